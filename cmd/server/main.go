@@ -12,7 +12,9 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/mail"
 	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/payments"
 	"github.com/ukuvago/angelvault/internal/routes"
 	"gorm.io/gorm"
 )
@@ -48,17 +50,42 @@ func main() {
 	defer database.Close()
 
 	// Run migrations (auto-migrate for development)
-	if err := autoMigrate(db); err != nil {
+	if err := autoMigrate(db, cfg); err != nil {
 		log.Fatal().Err(err).Msg("Failed to run migrations")
 	}
 
 	// Seed default data
-	seedDefaultData(db)
+	seedDefaultData(db, cfg)
+
+	// Payment processor (nil in demo mode)
+	provider, err := payments.NewProvider(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure payment provider")
+	}
+	if provider == nil {
+		log.Warn().Msg("No payment provider configured; running payments in demo mode")
+	}
+
+	// Outgoing email (nil when disabled)
+	mailer, err := mail.NewMailer(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure mailer")
+	}
+	if mailer == nil {
+		log.Warn().Msg("No mailer configured; emails will not be sent")
+	} else {
+		log.Info().Str("driver", mailer.Name()).Msg("Mail delivery configured")
+	}
 
 	// Setup routes
-	router := routes.NewRouter(cfg)
+	router := routes.NewRouter(cfg, provider, mailer)
 	engine := router.Setup()
 
+	// Start background expiry sweeps
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	router.StartBackgroundJobs(jobsCtx)
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -82,6 +109,7 @@ func main() {
 	<-quit
 
 	log.Info().Msg("Shutting down server...")
+	stopJobs()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -91,39 +119,60 @@ func main() {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
+	// Let emails queued by the last requests go out
+	router.WaitForEmails()
+
 	log.Info().Msg("Server exited properly")
 }
 
 // autoMigrate runs GORM auto-migrations
-func autoMigrate(db *gorm.DB) error {
+func autoMigrate(db *gorm.DB, cfg *config.Config) error {
 	log.Info().Msg("Running database migrations...")
 	
-	return db.AutoMigrate(
-		&models.User{},
-		&models.InvestorProfile{},
-		&models.Category{},
-		&models.Project{},
-		&models.TeamMember{},
-		&models.ProjectImage{},
-		&models.ProjectReadiness{},
-		&models.NDA{},
-		&models.ProjectNDAConfig{},
-		&models.ProjectNDASignature{},
-		&models.Payment{},
-		&models.ProjectView{},
-		&models.InvestmentOffer{},
-		&models.TermSheet{},
-		&models.PlatformCommission{},
-		&models.MeetingRequest{},
-		&models.Message{},
-		&models.AuditLog{},
-		&models.InvestorAccessLog{},
-		&models.ProjectViewLog{},
-	)
+	// Project views are unique per (investor, project); drop duplicates left by
+	// earlier racing unlocks so the unique index can be built
+	if db.Migrator().HasTable(&models.ProjectView{}) {
+		if err := db.Exec(`DELETE FROM project_views a USING project_views b
+			WHERE a.investor_id = b.investor_id AND a.project_id = b.project_id
+			AND (a.viewed_at, a.id) > (b.viewed_at, b.id)`).Error; err != nil {
+			return err
+		}
+	}
+	
+	hadLedger := db.Migrator().HasTable(&models.CreditLedgerEntry{})
+	
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		return err
+	}
+	
+	// Open the credit ledger with each payment's unused credits
+	if !hadLedger {
+		if err := db.Exec(`INSERT INTO credit_ledger_entries (id, investor_id, payment_id, entry_type, credits, description, created_at)
+			SELECT gen_random_uuid(), investor_id, id, ?, projects_remaining, ?, NOW()
+			FROM payments
+			WHERE status = ? AND projects_remaining > 0 AND deleted_at IS NULL`,
+			models.LedgerEntryOpeningBalance, "Balance carried over to the credit ledger",
+			models.PaymentStatusCompleted).Error; err != nil {
+			return err
+		}
+	}
+	
+	// Credits bought before they could expire get a full lifetime from now, not
+	// from their purchase date, so none lapse without a reminder
+	if cfg.CreditLifetimeMonths > 0 {
+		if err := db.Model(&models.Payment{}).
+			Where("status = ? AND expires_at IS NULL", models.PaymentStatusCompleted).
+			Update("expires_at", gorm.Expr("NOW() + make_interval(months => ?)", cfg.CreditLifetimeMonths)).
+			Error; err != nil {
+			return err
+		}
+	}
+	
+	return nil
 }
 
 // seedDefaultData seeds initial data if not present
-func seedDefaultData(db *gorm.DB) {
+func seedDefaultData(db *gorm.DB, cfg *config.Config) {
 	log.Info().Msg("Checking seed data...")
 
 	// Seed categories
@@ -141,6 +190,24 @@ func seedDefaultData(db *gorm.DB) {
 		log.Info().Int("count", len(models.DefaultCategories)).Msg("Categories seeded")
 	}
 
+	// Seed the standard credit package from the configured view fee
+	var packageCount int64
+	db.Model(&models.CreditPackage{}).Unscoped().Count(&packageCount)
+
+	if packageCount == 0 {
+		log.Info().Msg("Seeding default credit package...")
+		pkg := &models.CreditPackage{
+			Name:     "Standard",
+			Credits:  cfg.MaxProjectViews,
+			Amount:   cfg.ViewFeeAmount,
+			Currency: cfg.ViewFeeCurrency,
+			IsActive: true,
+		}
+		if err := db.Create(pkg).Error; err != nil {
+			log.Warn().Err(err).Msg("Failed to seed credit package")
+		}
+	}
+
 	// Create admin user if not exists
 	var adminCount int64
 	db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount)
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -21,7 +25,16 @@ type Config struct {
 
 	// JWT
 	JWTSecret          string
-	JWTExpirationHours int
+	AccessTokenMinutes int // Lifetime of access tokens
+	RefreshTokenDays   int // Idle lifetime of a session; each refresh extends it
+
+	// Two-factor authentication
+	TOTPIssuer string // Account label shown in authenticator apps
+
+	// Passkeys (WebAuthn)
+	WebAuthnRPID    string   // Domain passkeys are bound to; changing it orphans existing passkeys
+	WebAuthnRPName  string   // Shown by the browser and authenticator
+	WebAuthnOrigins []string // Frontend origins allowed to use passkeys
 
 	// OAuth Providers
 	GoogleClientID     string
@@ -38,18 +51,29 @@ type Config struct {
 	LinkedInClientSecret string
 	LinkedInRedirectURL  string
 
+	// Generic OpenID Connect providers, e.g. investor firms' corporate IdPs
+	OIDCProviders []OIDCProviderConfig
+
+	// Payment processor: "stripe", "fake" (offline, non-production) or empty to
+	// pick Stripe when a key is set and demo mode otherwise
+	PaymentProvider string
+
 	// Stripe
 	StripeSecretKey      string
 	StripePublishableKey string
 	StripeWebhookSecret  string
 
 	// Payment Config
-	ViewFeeAmount   int64  // Amount in cents
-	ViewFeeCurrency string
-	MaxProjectViews int
+	ViewFeeAmount            int64 // Amount in minor units of ViewFeeCurrency
+	ViewFeeCurrency          string
+	MaxProjectViews          int
+	CreditLifetimeMonths     int // Months after purchase before unused credits expire (0 = never)
+	CreditExpiryReminderDays int // Days before expiry that investors are reminded
+	ReportingCurrency        string // Mixed-currency revenue is normalised into this currency
 	
 	// Commission Config
-	CommissionRate float64 // Platform commission rate (e.g., 0.02 for 2%)
+	CommissionRate          float64 // Platform commission rate (e.g., 0.02 for 2%)
+	CommissionPaymentDays   int     // Days until a commission invoice is due
 
 	// Email (SendGrid or similar)
 	SMTPHost     string
@@ -59,6 +83,11 @@ type Config struct {
 	FromEmail    string
 	FromName     string
 
+	// Mail delivery: "smtp", "file" (writes .eml files to MailDir, non-production)
+	// or empty to send over SMTP when a host is set and disable mail otherwise
+	MailDriver string
+	MailDir    string
+
 	// Cloud Storage (GCS)
 	GCSBucket          string
 	GCSCredentialsFile string
@@ -66,6 +95,12 @@ type Config struct {
 	// NDA Config
 	NDAValidityYears int
 
+	// Term Sheet Config
+	TermSheetValidityDays int // Days both parties have to sign before a term sheet expires
+
+	// Background Jobs
+	SweepInterval time.Duration // How often expiry sweeps run
+
 	// Rate Limiting
 	RateLimitRequests int
 	RateLimitWindow   time.Duration
@@ -88,7 +123,14 @@ func Load() (*Config, error) {
 
 		// JWT
 		JWTSecret:          mustGetEnv("JWT_SECRET"),
-		JWTExpirationHours: getEnvInt("JWT_EXPIRATION_HOURS", 72),
+		AccessTokenMinutes: getEnvInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:   getEnvInt("REFRESH_TOKEN_DAYS", 30),
+
+		// Two-factor authentication
+		TOTPIssuer: getEnv("TOTP_ISSUER", "AngelVault"),
+
+		// Passkeys
+		WebAuthnRPName: getEnv("WEBAUTHN_RP_NAME", "AngelVault"),
 
 		// OAuth - Google
 		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
@@ -107,18 +149,24 @@ func Load() (*Config, error) {
 		LinkedInClientSecret: getEnv("LINKEDIN_CLIENT_SECRET", ""),
 		LinkedInRedirectURL:  getEnv("LINKEDIN_REDIRECT_URL", ""),
 
+		PaymentProvider: getEnv("PAYMENT_PROVIDER", ""),
+
 		// Stripe
 		StripeSecretKey:      getEnv("STRIPE_SECRET_KEY", ""),
 		StripePublishableKey: getEnv("STRIPE_PUBLISHABLE_KEY", ""),
 		StripeWebhookSecret:  getEnv("STRIPE_WEBHOOK_SECRET", ""),
 
 		// Payment Config
-		ViewFeeAmount:   getEnvInt64("VIEW_FEE_AMOUNT", 50000), // $500.00
-		ViewFeeCurrency: getEnv("VIEW_FEE_CURRENCY", "usd"),
-		MaxProjectViews: getEnvInt("MAX_PROJECT_VIEWS", 5),
+		ViewFeeAmount:            getEnvInt64("VIEW_FEE_AMOUNT", 50000), // $500.00
+		ViewFeeCurrency:          getEnv("VIEW_FEE_CURRENCY", "usd"),
+		MaxProjectViews:          getEnvInt("MAX_PROJECT_VIEWS", 5),
+		CreditLifetimeMonths:     getEnvInt("CREDIT_LIFETIME_MONTHS", 12),
+		CreditExpiryReminderDays: getEnvInt("CREDIT_EXPIRY_REMINDER_DAYS", 14),
+		ReportingCurrency:        strings.ToLower(getEnv("REPORTING_CURRENCY", getEnv("VIEW_FEE_CURRENCY", "usd"))),
 		
 		// Commission Config
-		CommissionRate:  getEnvFloat("COMMISSION_RATE", 0.02), // 2% default
+		CommissionRate:        getEnvFloat("COMMISSION_RATE", 0.02), // 2% default
+		CommissionPaymentDays: getEnvInt("COMMISSION_PAYMENT_DAYS", 30),
 
 		// Email
 		SMTPHost:     getEnv("SMTP_HOST", ""),
@@ -127,6 +175,8 @@ func Load() (*Config, error) {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		FromEmail:    getEnv("FROM_EMAIL", "noreply@angelvault.io"),
 		FromName:     getEnv("FROM_NAME", "AngelVault"),
+		MailDriver:   getEnv("MAIL_DRIVER", ""),
+		MailDir:      getEnv("MAIL_DIR", "./tmp/mail"),
 
 		// Cloud Storage
 		GCSBucket:          getEnv("GCS_BUCKET", ""),
@@ -135,11 +185,27 @@ func Load() (*Config, error) {
 		// NDA
 		NDAValidityYears: getEnvInt("NDA_VALIDITY_YEARS", 2),
 
+		// Term Sheets
+		TermSheetValidityDays: getEnvInt("TERM_SHEET_VALIDITY_DAYS", 30),
+
+		// Background Jobs
+		SweepInterval: time.Duration(getEnvInt("SWEEP_INTERVAL_MINUTES", 15)) * time.Minute,
+
 		// Rate Limiting
 		RateLimitRequests: getEnvInt("RATE_LIMIT_REQUESTS", 100),
 		RateLimitWindow:   time.Duration(getEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second,
 	}
 
+	providers, err := parseOIDCProviders(getEnv("OIDC_PROVIDERS", ""), cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.OIDCProviders = providers
+
+	// Passkeys default to the host the app is served from
+	cfg.WebAuthnOrigins = splitList(getEnv("WEBAUTHN_ORIGINS", cfg.BaseURL))
+	cfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", hostname(cfg.BaseURL))
+
 	// Validate critical configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -148,6 +214,91 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// OIDCProviderConfig configures one OpenID Connect provider. Endpoints and
+// signing keys come from the issuer's discovery document.
+type OIDCProviderConfig struct {
+	Name         string           `json:"name"`         // URL-safe key, e.g. "acme"
+	DisplayName  string           `json:"display_name"` // Button label
+	Issuer       string           `json:"issuer"`
+	ClientID     string           `json:"client_id"`
+	ClientSecret string           `json:"client_secret"`
+	RedirectURL  string           `json:"redirect_url"` // Defaults to BASE_URL/api/auth/oidc/<name>/callback
+	Scopes       []string         `json:"scopes"`
+	Claims       OIDCClaimMapping `json:"claims"`
+}
+
+// OIDCClaimMapping names the claims that hold each user attribute, for IdPs
+// that do not use the standard ones
+type OIDCClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Picture       string `json:"picture"`
+}
+
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,14}$`)
+
+// parseOIDCProviders reads OIDC_PROVIDERS, a JSON array of provider configs,
+// and fills in defaults
+func parseOIDCProviders(raw, baseURL string) ([]OIDCProviderConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var providers []OIDCProviderConfig
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, fmt.Errorf("OIDC_PROVIDERS is not valid JSON: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i := range providers {
+		p := &providers[i]
+		if !oidcProviderName.MatchString(p.Name) {
+			return nil, fmt.Errorf("OIDC provider name %q must be 1-15 lowercase letters, digits or dashes", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("OIDC provider %q is configured twice", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs an issuer and client_id", p.Name)
+		}
+
+		if p.DisplayName == "" {
+			p.DisplayName = p.Name
+		}
+		if p.RedirectURL == "" {
+			p.RedirectURL = strings.TrimSuffix(baseURL, "/") + "/api/auth/oidc/" + p.Name + "/callback"
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		p.Claims.setDefaults()
+	}
+	return providers, nil
+}
+
+func (m *OIDCClaimMapping) setDefaults() {
+	defaults := []struct {
+		field    *string
+		standard string
+	}{
+		{&m.Subject, "sub"},
+		{&m.Email, "email"},
+		{&m.EmailVerified, "email_verified"},
+		{&m.FirstName, "given_name"},
+		{&m.LastName, "family_name"},
+		{&m.Picture, "picture"},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.standard
+		}
+	}
+}
+
 func (c *Config) Validate() error {
 	if c.Environment == "production" {
 		if c.JWTSecret == "changeme" || len(c.JWTSecret) < 32 {
@@ -202,6 +353,25 @@ func getEnvInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+// splitList parses a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// hostname returns the host of a URL without its port
+func hostname(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
 func getEnvFloat(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
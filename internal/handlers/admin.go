@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
 	"github.com/ukuvago/angelvault/internal/models"
 	"github.com/ukuvago/angelvault/internal/services"
 )
@@ -139,6 +140,54 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	})
 }
 
+// RevokeUserSessions signs a user out of every device
+func (h *AdminHandler) RevokeUserSessions(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	revoked, err := h.adminService.RevokeUserSessions(adminID, userID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked",
+		"revoked": revoked,
+	})
+}
+
+// ResetTwoFactor clears a user's 2FA so they can enroll again
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.adminService.ResetTwoFactor(adminID, userID, req.Reason, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
 // CreateDeveloper creates a new developer account
 func (h *AdminHandler) CreateDeveloper(c *gin.Context) {
 	var req struct {
@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/middleware"
 	"github.com/ukuvago/angelvault/internal/models"
 	"github.com/ukuvago/angelvault/internal/services"
 )
 
+// oauthBindingCookie ties OAuth callbacks to the browser that started them
+const oauthBindingCookie = "oauth_binding"
+
 type AuthHandler struct {
 	authService  *services.AuthService
 	oauthService *services.OAuthService
@@ -32,7 +37,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.authService.Register(&req)
+	resp, err := h.authService.Register(&req, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -49,7 +54,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.authService.Login(&req)
+	resp, err := h.authService.Login(&req, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -58,6 +63,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// Refresh exchanges a refresh token for new access and refresh tokens
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.authService.Refresh(req.RefreshToken, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	sessionID, _ := middleware.GetSessionID(c)
+
+	if err := h.authService.Logout(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out"})
+}
+
 // GetCurrentUser returns the authenticated user
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -203,6 +241,7 @@ func (h *AuthHandler) GetInvestorProfile(c *gin.Context) {
 // ChangePassword changes user's password
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, _ := middleware.GetUserID(c)
+	sessionID, _ := middleware.GetSessionID(c)
 
 	var req struct {
 		CurrentPassword string `json:"current_password" binding:"required"`
@@ -214,7 +253,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.ChangePassword(userID, req.CurrentPassword, req.NewPassword); err != nil {
+	if err := h.authService.ChangePassword(userID, sessionID, req.CurrentPassword, req.NewPassword); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -234,7 +273,10 @@ func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	}
 
 	// Always return success to prevent email enumeration
-	h.authService.RequestPasswordReset(req.Email)
+	if err := h.authService.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "If an account with that email exists, a reset link has been sent"})
 }
 
@@ -274,52 +316,47 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
 
-// OAuth Handlers
-
-// GetOAuthProviders returns available OAuth providers
-func (h *AuthHandler) GetOAuthProviders(c *gin.Context) {
-	providers := []gin.H{}
-
-	if h.oauthService.IsGoogleEnabled() {
-		providers = append(providers, gin.H{
-			"name":    "google",
-			"enabled": true,
-		})
+// ExchangeLoginCode swaps the one-time code from an OAuth redirect for tokens,
+// or for a 2FA challenge when a second factor is needed
+func (h *AuthHandler) ExchangeLoginCode(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
 	}
-
-	if h.oauthService.IsLinkedInEnabled() {
-		providers = append(providers, gin.H{
-			"name":    "linkedin",
-			"enabled": true,
-		})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if h.oauthService.IsAppleEnabled() {
-		providers = append(providers, gin.H{
-			"name":    "apple",
-			"enabled": true,
-		})
+	resp, err := h.authService.ExchangeLoginCode(req.Code, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"providers": providers})
+	c.JSON(http.StatusOK, resp)
+}
+
+// OAuth Handlers
+
+// GetOAuthProviders returns the configured sign-in providers
+func (h *AuthHandler) GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oauthService.Providers()})
 }
 
 // GoogleAuthURL returns the Google OAuth URL
 func (h *AuthHandler) GoogleAuthURL(c *gin.Context) {
-	role := c.Query("role")
-	if role == "" {
-		role = "investor"
+	if !h.oauthService.IsGoogleEnabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Google OAuth not configured"})
+		return
 	}
 
-	state := services.GenerateState() + ":" + role
-	url := h.oauthService.GetGoogleAuthURL(state)
-
-	if url == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Google OAuth not configured"})
+	state, oauthState, err := h.beginOAuth(c, models.AuthProviderGoogle, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	c.JSON(http.StatusOK, gin.H{"url": h.oauthService.GetGoogleAuthURL(state, oauthState.CodeVerifier)})
 }
 
 // GoogleCallback handles Google OAuth callback
@@ -332,48 +369,34 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// Extract role from state
-	role := models.RoleInvestor
-	if len(state) > 0 {
-		parts := splitState(state)
-		if len(parts) > 1 && parts[1] == "developer" {
-			role = models.RoleDeveloper
-		}
-	}
-
-	userInfo, err := h.oauthService.HandleGoogleCallback(c.Request.Context(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	oauthState, ok := h.consumeOAuthState(c, models.AuthProviderGoogle, state)
+	if !ok {
 		return
 	}
 
-	resp, err := h.authService.LoginWithOAuth(userInfo, role)
+	userInfo, err := h.oauthService.HandleGoogleCallback(c.Request.Context(), code, oauthState.CodeVerifier)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Redirect to frontend with token
-	redirectURL := h.config.BaseURL + "/auth/callback?token=" + resp.Token
-	c.Redirect(http.StatusFound, redirectURL)
+	h.completeOAuth(c, oauthState, userInfo)
 }
 
 // LinkedInAuthURL returns the LinkedIn OAuth URL
 func (h *AuthHandler) LinkedInAuthURL(c *gin.Context) {
-	role := c.Query("role")
-	if role == "" {
-		role = "investor"
+	if !h.oauthService.IsLinkedInEnabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LinkedIn OAuth not configured"})
+		return
 	}
 
-	state := services.GenerateState() + ":" + role
-	url := h.oauthService.GetLinkedInAuthURL(state)
-
-	if url == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LinkedIn OAuth not configured"})
+	state, oauthState, err := h.beginOAuth(c, models.AuthProviderLinkedIn, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	c.JSON(http.StatusOK, gin.H{"url": h.oauthService.GetLinkedInAuthURL(state, oauthState.CodeVerifier)})
 }
 
 // LinkedInCallback handles LinkedIn OAuth callback
@@ -386,46 +409,34 @@ func (h *AuthHandler) LinkedInCallback(c *gin.Context) {
 		return
 	}
 
-	role := models.RoleInvestor
-	if len(state) > 0 {
-		parts := splitState(state)
-		if len(parts) > 1 && parts[1] == "developer" {
-			role = models.RoleDeveloper
-		}
-	}
-
-	userInfo, err := h.oauthService.HandleLinkedInCallback(c.Request.Context(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	oauthState, ok := h.consumeOAuthState(c, models.AuthProviderLinkedIn, state)
+	if !ok {
 		return
 	}
 
-	resp, err := h.authService.LoginWithOAuth(userInfo, role)
+	userInfo, err := h.oauthService.HandleLinkedInCallback(c.Request.Context(), code, oauthState.CodeVerifier)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	redirectURL := h.config.BaseURL + "/auth/callback?token=" + resp.Token
-	c.Redirect(http.StatusFound, redirectURL)
+	h.completeOAuth(c, oauthState, userInfo)
 }
 
 // AppleAuthURL returns the Apple OAuth URL
 func (h *AuthHandler) AppleAuthURL(c *gin.Context) {
-	role := c.Query("role")
-	if role == "" {
-		role = "investor"
+	if !h.oauthService.IsAppleEnabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Apple OAuth not configured"})
+		return
 	}
 
-	state := services.GenerateState() + ":" + role
-	url := h.oauthService.GetAppleAuthURL(state)
-
-	if url == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Apple OAuth not configured"})
+	state, oauthState, err := h.beginOAuth(c, models.AuthProviderApple, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	c.JSON(http.StatusOK, gin.H{"url": h.oauthService.GetAppleAuthURL(state, oauthState.Nonce)})
 }
 
 // AppleCallback handles Apple OAuth callback (form_post)
@@ -440,44 +451,82 @@ func (h *AuthHandler) AppleCallback(c *gin.Context) {
 		return
 	}
 
-	role := models.RoleInvestor
-	if len(state) > 0 {
-		parts := splitState(state)
-		if len(parts) > 1 && parts[1] == "developer" {
-			role = models.RoleDeveloper
-		}
+	oauthState, ok := h.consumeOAuthState(c, models.AuthProviderApple, state)
+	if !ok {
+		return
 	}
 
-	userInfo, err := h.oauthService.HandleAppleCallback(c.Request.Context(), code, idToken, userData)
+	userInfo, err := h.oauthService.HandleAppleCallback(c.Request.Context(), code, idToken, userData, oauthState.Nonce)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	resp, err := h.authService.LoginWithOAuth(userInfo, role)
+	h.completeOAuth(c, oauthState, userInfo)
+}
+
+// completeOAuth finishes a callback: it links the provider account when the
+// flow was started from a signed-in profile, and otherwise signs in, sending
+// the browser back to the frontend with a one-time code to exchange for tokens
+func (h *AuthHandler) completeOAuth(c *gin.Context, oauthState *models.OAuthState, userInfo *services.OAuthUserInfo) {
+	if oauthState.LinkUserID != nil {
+		params := url.Values{}
+		if _, err := h.authService.LinkIdentity(*oauthState.LinkUserID, userInfo, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+			params.Set("link_error", err.Error())
+		} else {
+			params.Set("linked", string(oauthState.Provider))
+		}
+		c.Redirect(http.StatusFound, h.config.BaseURL+"/settings/linked-accounts?"+params.Encode())
+		return
+	}
+
+	loginCode, err := h.authService.LoginWithOAuth(userInfo, oauthState.Role)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	redirectURL := h.config.BaseURL + "/auth/callback?token=" + resp.Token
-	c.Redirect(http.StatusFound, redirectURL)
+	c.Redirect(http.StatusFound, h.config.BaseURL+"/auth/callback?code="+url.QueryEscape(loginCode))
 }
 
-// Helper to split state string
-func splitState(state string) []string {
-	result := []string{}
-	current := ""
-	for _, c := range state {
-		if c == ':' {
-			result = append(result, current)
-			current = ""
-		} else {
-			current += string(c)
-		}
+// beginOAuth starts a sign-in with a provider, or a link to linkUserID's
+// account. The browser is identified by a random nonce in an HttpOnly cookie,
+// reused across concurrent sign-ins, which the callback must present along with
+// the state.
+func (h *AuthHandler) beginOAuth(c *gin.Context, provider models.AuthProvider, linkUserID *uuid.UUID) (string, *models.OAuthState, error) {
+	binding, err := c.Cookie(oauthBindingCookie)
+	if err != nil || binding == "" {
+		binding = services.GenerateState()
 	}
-	if current != "" {
-		result = append(result, current)
+
+	state, oauthState, err := h.oauthService.BeginLogin(provider, models.UserRole(c.Query("role")), binding, linkUserID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Apple posts its callback cross-site, which only SameSite=None cookies
+	// survive; browsers require those to be Secure, so plain-HTTP development
+	// falls back to Lax
+	secure := h.config.IsProduction()
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(oauthBindingCookie, binding, int(h.oauthService.StateTTL().Seconds()), "/api/auth", "", secure, true)
+
+	return state, oauthState, nil
+}
+
+// consumeOAuthState validates a callback's state against this browser's
+// sign-in, writing the error response if it does not match
+func (h *AuthHandler) consumeOAuthState(c *gin.Context, provider models.AuthProvider, state string) (*models.OAuthState, bool) {
+	binding, _ := c.Cookie(oauthBindingCookie)
+
+	oauthState, err := h.oauthService.ConsumeState(provider, state, binding)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
-	return result
+	return oauthState, true
 }
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type CommissionHandler struct {
+	commissionService *services.CommissionService
+}
+
+func NewCommissionHandler(commissionSvc *services.CommissionService) *CommissionHandler {
+	return &CommissionHandler{commissionService: commissionSvc}
+}
+
+// ConfirmFunds records receipt of investment funds and raises the commission invoice
+func (h *CommissionHandler) ConfirmFunds(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	termSheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term sheet ID"})
+		return
+	}
+
+	var req services.ConfirmFundsInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	termSheet, commission, err := h.commissionService.ConfirmFundsReceived(adminID, termSheetID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Funds confirmed and commission invoice created",
+		"term_sheet": termSheet,
+		"commission": commission,
+	})
+}
+
+// ReissueInvoice raises a new commission invoice after the previous one was cancelled
+func (h *CommissionHandler) ReissueInvoice(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	termSheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term sheet ID"})
+		return
+	}
+
+	commission, err := h.commissionService.ReissueInvoice(adminID, termSheetID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Commission invoice created",
+		"commission": commission,
+	})
+}
+
+// GetDeveloperCommissions returns the developer's own commission invoices
+func (h *CommissionHandler) GetDeveloperCommissions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	params := services.ListCommissionsParams{
+		DeveloperID: &userID,
+		Status:      c.Query("status"),
+		Page:        1,
+		PageSize:    100,
+	}
+
+	commissions, total, err := h.commissionService.ListCommissions(&params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commissions": commissions,
+		"total":       total,
+	})
+}
+
+// ListCommissions returns commission invoices with filtering and pagination
+func (h *CommissionHandler) ListCommissions(c *gin.Context) {
+	params := services.ListCommissionsParams{
+		Page:     1,
+		PageSize: 20,
+		Status:   c.Query("status"),
+		Search:   c.Query("search"),
+		Overdue:  c.Query("overdue") == "true",
+	}
+
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil {
+			params.Page = parsed
+		}
+	}
+
+	// Accept both page_size and the frontend's limit
+	for _, key := range []string{"page_size", "limit"} {
+		if ps := c.Query(key); ps != "" {
+			if parsed, err := strconv.Atoi(ps); err == nil {
+				params.PageSize = parsed
+			}
+		}
+	}
+
+	if developerID := c.Query("developer_id"); developerID != "" {
+		if id, err := uuid.Parse(developerID); err == nil {
+			params.DeveloperID = &id
+		}
+	}
+
+	commissions, total, err := h.commissionService.ListCommissions(&params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch commissions"})
+		return
+	}
+
+	totalPages := (total + int64(params.PageSize) - 1) / int64(params.PageSize)
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commissions": commissions,
+		"total":       total,
+		"page":        params.Page,
+		"page_size":   params.PageSize,
+		"total_pages": totalPages,
+	})
+}
+
+// GetCommissionStats returns commission revenue totals
+func (h *CommissionHandler) GetCommissionStats(c *gin.Context) {
+	stats, err := h.commissionService.GetCommissionStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch commission stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetCommission returns a single commission invoice
+func (h *CommissionHandler) GetCommission(c *gin.Context) {
+	commissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid commission ID"})
+		return
+	}
+
+	commission, err := h.commissionService.GetCommission(commissionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"commission": commission})
+}
+
+// SendInvoice marks a draft commission invoice as sent
+func (h *CommissionHandler) SendInvoice(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	commissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid commission ID"})
+		return
+	}
+
+	commission, err := h.commissionService.SendInvoice(adminID, commissionID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invoice sent",
+		"commission": commission,
+	})
+}
+
+// MarkPaid records payment of a commission invoice
+func (h *CommissionHandler) MarkPaid(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	commissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid commission ID"})
+		return
+	}
+
+	var req services.RecordPaymentInput
+	// Body is optional
+	c.ShouldBindJSON(&req)
+
+	commission, err := h.commissionService.RecordPayment(adminID, commissionID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Payment recorded",
+		"commission": commission,
+	})
+}
+
+// CancelInvoice cancels an unpaid commission invoice
+func (h *CommissionHandler) CancelInvoice(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	commissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid commission ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Body is optional
+	c.ShouldBindJSON(&req)
+
+	commission, err := h.commissionService.CancelInvoice(adminID, commissionID, req.Reason, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invoice cancelled",
+		"commission": commission,
+	})
+}
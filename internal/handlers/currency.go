@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type CurrencyHandler struct {
+	currencyService *services.CurrencyService
+}
+
+func NewCurrencyHandler(currencySvc *services.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{currencyService: currencySvc}
+}
+
+// ListCurrencies returns the currencies prices can be set in
+func (h *CurrencyHandler) ListCurrencies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"currencies": models.SupportedCurrencies()})
+}
+
+// ListExchangeRates returns the FX rate history (admin)
+func (h *CurrencyHandler) ListExchangeRates(c *gin.Context) {
+	rates, err := h.currencyService.ListExchangeRates(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rates": rates})
+}
+
+// SetExchangeRate records a new FX rate into the reporting currency (admin)
+func (h *CurrencyHandler) SetExchangeRate(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req services.ExchangeRateInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := h.currencyService.SetExchangeRate(adminID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Exchange rate recorded",
+		"rate":    rate,
+	})
+}
+
+// GetRevenueReport returns revenue for a period in the reporting currency (admin).
+// The period defaults to the current month; end_date is inclusive.
+func (h *CurrencyHandler) GetRevenueReport(c *gin.Context) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+
+	if sd := c.Query("start_date"); sd != "" {
+		parsed, err := time.Parse("2006-01-02", sd)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date"})
+			return
+		}
+		from = parsed
+	}
+	if ed := c.Query("end_date"); ed != "" {
+		parsed, err := time.Parse("2006-01-02", ed)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date"})
+			return
+		}
+		to = parsed.Add(24 * time.Hour)
+	}
+
+	report, err := h.currencyService.GetRevenueReport(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build revenue report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type DocumentHandler struct {
+	documentService *services.DocumentService
+}
+
+func NewDocumentHandler(documentSvc *services.DocumentService) *DocumentHandler {
+	return &DocumentHandler{documentService: documentSvc}
+}
+
+// DownloadCommissionInvoice streams a commission invoice as PDF
+func (h *DocumentHandler) DownloadCommissionInvoice(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetUserRole(c)
+
+	commissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	commission, content, err := h.documentService.GetCommissionInvoicePDF(userID, role, commissionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendPDF(c, commission.InvoiceNumber+".pdf", content)
+}
+
+// DownloadPaymentReceipt streams a view-credit purchase receipt as PDF
+func (h *DocumentHandler) DownloadPaymentReceipt(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	payment, content, err := h.documentService.GetPaymentReceiptPDF(userID, paymentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendPDF(c, "receipt-"+payment.ID.String()[:8]+".pdf", content)
+}
+
+func sendPDF(c *gin.Context, filename string, content []byte) {
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", content)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+// ListIdentities returns the provider accounts linked to the current user
+func (h *AuthHandler) ListIdentities(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	identities, err := h.authService.ListIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load linked accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// LinkIdentity returns the provider URL for linking another sign-in to the
+// current user. The provider redirects back to the usual callback, which links
+// the account and returns the browser to the profile.
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	provider := models.AuthProvider(c.Param("provider"))
+	if !h.oauthService.IsEnabled(provider) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or unconfigured provider"})
+		return
+	}
+
+	state, oauthState, err := h.beginOAuth(c, provider, &userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start linking"})
+		return
+	}
+
+	url, err := h.oauthService.AuthURL(c.Request.Context(), oauthState, state)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// UnlinkIdentity removes a linked provider account from the current user
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	identityID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	if err := h.authService.UnlinkIdentity(userID, identityID, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrIdentityNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlinked"})
+}
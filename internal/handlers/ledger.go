@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type LedgerHandler struct {
+	ledgerService *services.LedgerService
+}
+
+func NewLedgerHandler(ledgerSvc *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{ledgerService: ledgerSvc}
+}
+
+// GetMyLedger returns the investor's credit balance and movements
+func (h *LedgerHandler) GetMyLedger(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	entries, total, err := h.ledgerService.GetLedger(userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance": h.ledgerService.GetCreditBalance(userID),
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+	})
+}
+
+// GetUserCredits returns an investor's credit balance and movements (admin)
+func (h *LedgerHandler) GetUserCredits(c *gin.Context) {
+	investorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	entries, total, err := h.ledgerService.GetLedger(investorID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance": h.ledgerService.GetCreditBalance(investorID),
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+	})
+}
+
+// Reconcile reports drift between the credit ledger and the payments table
+func (h *LedgerHandler) Reconcile(c *gin.Context) {
+	report, err := h.ledgerService.Reconcile()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile credit ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
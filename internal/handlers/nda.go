@@ -61,6 +61,30 @@ func (h *NDAHandler) SignMasterNDA(c *gin.Context) {
 	})
 }
 
+// SignOrganizationNDA signs the master NDA on behalf of the investor's organisation
+func (h *NDAHandler) SignOrganizationNDA(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.SignNDARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nda, err := h.ndaService.SignOrganizationNDA(userID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "NDA signed for the organisation",
+		"organization_id": nda.OrganizationID,
+		"signed_at":       nda.SignedAt,
+		"expires_at":      nda.ExpiresAt,
+	})
+}
+
 // GetProjectNDAStatus returns the NDA status for a specific project
 func (h *NDAHandler) GetProjectNDAStatus(c *gin.Context) {
 	userID, _ := middleware.GetUserID(c)
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type OfferHandler struct {
+	offerService *services.OfferService
+}
+
+func NewOfferHandler(offerSvc *services.OfferService) *OfferHandler {
+	return &OfferHandler{offerService: offerSvc}
+}
+
+// ========================================
+// INVESTOR ENDPOINTS
+// ========================================
+
+// CreateOffer submits a new investment offer
+func (h *OfferHandler) CreateOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.CreateOfferInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer, err := h.offerService.CreateOffer(userID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Offer submitted successfully",
+		"offer":   offer.ToResponse(),
+	})
+}
+
+// GetInvestorOffers returns all offers submitted by the investor
+func (h *OfferHandler) GetInvestorOffers(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offers, err := h.offerService.GetInvestorOffers(userID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
+		return
+	}
+
+	response := []models.OfferResponse{}
+	for _, o := range offers {
+		response = append(response, o.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offers": response})
+}
+
+// WithdrawOffer withdraws a pending offer
+func (h *OfferHandler) WithdrawOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	offer, err := h.offerService.WithdrawOffer(userID, offerID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Offer withdrawn",
+		"offer":   offer.ToResponse(),
+	})
+}
+
+// ========================================
+// DEVELOPER ENDPOINTS
+// ========================================
+
+// GetDeveloperOffers returns all offers on the developer's projects
+func (h *OfferHandler) GetDeveloperOffers(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offers, err := h.offerService.GetDeveloperOffers(userID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
+		return
+	}
+
+	// Convert to response format with investor details
+	response := []gin.H{}
+	for _, o := range offers {
+		resp := gin.H{"offer": o.ToResponse()}
+
+		if o.Investor != nil {
+			resp["investor"] = gin.H{
+				"id":           o.Investor.ID,
+				"name":         o.Investor.FullName(),
+				"company_name": o.Investor.CompanyName,
+				"email":        o.Investor.Email,
+			}
+
+			if o.Investor.InvestorProfile != nil {
+				resp["investor_profile"] = gin.H{
+					"investor_type": o.Investor.InvestorProfile.InvestorType,
+					"linkedin_url":  o.Investor.InvestorProfile.LinkedInURL,
+				}
+			}
+		}
+
+		response = append(response, resp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offers": response})
+}
+
+// RejectOffer rejects a pending offer
+func (h *OfferHandler) RejectOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	var req struct {
+		ResponseMessage string `json:"response_message"`
+	}
+	// Body is optional
+	c.ShouldBindJSON(&req)
+
+	offer, err := h.offerService.RejectOffer(userID, offerID, req.ResponseMessage, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Offer rejected",
+		"offer":   offer.ToResponse(),
+	})
+}
+
+// ========================================
+// SHARED ENDPOINTS
+// ========================================
+
+// GetOffer returns a single offer
+func (h *OfferHandler) GetOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	offer, err := h.offerService.GetOffer(offerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Verify user is authorized
+	isInvestor := offer.InvestorID == userID
+	isDeveloper := offer.Project != nil && offer.Project.DeveloperID == userID
+
+	if !isInvestor && !isDeveloper {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this offer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offer": offer.ToResponse()})
+}
+
+// AcceptOffer accepts the latest revision of a pending offer.
+// Developers accept investor terms; investors accept a founder's counter-offer.
+func (h *OfferHandler) AcceptOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	var req struct {
+		Revision        int    `json:"revision" binding:"omitempty,min=1"` // Defaults to the original offer
+		ResponseMessage string `json:"response_message"`
+	}
+	// Body is optional, but must be valid when sent
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer, err := h.offerService.AcceptOffer(userID, offerID, req.Revision, req.ResponseMessage, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Offer accepted",
+		"offer":   offer.ToResponse(),
+	})
+}
+
+// CounterOffer proposes revised terms on a pending offer
+func (h *OfferHandler) CounterOffer(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	var req services.CounterOfferInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer, err := h.offerService.CounterOffer(userID, offerID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Counter-offer submitted",
+		"offer":   offer.ToResponse(),
+	})
+}
+
+// GetOfferRevisions returns the negotiation history of an offer
+func (h *OfferHandler) GetOfferRevisions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	revisions, err := h.offerService.GetOfferRevisions(userID, offerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
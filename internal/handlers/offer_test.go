@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+func TestAcceptOfferRejectsInvalidBody(t *testing.T) {
+	h := NewOfferHandler(nil)
+
+	for _, body := range []string{`{"revision": 0`, `{"revision": -1}`} {
+		if w := serve(h.AcceptOffer, uuid.New(), uuid.NewString(), body); w.Code != http.StatusBadRequest {
+			t.Fatalf("got %d for body %s, want 400", w.Code, body)
+		}
+	}
+}
+
+func TestAcceptOfferRevisionIsOptional(t *testing.T) {
+	requireDB(t)
+	cfg := testConfig()
+	offerSvc := services.NewOfferService(cfg, services.NewAuditService(cfg))
+	h := NewOfferHandler(offerSvc)
+	project := createTestProject(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no body", ""},
+		{"explicit revision", `{"revision": 1, "response_message": "Welcome aboard"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			investor := createTestUser(t, models.RoleInvestor)
+			offer, err := offerSvc.CreateOffer(investor.ID, &services.CreateOfferInput{
+				ProjectID: project.ID,
+				Amount:    project.MinInvestment,
+			}, "127.0.0.1", "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := serve(h.AcceptOffer, project.DeveloperID, offer.ID.String(), tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Offer struct {
+					Status models.OfferStatus `json:"status"`
+				} `json:"offer"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Offer.Status != models.OfferStatusAccepted {
+				t.Fatalf("got status %q, want accepted", resp.Offer.Status)
+			}
+		})
+	}
+}
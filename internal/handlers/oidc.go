@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+// OIDCAuthURL returns the authorization URL for a configured OIDC provider
+func (h *AuthHandler) OIDCAuthURL(c *gin.Context) {
+	provider := models.OIDCProvider(c.Param("provider"))
+	if !h.oauthService.IsEnabled(provider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown sign-in provider"})
+		return
+	}
+
+	state, oauthState, err := h.beginOAuth(c, provider, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+
+	url, err := h.oauthService.AuthURL(c.Request.Context(), oauthState, state)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// OIDCCallback handles the redirect back from an OIDC provider
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	provider := models.OIDCProvider(c.Param("provider"))
+	code := c.Query("code")
+	state := c.Query("state")
+
+	if code == "" {
+		message := "Authorization code required"
+		if desc := c.Query("error_description"); desc != "" {
+			message = desc
+		} else if reason := c.Query("error"); reason != "" {
+			message = reason
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	oauthState, ok := h.consumeOAuthState(c, provider, state)
+	if !ok {
+		return
+	}
+
+	userInfo, err := h.oauthService.HandleOIDCCallback(c.Request.Context(), provider, code, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.completeOAuth(c, oauthState, userInfo)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationHandler(organizationSvc *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{organizationService: organizationSvc}
+}
+
+// GetMyOrganization returns the investor's organisation and their role in it
+func (h *OrganizationHandler) GetMyOrganization(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, member, err := h.organizationService.GetOrganization(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": org,
+		"role":         member.Role,
+	})
+}
+
+// CreateOrganization sets up an organisation for an institutional investor
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.CreateOrganizationInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.organizationService.CreateOrganization(userID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Organisation created",
+		"organization": org,
+	})
+}
+
+// AddMember adds an investor to the organisation (owners)
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.AddMemberInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.organizationService.AddMember(userID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Member added",
+		"member":  member,
+	})
+}
+
+// UpdateMemberRole changes a member's role (owners)
+func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	var req struct {
+		Role models.OrganizationRole `json:"role" binding:"required,oneof=owner partner analyst"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.organizationService.UpdateMemberRole(userID, memberID, req.Role, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member role updated",
+		"member":  member,
+	})
+}
+
+// RemoveMember removes a member from the organisation (owners)
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(userID, memberID, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// LeaveOrganization removes the investor from their organisation
+func (h *OrganizationHandler) LeaveOrganization(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.organizationService.RemoveMember(userID, userID, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You have left the organisation"})
+}
+
+// SetSignatory authorises a member to sign the master NDA (owners)
+func (h *OrganizationHandler) SetSignatory(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req struct {
+		UserID uuid.UUID `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.organizationService.SetSignatory(userID, req.UserID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Signatory updated",
+		"organization": org,
+	})
+}
+
+// ListOrganizations returns every organisation with its members (admin)
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.organizationService.ListOrganizations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organisations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
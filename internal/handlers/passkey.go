@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+// BeginPasskeyLogin returns WebAuthn request options for signing in. The email
+// is optional; without it the browser offers any passkey saved for the site.
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	// An empty body is fine
+	_ = c.ShouldBindJSON(&req)
+
+	opts, err := h.authService.BeginPasskeyLogin(req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"public_key": opts})
+}
+
+// FinishPasskeyLogin verifies the browser's assertion and signs the user in
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req services.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.authService.FinishPasskeyLogin(&req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListPasskeys returns the current user's passkeys
+func (h *AuthHandler) ListPasskeys(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	passkeys, err := h.authService.ListPasskeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// BeginPasskeyRegistration returns WebAuthn creation options for adding a passkey
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	opts, err := h.authService.BeginPasskeyRegistration(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"public_key": opts})
+}
+
+// FinishPasskeyRegistration verifies the new credential and saves it
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	passkey, err := h.authService.FinishPasskeyRegistration(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"passkey": passkey})
+}
+
+// RenamePasskey changes a passkey's label
+func (h *AuthHandler) RenamePasskey(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	passkeyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	passkey, err := h.authService.RenamePasskey(userID, passkeyID, req.Name)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkey": passkey})
+}
+
+// DeletePasskey removes one of the current user's passkeys
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	passkeyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	if err := h.authService.DeletePasskey(userID, passkeyID); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey removed"})
+}
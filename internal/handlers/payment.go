@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/payments"
 	"github.com/ukuvago/angelvault/internal/services"
 )
 
@@ -26,7 +28,11 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 		return
 	}
 
-	payment, clientSecret, err := h.paymentService.CreatePaymentIntent(userID)
+	var req services.CheckoutInput
+	// Body is optional; defaults to the first package with no promo code
+	c.ShouldBindJSON(&req)
+
+	payment, clientSecret, err := h.paymentService.CreatePaymentIntent(userID, req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -37,8 +43,10 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 		"client_secret": clientSecret,
 		"amount":        payment.Amount,
 		"currency":      payment.Currency,
+		"discount":      payment.DiscountAmount,
 		"projects":      payment.ProjectsTotal,
-		"is_demo":       !h.paymentService.IsStripeConfigured(),
+		"status":        payment.Status,
+		"is_demo":       !h.paymentService.IsProviderConfigured(),
 	})
 }
 
@@ -64,7 +72,7 @@ func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
 	var payment interface{}
 	var err error
 
-	if req.DemoMode || !h.paymentService.IsStripeConfigured() {
+	if req.DemoMode || !h.paymentService.IsProviderConfigured() {
 		payment, err = h.paymentService.DemoConfirmPayment(req.PaymentID)
 	} else {
 		payment, err = h.paymentService.ConfirmPayment(req.PaymentID, req.StripePaymentID)
@@ -135,28 +143,149 @@ func (h *PaymentHandler) GetViewedProjects(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"views": views})
 }
 
-// HandleStripeWebhook processes Stripe webhook events
-func (h *PaymentHandler) HandleStripeWebhook(c *gin.Context) {
+// HandleWebhook processes payment provider webhook events
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
 	payload, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 		return
 	}
 
-	signature := c.GetHeader("Stripe-Signature")
+	signature := c.GetHeader(h.paymentService.WebhookSignatureHeader())
 
-	if err := h.paymentService.HandleStripeWebhook(payload, signature); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.paymentService.HandleWebhook(payload, signature); err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Non-2xx makes the provider redeliver the event later
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"received": true})
 }
 
+// RefundPayment refunds an investor's credit purchase (admin)
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var req services.RefundPaymentInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.RefundPayment(adminID, paymentID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment refunded",
+		"payment": payment,
+	})
+}
+
+// GrantCredits comps view credits to an investor (admin)
+func (h *PaymentHandler) GrantCredits(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	investorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req services.CreditAdjustmentInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.GrantCredits(adminID, investorID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Credits granted",
+		"payment": payment.ToResponse(),
+	})
+}
+
+// RevokeCredits removes unused view credits from an investor (admin)
+func (h *PaymentHandler) RevokeCredits(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	investorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req services.CreditAdjustmentInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	balance, err := h.paymentService.RevokeCredits(adminID, investorID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Credits revoked",
+		"balance": balance,
+	})
+}
+
+// GrantComplimentaryUnlock opens a project to an investor without using credits (admin)
+func (h *PaymentHandler) GrantComplimentaryUnlock(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	investorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		ProjectID uuid.UUID `json:"project_id" binding:"required"`
+		Reason    string    `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view, err := h.paymentService.GrantComplimentaryUnlock(adminID, investorID, req.ProjectID, req.Reason, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Project unlocked",
+		"view":    view,
+	})
+}
+
 // GetStripeConfig returns Stripe configuration for frontend
 func (h *PaymentHandler) GetStripeConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"publishable_key": h.paymentService.GetStripePublishableKey(),
-		"is_configured":   h.paymentService.IsStripeConfigured(),
+		"is_configured":   h.paymentService.ProviderName() == payments.ProviderStripe,
+		"provider":        h.paymentService.ProviderName(),
 	})
 }
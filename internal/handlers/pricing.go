@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type PricingHandler struct {
+	pricingService *services.PricingService
+}
+
+func NewPricingHandler(pricingSvc *services.PricingService) *PricingHandler {
+	return &PricingHandler{pricingService: pricingSvc}
+}
+
+// ListPackages returns the credit packages on sale
+func (h *PricingHandler) ListPackages(c *gin.Context) {
+	packages, err := h.pricingService.ListPackages(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve packages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+// GetQuote prices a package with an optional promo code before checkout
+func (h *PricingHandler) GetQuote(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.CheckoutInput
+	// Body is optional
+	c.ShouldBindJSON(&req)
+
+	quote, err := h.pricingService.Quote(userID, req.PackageID, req.Currency, req.PromoCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quote.TotalFormatted = models.FormatCurrencyLocale(quote.Total, quote.Currency, c.GetHeader("Accept-Language"))
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// AdminListPackages returns all credit packages, including inactive ones
+func (h *PricingHandler) AdminListPackages(c *gin.Context) {
+	packages, err := h.pricingService.ListPackages(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve packages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"packages": packages})
+}
+
+// CreatePackage adds a credit package
+func (h *PricingHandler) CreatePackage(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req services.CreditPackageInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pkg, err := h.pricingService.CreatePackage(adminID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Package created",
+		"package": pkg,
+	})
+}
+
+// UpdatePackage edits a credit package
+func (h *PricingHandler) UpdatePackage(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	packageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid package ID"})
+		return
+	}
+
+	var req services.CreditPackageInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pkg, err := h.pricingService.UpdatePackage(adminID, packageID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Package updated",
+		"package": pkg,
+	})
+}
+
+// DeletePackage removes a credit package from sale
+func (h *PricingHandler) DeletePackage(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	packageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid package ID"})
+		return
+	}
+
+	if err := h.pricingService.DeletePackage(adminID, packageID, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Package deleted"})
+}
+
+// ListPromoCodes returns all promo codes
+func (h *PricingHandler) ListPromoCodes(c *gin.Context) {
+	codes, err := h.pricingService.ListPromoCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promo_codes": codes})
+}
+
+// CreatePromoCode adds a promo code
+func (h *PricingHandler) CreatePromoCode(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req services.PromoCodeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo, err := h.pricingService.CreatePromoCode(adminID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Promo code created",
+		"promo_code": promo,
+	})
+}
+
+// UpdatePromoCode edits a promo code
+func (h *PricingHandler) UpdatePromoCode(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	promoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return
+	}
+
+	var req services.PromoCodeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo, err := h.pricingService.UpdatePromoCode(adminID, promoID, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Promo code updated",
+		"promo_code": promo,
+	})
+}
+
+// GetPromoRedemptions lists the payments a promo code was used on
+func (h *PricingHandler) GetPromoRedemptions(c *gin.Context) {
+	promoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return
+	}
+
+	redemptions, err := h.pricingService.GetPromoRedemptions(promoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve redemptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redemptions": redemptions})
+}
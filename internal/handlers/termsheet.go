@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/middleware"
+	"github.com/ukuvago/angelvault/internal/services"
+)
+
+type TermSheetHandler struct {
+	termSheetService *services.TermSheetService
+}
+
+func NewTermSheetHandler(termSheetSvc *services.TermSheetService) *TermSheetHandler {
+	return &TermSheetHandler{termSheetService: termSheetSvc}
+}
+
+// GenerateTermSheet creates a term sheet from an accepted offer
+func (h *TermSheetHandler) GenerateTermSheet(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req services.GenerateTermSheetInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	termSheet, err := h.termSheetService.GenerateTermSheet(userID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Term sheet generated",
+		"term_sheet": termSheet,
+	})
+}
+
+// GetTermSheet returns a single term sheet
+func (h *TermSheetHandler) GetTermSheet(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	termSheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term sheet ID"})
+		return
+	}
+
+	termSheet, err := h.termSheetService.GetTermSheet(userID, termSheetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"term_sheet": termSheet})
+}
+
+// GetTermSheetDocument returns the SAFE document text and hash to review before signing
+func (h *TermSheetHandler) GetTermSheetDocument(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	termSheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term sheet ID"})
+		return
+	}
+
+	content, hash, err := h.termSheetService.GetTermSheetDocument(userID, termSheetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"content":       content,
+		"document_hash": hash,
+	})
+}
+
+// ========================================
+// INVESTOR ENDPOINTS
+// ========================================
+
+// GetInvestorTermSheets returns the investor's term sheets
+func (h *TermSheetHandler) GetInvestorTermSheets(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	termSheets, err := h.termSheetService.GetInvestorTermSheets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term sheets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"term_sheets": termSheets})
+}
+
+// InvestorSign records the investor's signature
+func (h *TermSheetHandler) InvestorSign(c *gin.Context) {
+	h.sign(c, true)
+}
+
+// ========================================
+// DEVELOPER ENDPOINTS
+// ========================================
+
+// GetDeveloperTermSheets returns term sheets on the developer's projects
+func (h *TermSheetHandler) GetDeveloperTermSheets(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	termSheets, err := h.termSheetService.GetDeveloperTermSheets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term sheets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"term_sheets": termSheets})
+}
+
+// FounderSign records the founder's countersignature
+func (h *TermSheetHandler) FounderSign(c *gin.Context) {
+	h.sign(c, false)
+}
+
+func (h *TermSheetHandler) sign(c *gin.Context, asInvestor bool) {
+	userID, _ := middleware.GetUserID(c)
+
+	termSheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term sheet ID"})
+		return
+	}
+
+	var req services.SignTermSheetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sign := h.termSheetService.SignAsFounder
+	if asInvestor {
+		sign = h.termSheetService.SignAsInvestor
+	}
+
+	termSheet, err := sign(userID, termSheetID, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Term sheet signed",
+		"term_sheet": termSheet,
+	})
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer writes each message to an .eml file for local development, where
+// it can be opened in any mail client. Links in the mail (password resets,
+// email verification) work as they would from a real inbox.
+type FileMailer struct {
+	dir  string
+	from Sender
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir string, from Sender) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Name() string {
+	return DriverFile
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.mu.Unlock()
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().UTC().Format("20060102T150405"), seq, recipient)
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o600)
+}
@@ -0,0 +1,172 @@
+// Package mail delivers transactional email. A small Mailer interface lets the
+// app send over SMTP in production, to .eml files in development and to memory
+// in tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/ukuvago/angelvault/internal/config"
+)
+
+// Message is an email to a single recipient with plain text and HTML bodies
+type Message struct {
+	To      string // Address
+	ToName  string // Display name, optional
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	// Name identifies the mailer, e.g. "smtp"
+	Name() string
+	// Send delivers a message, honouring ctx's deadline
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Sender is the From address of outgoing mail
+type Sender struct {
+	Email string
+	Name  string
+}
+
+// Mailer names. MAIL_DRIVER accepts smtp and file; the memory mailer is built
+// directly by tests.
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+// NewMailer builds the configured mailer. It returns nil when mail is disabled,
+// when no driver is set and no SMTP host is configured.
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	from := Sender{Email: cfg.FromEmail, Name: cfg.FromName}
+
+	name := cfg.MailDriver
+	if name == "" && cfg.SMTPHost != "" {
+		name = DriverSMTP
+	}
+
+	switch name {
+	case "":
+		return nil, nil
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp mail driver")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, from), nil
+	case DriverFile:
+		if cfg.IsProduction() {
+			return nil, errors.New("the file mail driver cannot be used in production")
+		}
+		return NewFileMailer(cfg.MailDir, from)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", name)
+	}
+}
+
+// validate rejects messages that cannot be delivered or would smuggle headers
+func (m *Message) validate() error {
+	if _, err := netmail.ParseAddress(m.To); err != nil {
+		return fmt.Errorf("invalid recipient %q", m.To)
+	}
+	if strings.ContainsAny(m.ToName+m.Subject, "\r\n") {
+		return errors.New("header values cannot contain line breaks")
+	}
+	if m.Text == "" && m.HTML == "" {
+		return errors.New("message has no body")
+	}
+	return nil
+}
+
+// Bytes renders the message as a MIME document, multipart/alternative when it
+// has both a text and an HTML body
+func (m *Message) Bytes(from Sender) ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	header("From", (&netmail.Address{Name: from.Name, Address: from.Email}).String())
+	header("To", (&netmail.Address{Name: m.ToName, Address: m.To}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Email))
+	header("MIME-Version", "1.0")
+
+	if m.Text == "" || m.HTML == "" {
+		contentType, body := "text/plain", m.Text
+		if m.Text == "" {
+			contentType, body = "text/html", m.HTML
+		}
+		header("Content-Type", contentType+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", m.Text}, // Least preferred first (RFC 2046)
+		{"text/html", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(fromEmail string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(fromEmail, "@"); at >= 0 {
+		domain = fromEmail[at+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Templates. Each has a text body (name.txt, which also defines the subject)
+// and an HTML body (name.html, rendered inside layout.html).
+const (
+	TemplateVerifyEmail      = "verify_email"
+	TemplatePasswordReset    = "password_reset"
+	TemplateNDASigned        = "nda_signed"
+	TemplateMeetingRequested = "meeting_requested"
+	TemplateMeetingAccepted  = "meeting_accepted"
+	TemplateProjectApproved  = "project_approved"
+	TemplateProjectRejected  = "project_rejected"
+	TemplateCreditsExpiring  = "credits_expiring"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = map[string]emailTemplate{}
+
+var templateFuncs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2 January 2006")
+	},
+	"datetime": func(t time.Time) string {
+		return t.UTC().Format("2 January 2006 15:04 MST")
+	},
+}
+
+// Embedded templates are part of the binary, so a broken one fails at startup
+func init() {
+	layout := htmltemplate.Must(htmltemplate.New("layout.html").
+		Funcs(templateFuncs).
+		Option("missingkey=error").
+		ParseFS(templateFS, "templates/layout.html"))
+
+	for _, name := range []string{
+		TemplateVerifyEmail,
+		TemplatePasswordReset,
+		TemplateNDASigned,
+		TemplateMeetingRequested,
+		TemplateMeetingAccepted,
+		TemplateProjectApproved,
+		TemplateProjectRejected,
+		TemplateCreditsExpiring,
+	} {
+		templates[name] = emailTemplate{
+			text: texttemplate.Must(texttemplate.New(name+".txt").
+				Funcs(templateFuncs).
+				Option("missingkey=error").
+				ParseFS(templateFS, "templates/"+name+".txt")),
+			html: htmltemplate.Must(htmltemplate.Must(layout.Clone()).
+				ParseFS(templateFS, "templates/"+name+".html")),
+		}
+	}
+}
+
+// Render builds a message from a template. The caller sets the recipient.
+func Render(name string, data map[string]interface{}) (*Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s HTML: %w", name, err)
+	}
+
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
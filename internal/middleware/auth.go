@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,18 +17,53 @@ import (
 type contextKey string
 
 const (
-	UserIDKey   contextKey = "user_id"
-	UserRoleKey contextKey = "user_role"
-	UserKey     contextKey = "user"
+	UserIDKey    contextKey = "user_id"
+	UserRoleKey  contextKey = "user_role"
+	UserKey      contextKey = "user"
+	SessionIDKey contextKey = "session_id"
 )
 
 type Claims struct {
-	UserID uuid.UUID       `json:"user_id"`
-	Email  string          `json:"email"`
-	Role   models.UserRole `json:"role"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Email     string          `json:"email"`
+	Role      models.UserRole `json:"role"`
+	SessionID uuid.UUID       `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// authenticate verifies an access token and checks that its session has not been
+// revoked and its user is still active. The role comes from the database so a
+// role change applies without waiting for the token to expire.
+func authenticate(cfg *config.Config, tokenString string) (*Claims, models.UserRole, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || claims.SessionID == uuid.Nil {
+		return nil, "", errors.New("invalid or expired token")
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Select("id", "role", "is_active").First(&user, "id = ?", claims.UserID).Error; err != nil {
+		return nil, "", errors.New("invalid or expired token")
+	}
+	if !user.IsActive {
+		return nil, "", errors.New("account is disabled")
+	}
+
+	var live int64
+	db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", claims.SessionID, user.ID, time.Now()).
+		Count(&live)
+	if live == 0 {
+		return nil, "", errors.New("session has been signed out")
+	}
+
+	return claims, user.Role, nil
+}
+
 // AuthMiddleware validates JWT tokens
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -44,20 +81,17 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		claims, role, err := authenticate(cfg, tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
 		// Store claims in context
 		c.Set(string(UserIDKey), claims.UserID)
-		c.Set(string(UserRoleKey), claims.Role)
+		c.Set(string(UserRoleKey), role)
+		c.Set(string(SessionIDKey), claims.SessionID)
 
 		c.Next()
 	}
@@ -78,14 +112,10 @@ func OptionalAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err == nil && token.Valid {
+		if claims, role, err := authenticate(cfg, tokenString); err == nil {
 			c.Set(string(UserIDKey), claims.UserID)
-			c.Set(string(UserRoleKey), claims.Role)
+			c.Set(string(UserRoleKey), role)
+			c.Set(string(SessionIDKey), claims.SessionID)
 		}
 
 		c.Next()
@@ -160,6 +190,14 @@ func GetUserID(c *gin.Context) (uuid.UUID, bool) {
 	return val.(uuid.UUID), true
 }
 
+func GetSessionID(c *gin.Context) (uuid.UUID, bool) {
+	val, exists := c.Get(string(SessionIDKey))
+	if !exists {
+		return uuid.Nil, false
+	}
+	return val.(uuid.UUID), true
+}
+
 func GetUserRole(c *gin.Context) (models.UserRole, bool) {
 	val, exists := c.Get(string(UserRoleKey))
 	if !exists {
@@ -19,6 +19,10 @@ const (
 	AuditActionUserPasswordChanged AuditAction = "user.password_changed"
 	AuditActionUserDeactivated    AuditAction = "user.deactivated"
 	AuditActionUserReactivated    AuditAction = "user.reactivated"
+	AuditActionUserTwoFactorReset AuditAction = "user.two_factor_reset"
+	AuditActionUserSessionsRevoked AuditAction = "user.sessions_revoked"
+	AuditActionUserIdentityLinked  AuditAction = "user.identity_linked"
+	AuditActionUserIdentityUnlinked AuditAction = "user.identity_unlinked"
 	
 	// Admin user actions
 	AuditActionAdminCreated       AuditAction = "admin.created"
@@ -34,6 +38,7 @@ const (
 	AuditActionProjectDeleted     AuditAction = "project.deleted"
 	AuditActionProjectViewed      AuditAction = "project.viewed"
 	AuditActionProjectUnlocked    AuditAction = "project.unlocked"
+	AuditActionProjectFunded      AuditAction = "project.funded"
 	
 	// Investor actions
 	AuditActionInvestorAccess     AuditAction = "investor.access"
@@ -44,18 +49,53 @@ const (
 	AuditActionPaymentCompleted   AuditAction = "payment.completed"
 	AuditActionPaymentFailed      AuditAction = "payment.failed"
 	AuditActionPaymentRefunded    AuditAction = "payment.refunded"
+	AuditActionPaymentDisputed    AuditAction = "payment.disputed"
 	AuditActionCreditsUsed        AuditAction = "payment.credits_used"
 	AuditActionCreditsExpired     AuditAction = "payment.credits_expired"
+	AuditActionCreditsGranted     AuditAction = "payment.credits_granted"
+	AuditActionCreditsRevoked     AuditAction = "payment.credits_revoked"
+	AuditActionProjectComplimentary AuditAction = "project.complimentary_unlock"
+	
+	// Pricing actions
+	AuditActionCreditPackageCreated AuditAction = "credit_package.created"
+	AuditActionCreditPackageUpdated AuditAction = "credit_package.updated"
+	AuditActionCreditPackageDeleted AuditAction = "credit_package.deleted"
+	AuditActionPromoCodeCreated     AuditAction = "promo_code.created"
+	AuditActionPromoCodeUpdated     AuditAction = "promo_code.updated"
+	AuditActionExchangeRateSet      AuditAction = "exchange_rate.set"
 	
 	// NDA actions
 	AuditActionNDAMasterSigned    AuditAction = "nda.master_signed"
 	AuditActionNDAAddendumSigned  AuditAction = "nda.addendum_signed"
+	AuditActionNDAOrganizationSigned AuditAction = "nda.organization_signed"
+	
+	// Organization actions
+	AuditActionOrganizationCreated     AuditAction = "organization.created"
+	AuditActionOrgMemberAdded          AuditAction = "organization.member_added"
+	AuditActionOrgMemberRemoved        AuditAction = "organization.member_removed"
+	AuditActionOrgMemberRoleChanged    AuditAction = "organization.member_role_changed"
+	AuditActionOrgSignatoryChanged     AuditAction = "organization.signatory_changed"
 	
 	// Offer actions
 	AuditActionOfferCreated       AuditAction = "offer.created"
 	AuditActionOfferAccepted      AuditAction = "offer.accepted"
 	AuditActionOfferRejected      AuditAction = "offer.rejected"
 	AuditActionOfferWithdrawn     AuditAction = "offer.withdrawn"
+	AuditActionOfferCountered     AuditAction = "offer.countered"
+	
+	// Term sheet actions
+	AuditActionTermSheetGenerated      AuditAction = "term_sheet.generated"
+	AuditActionTermSheetInvestorSigned AuditAction = "term_sheet.investor_signed"
+	AuditActionTermSheetFounderSigned  AuditAction = "term_sheet.founder_signed"
+	AuditActionTermSheetExpired        AuditAction = "term_sheet.expired"
+	AuditActionTermSheetFundsReceived  AuditAction = "term_sheet.funds_received"
+	
+	// Commission actions
+	AuditActionCommissionInvoiced      AuditAction = "commission.invoiced"
+	AuditActionCommissionSent          AuditAction = "commission.sent"
+	AuditActionCommissionPaid          AuditAction = "commission.paid"
+	AuditActionCommissionOverdue       AuditAction = "commission.overdue"
+	AuditActionCommissionCancelled     AuditAction = "commission.cancelled"
 	
 	// Category actions
 	AuditActionCategoryCreated    AuditAction = "category.created"
@@ -172,10 +212,13 @@ type DashboardStats struct {
 	
 	// Payment stats
 	TotalPayments     int64 `json:"total_payments"`
-	TotalRevenue      int64 `json:"total_revenue"` // cents
+	TotalRevenue      int64 `json:"total_revenue"` // Minor units of RevenueCurrency
 	RevenueToday      int64 `json:"revenue_today"`
 	RevenueThisWeek   int64 `json:"revenue_this_week"`
 	RevenueThisMonth  int64 `json:"revenue_this_month"`
+	RevenueCurrency   string `json:"revenue_currency"`
+	MissingRates      []string `json:"missing_rates"` // Currencies with no FX rate, left out of the revenue totals
+	RevenueComplete   bool   `json:"revenue_complete"`
 	
 	// Activity stats
 	TotalProjectViews int64 `json:"total_project_views"`
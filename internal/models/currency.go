@@ -0,0 +1,229 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CurrencyInfo describes an ISO 4217 currency. Amounts are always stored as
+// integers in the currency's minor unit (cents for USD, yen for JPY, fils for KWD).
+type CurrencyInfo struct {
+	Code       string `json:"code"` // Lower-case, as used by payment processors
+	Name       string `json:"name"`
+	Symbol     string `json:"symbol"`
+	MinorUnits int    `json:"minor_units"` // Digits after the decimal separator
+}
+
+// currencies are the ISO 4217 currencies the platform can price and report in
+var currencies = map[string]CurrencyInfo{
+	"usd": {"usd", "US Dollar", "$", 2},
+	"eur": {"eur", "Euro", "€", 2},
+	"gbp": {"gbp", "Pound Sterling", "£", 2},
+	"zar": {"zar", "South African Rand", "R", 2},
+	"chf": {"chf", "Swiss Franc", "CHF", 2},
+	"cad": {"cad", "Canadian Dollar", "CA$", 2},
+	"aud": {"aud", "Australian Dollar", "A$", 2},
+	"nzd": {"nzd", "New Zealand Dollar", "NZ$", 2},
+	"sek": {"sek", "Swedish Krona", "kr", 2},
+	"nok": {"nok", "Norwegian Krone", "kr", 2},
+	"dkk": {"dkk", "Danish Krone", "kr.", 2},
+	"pln": {"pln", "Polish Zloty", "zł", 2},
+	"inr": {"inr", "Indian Rupee", "₹", 2},
+	"kes": {"kes", "Kenyan Shilling", "KSh", 2},
+	"ngn": {"ngn", "Nigerian Naira", "₦", 2},
+	"jpy": {"jpy", "Japanese Yen", "¥", 0},
+	"krw": {"krw", "South Korean Won", "₩", 0},
+	"bhd": {"bhd", "Bahraini Dinar", "BD", 3},
+	"kwd": {"kwd", "Kuwaiti Dinar", "KD", 3},
+}
+
+// LookupCurrency returns the ISO 4217 details of a currency code in any case
+func LookupCurrency(code string) (CurrencyInfo, bool) {
+	info, ok := currencies[strings.ToLower(strings.TrimSpace(code))]
+	return info, ok
+}
+
+// IsSupportedCurrency reports whether amounts can be priced in the currency
+func IsSupportedCurrency(code string) bool {
+	_, ok := LookupCurrency(code)
+	return ok
+}
+
+// SupportedCurrencies lists the supported currencies by code
+func SupportedCurrencies() []CurrencyInfo {
+	list := make([]CurrencyInfo, 0, len(currencies))
+	for _, info := range currencies {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// currencyInfo falls back to two minor units and the upper-case code as the
+// symbol for currencies outside the table
+func currencyInfo(code string) CurrencyInfo {
+	if info, ok := LookupCurrency(code); ok {
+		return info
+	}
+	upper := strings.ToUpper(code)
+	return CurrencyInfo{Code: strings.ToLower(code), Name: upper, Symbol: upper, MinorUnits: 2}
+}
+
+// ConvertAmount converts an amount in from's minor units to to's minor units at
+// rate, the number of major units of to per major unit of from
+func ConvertAmount(amount int64, from, to string, rate float64) int64 {
+	major := float64(amount) / math.Pow10(currencyInfo(from).MinorUnits)
+	return int64(math.Round(major * rate * math.Pow10(currencyInfo(to).MinorUnits)))
+}
+
+// localeFormat is how a locale writes money
+type localeFormat struct {
+	group       string
+	decimal     string
+	symbolAfter bool // "1.234,56 €" rather than "€1,234.56"
+	space       bool // Space between symbol and number
+}
+
+// DefaultLocale is used for documents and when a locale is not recognised
+const DefaultLocale = "en"
+
+var localeFormats = map[string]localeFormat{
+	"en":    {",", ".", false, false},
+	"en-za": {" ", ",", false, false},
+	"de":    {".", ",", true, true},
+	"de-ch": {"’", ".", false, true},
+	"fr":    {" ", ",", true, true},
+	"es":    {".", ",", true, true},
+	"it":    {".", ",", true, true},
+	"pt":    {" ", ",", true, true},
+	"nl":    {".", ",", false, true},
+	"sv":    {" ", ",", true, true},
+	"pl":    {" ", ",", true, true},
+}
+
+// resolveLocale picks the format for a locale tag or Accept-Language header,
+// trying the full tag before its language
+func resolveLocale(locale string) localeFormat {
+	tag := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(tag, ",;"); i >= 0 {
+		tag = tag[:i]
+	}
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+
+	if format, ok := localeFormats[tag]; ok {
+		return format
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		if format, ok := localeFormats[tag[:i]]; ok {
+			return format
+		}
+	}
+	return localeFormats[DefaultLocale]
+}
+
+// FormatCurrency formats an amount in minor units for display in the default
+// locale, e.g. "$1,234.56", "£500.00" or "¥12,000"
+func FormatCurrency(amount int64, currency string) string {
+	return FormatCurrencyLocale(amount, currency, DefaultLocale)
+}
+
+// FormatCurrencyLocale formats an amount in minor units using the currency's
+// ISO 4217 decimal places and the locale's separators and symbol placement.
+// locale may be a tag such as "de-DE" or a whole Accept-Language header.
+func FormatCurrencyLocale(amount int64, currency, locale string) string {
+	info := currencyInfo(currency)
+	format := resolveLocale(locale)
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	scale := int64(math.Pow10(info.MinorUnits))
+	number := groupDigits(strconv.FormatInt(amount/scale, 10), format.group)
+	if info.MinorUnits > 0 {
+		fraction := strconv.FormatInt(amount%scale, 10)
+		number += format.decimal + strings.Repeat("0", info.MinorUnits-len(fraction)) + fraction
+	}
+
+	sep := ""
+	if format.space {
+		sep = " "
+	}
+	if format.symbolAfter {
+		return sign + number + sep + info.Symbol
+	}
+	return sign + info.Symbol + sep + number
+}
+
+// groupDigits inserts a thousands separator into a run of digits
+func groupDigits(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// ExchangeRate is an admin-entered FX rate used to normalise mixed-currency
+// revenue into the reporting currency. Rates are never edited; a new rate with a
+// later EffectiveAt supersedes the previous one.
+type ExchangeRate struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Currency          string    `gorm:"type:varchar(3);not null;index:idx_exchange_rate_pair" json:"currency"`
+	ReportingCurrency string    `gorm:"type:varchar(3);not null;index:idx_exchange_rate_pair" json:"reporting_currency"`
+	Rate              float64   `gorm:"not null" json:"rate"` // Reporting-currency units per unit of Currency
+	EffectiveAt       time.Time `gorm:"not null;index" json:"effective_at"`
+	Note              string    `json:"note,omitempty"`
+	CreatedByID       uuid.UUID `gorm:"type:uuid" json:"created_by_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func (r *ExchangeRate) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// RevenueLine is one source of revenue in one currency
+type RevenueLine struct {
+	Source       string   `json:"source"` // view_credits, commissions
+	Currency     string   `json:"currency"`
+	Count        int64    `json:"count"`
+	Gross        int64    `json:"gross"`
+	Refunded     int64    `json:"refunded"`
+	Net          int64    `json:"net"`
+	Rate         *float64 `json:"rate,omitempty"`          // Nil when no FX rate is on file
+	NetReporting *int64   `json:"net_reporting,omitempty"` // Net in the reporting currency
+}
+
+// RevenueReport normalises revenue for a period into the reporting currency
+type RevenueReport struct {
+	From              time.Time     `json:"from"`
+	To                time.Time     `json:"to"`
+	ReportingCurrency string        `json:"reporting_currency"`
+	RatesAsOf         time.Time     `json:"rates_as_of"`
+	Lines             []RevenueLine `json:"lines"`
+	NetTotal          int64         `json:"net_total"` // Reporting currency, converted lines only
+	NetTotalFormatted string        `json:"net_total_formatted"`
+	MissingRates      []string      `json:"missing_rates"` // Currencies left out of NetTotal
+	IsComplete        bool          `json:"is_complete"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LedgerEntryType classifies a movement of view credits
+type LedgerEntryType string
+
+const (
+	LedgerEntryPurchase       LedgerEntryType = "purchase"        // Credits bought (+)
+	LedgerEntryUnlock         LedgerEntryType = "unlock"          // Credit spent on a project (-1)
+	LedgerEntryRefund         LedgerEntryType = "refund"          // Unused credits withdrawn by a refund (-)
+	LedgerEntryExpiry         LedgerEntryType = "expiry"          // Unused credits lapsed (-)
+	LedgerEntryDispute        LedgerEntryType = "dispute"         // Credits frozen by a chargeback (-)
+	LedgerEntryGrant          LedgerEntryType = "grant"           // Credits added by an admin (+)
+	LedgerEntryRevoke         LedgerEntryType = "revoke"          // Credits removed by an admin (-)
+	LedgerEntryOpeningBalance LedgerEntryType = "opening_balance" // Balance carried over when the ledger was introduced
+)
+
+// ErrLedgerImmutable is returned when code tries to change a posted ledger entry
+var ErrLedgerImmutable = errors.New("credit ledger entries are immutable")
+
+// CreditLedgerEntry is an immutable movement of view credits on an investor's
+// account. Every entry belongs to the payment (credit lot) it draws on, so an
+// investor's balance is the sum of their entries and each payment's unused
+// credits are the sum of its entries.
+type CreditLedgerEntry struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	InvestorID  uuid.UUID       `gorm:"type:uuid;not null;index" json:"investor_id"`
+	PaymentID   *uuid.UUID      `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	ProjectID   *uuid.UUID      `gorm:"type:uuid" json:"project_id,omitempty"`
+	EntryType   LedgerEntryType `gorm:"type:varchar(30);not null;index" json:"entry_type"`
+	Credits     int             `gorm:"not null" json:"credits"` // Signed: positive adds, negative removes
+	Description string          `json:"description,omitempty"`
+	CreatedByID *uuid.UUID      `gorm:"type:uuid" json:"created_by_id,omitempty"` // Admin for manual entries
+	CreatedAt   time.Time       `gorm:"index" json:"created_at"`
+
+	// Relations
+	Payment *Payment `gorm:"foreignKey:PaymentID" json:"-"`
+	Project *Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
+
+func (e *CreditLedgerEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *CreditLedgerEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+func (e *CreditLedgerEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// CreditSummary is an investor's spendable balance as derived from the ledger
+type CreditSummary struct {
+	Credited int `json:"credited"` // Credits added to lots that are still live
+	Used     int `json:"used"`     // Credits spent on unlocks from those lots
+	Balance  int `json:"balance"`  // Credits still available
+}
+
+// LedgerDrift is a payment whose ledger balance disagrees with its ProjectsRemaining
+type LedgerDrift struct {
+	PaymentID         uuid.UUID     `json:"payment_id"`
+	InvestorID        uuid.UUID     `json:"investor_id"`
+	InvestorEmail     string        `json:"investor_email"`
+	Status            PaymentStatus `json:"status"`
+	ProjectsRemaining int           `json:"projects_remaining"`
+	ExpectedBalance   int           `json:"expected_balance"` // What the ledger should show for this payment
+	LedgerBalance     int           `json:"ledger_balance"`
+	Drift             int           `json:"drift"` // LedgerBalance - ExpectedBalance
+}
+
+// LedgerReconciliation compares the credit ledger with the payments table
+type LedgerReconciliation struct {
+	GeneratedAt        time.Time     `json:"generated_at"`
+	PaymentsChecked    int64         `json:"payments_checked"`
+	LedgerBalance      int64         `json:"ledger_balance"`       // Sum of all entries
+	PaymentsRemaining  int64         `json:"payments_remaining"`   // Sum of ProjectsRemaining on completed payments
+	UnlinkedEntries    int64         `json:"unlinked_entries"`     // Entries with no payment
+	UnlocksWithoutView int64         `json:"unlocks_without_view"` // Unlock entries with no matching ProjectView
+	Drifts             []LedgerDrift `json:"drifts"`
+	IsBalanced         bool          `json:"is_balanced"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// All lists every persisted model, in migration order
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Session{},
+		&RecoveryCode{},
+		&TwoFactorChallenge{},
+		&Passkey{},
+		&PasskeyChallenge{},
+		&OAuthState{},
+		&LoginCode{},
+		&UserIdentity{},
+		&InvestorProfile{},
+		&Category{},
+		&Project{},
+		&TeamMember{},
+		&ProjectImage{},
+		&ProjectReadiness{},
+		&NDA{},
+		&ProjectNDAConfig{},
+		&ProjectNDASignature{},
+		&Payment{},
+		&ProjectView{},
+		&StripeWebhookEvent{},
+		&CreditPackage{},
+		&CreditPackagePrice{},
+		&PromoCode{},
+		&PromoRedemption{},
+		&CreditLedgerEntry{},
+		&InvestmentOffer{},
+		&OfferRevision{},
+		&TermSheet{},
+		&PlatformCommission{},
+		&InvoiceCounter{},
+		&ExchangeRate{},
+		&Organization{},
+		&OrganizationMember{},
+		&MeetingRequest{},
+		&Message{},
+		&AuditLog{},
+		&InvestorAccessLog{},
+		&ProjectViewLog{},
+	}
+}
@@ -15,6 +15,9 @@ type NDA struct {
 	InvestorID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"investor_id"`
 	Version       string         `gorm:"not null;default:'1.0'" json:"version"`
 	
+	// Set when the signatory signed on behalf of an organisation; covers all its members
+	OrganizationID *uuid.UUID    `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	
 	// Signature Info
 	SignedName    string         `gorm:"not null" json:"signed_name"`
 	SignatureData string         `gorm:"type:text;not null" json:"-"` // Base64 signature image
@@ -111,6 +114,7 @@ type NDAStatusResponse struct {
 	MasterNDAValid   bool       `json:"master_nda_valid"`
 	MasterNDAExpires *time.Time `json:"master_nda_expires,omitempty"`
 	SignedAt         *time.Time `json:"signed_at,omitempty"`
+	OrganizationID   *uuid.UUID `json:"organization_id,omitempty"` // Set when covered by the organisation's NDA
 }
 
 // Project NDA status for viewing a project
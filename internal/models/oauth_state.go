@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthState is an OAuth sign-in in progress. The state parameter sent to the
+// provider is stored hashed, alongside a hash of a nonce kept in a cookie in the
+// browser that started the sign-in, so a callback is only accepted once and
+// only in that browser.
+type OAuthState struct {
+	ID           uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	StateHash    string       `gorm:"not null;uniqueIndex" json:"-"`
+	BindingHash  string       `gorm:"not null" json:"-"`
+	Provider     AuthProvider `gorm:"type:varchar(20);not null" json:"provider"`
+	Role         UserRole     `gorm:"type:varchar(20);not null" json:"role"`   // Role for a new account
+	CodeVerifier string       `json:"-"`                                       // PKCE verifier, for providers that support it
+	Nonce        string       `json:"-"`                                       // OIDC or Apple nonce the ID token must carry
+	LinkUserID   *uuid.UUID   `gorm:"type:uuid" json:"link_user_id,omitempty"` // Set when linking to a signed-in user
+	ExpiresAt    time.Time    `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+func (s *OAuthState) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,7 +27,7 @@ type InvestmentOffer struct {
 	MeetingRequestID *uuid.UUID    `gorm:"type:uuid;index" json:"meeting_request_id,omitempty"`
 	
 	// Offer Details
-	Amount          int64          `gorm:"not null" json:"amount"` // Amount in cents
+	Amount          int64          `gorm:"not null" json:"amount"` // Amount in minor units of Currency
 	Currency        string         `gorm:"default:'usd'" json:"currency"`
 	EquityRequested float64        `json:"equity_requested,omitempty"`
 	ValuationCap    int64          `json:"valuation_cap,omitempty"`
@@ -44,6 +45,11 @@ type InvestmentOffer struct {
 	Status          OfferStatus    `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	ResponseMessage string         `gorm:"type:text" json:"response_message,omitempty"`
 	
+	// Negotiation (term fields above always mirror the current revision)
+	CurrentRevision  int           `gorm:"not null;default:1" json:"current_revision"`
+	LastRevisedBy    UserRole      `gorm:"type:varchar(20)" json:"last_revised_by,omitempty"`
+	AcceptedRevisionID *uuid.UUID  `gorm:"type:uuid" json:"accepted_revision_id,omitempty"`
+	
 	// Timestamps
 	ExpiresAt       *time.Time     `json:"expires_at,omitempty"`
 	RespondedAt     *time.Time     `json:"responded_at,omitempty"`
@@ -56,6 +62,7 @@ type InvestmentOffer struct {
 	Project         *Project       `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 	TermSheet       *TermSheet     `gorm:"foreignKey:OfferID" json:"term_sheet,omitempty"`
 	MeetingRequest  *MeetingRequest `gorm:"foreignKey:MeetingRequestID" json:"meeting_request,omitempty"`
+	Revisions       []OfferRevision `gorm:"foreignKey:OfferID" json:"revisions,omitempty"`
 }
 
 func (o *InvestmentOffer) BeforeCreate(tx *gorm.DB) error {
@@ -79,6 +86,69 @@ func (o *InvestmentOffer) CanRespond() bool {
 	return true
 }
 
+// AwaitingResponseFrom returns the party expected to respond to the current revision
+func (o *InvestmentOffer) AwaitingResponseFrom() UserRole {
+	if o.LastRevisedBy == RoleDeveloper {
+		return RoleInvestor
+	}
+	return RoleDeveloper
+}
+
+// NewTermSheet builds a draft term sheet from the accepted revision's terms
+func (o *InvestmentOffer) NewTermSheet(revision *OfferRevision) *TermSheet {
+	ts := &TermSheet{
+		OfferID:          o.ID,
+		InvestmentAmount: o.Amount,
+		Currency:         o.Currency,
+		ValuationCap:     o.ValuationCap,
+		DiscountRate:     o.DiscountRate,
+		HasMFN:           o.HasMFN,
+		ProRataRights:    o.ProRataRights,
+		Status:           TermSheetStatusDraft,
+	}
+	if revision != nil {
+		ts.RevisionID = &revision.ID
+		ts.InvestmentAmount = revision.Amount
+		ts.ValuationCap = revision.ValuationCap
+		ts.DiscountRate = revision.DiscountRate
+		ts.HasMFN = revision.HasMFN
+		ts.ProRataRights = revision.ProRataRights
+	}
+	return ts
+}
+
+// OfferRevision is one proposed set of terms in an offer negotiation.
+// Version 1 is the investor's original offer; each counter-offer adds a new version.
+type OfferRevision struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OfferID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_offer_revision_version" json:"offer_id"`
+	Version        int       `gorm:"not null;uniqueIndex:idx_offer_revision_version" json:"version"`
+	
+	// Who proposed these terms
+	ProposedByID   uuid.UUID `gorm:"type:uuid;not null" json:"proposed_by_id"`
+	ProposedByRole UserRole  `gorm:"type:varchar(20);not null" json:"proposed_by_role"`
+	
+	// Proposed Terms
+	Amount         int64     `gorm:"not null" json:"amount"` // Amount in minor units of Currency
+	ValuationCap   int64     `json:"valuation_cap,omitempty"`
+	DiscountRate   float64   `json:"discount_rate,omitempty"`
+	HasMFN         bool      `gorm:"default:false" json:"has_mfn"`
+	ProRataRights  bool      `gorm:"default:false" json:"pro_rata_rights"`
+	
+	Message        string    `gorm:"type:text" json:"message,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	
+	// Relations
+	ProposedBy     *User     `gorm:"foreignKey:ProposedByID" json:"proposed_by,omitempty"`
+}
+
+func (r *OfferRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
 // TermSheet represents the formal SAFE note investment agreement
 type TermSheetStatus string
 
@@ -92,12 +162,29 @@ const (
 	TermSheetStatusCancelled       TermSheetStatus = "cancelled"
 )
 
+// SAFE types supported for term sheets
+const (
+	SAFETypePostMoney = "post_money"
+	SAFETypePreMoney  = "pre_money"
+	SAFETypeMFN       = "mfn"
+)
+
+// IsValidSAFEType reports whether the given SAFE type is supported
+func IsValidSAFEType(safeType string) bool {
+	switch safeType {
+	case SAFETypePostMoney, SAFETypePreMoney, SAFETypeMFN:
+		return true
+	}
+	return false
+}
+
 type TermSheet struct {
 	ID                  uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	OfferID             uuid.UUID       `gorm:"type:uuid;uniqueIndex;not null" json:"offer_id"`
+	RevisionID          *uuid.UUID      `gorm:"type:uuid" json:"revision_id,omitempty"` // Accepted offer revision
 	
 	// SAFE Note Terms
-	InvestmentAmount    int64           `gorm:"not null" json:"investment_amount"` // Amount in cents
+	InvestmentAmount    int64           `gorm:"not null" json:"investment_amount"` // Amount in minor units of Currency
 	Currency            string          `gorm:"default:'usd'" json:"currency"`
 	ValuationCap        int64           `json:"valuation_cap,omitempty"`
 	DiscountRate        float64         `json:"discount_rate,omitempty"` // e.g., 0.20 for 20%
@@ -107,6 +194,7 @@ type TermSheet struct {
 	// Document
 	DocumentURL         string          `json:"document_url,omitempty"`
 	DocumentHash        string          `json:"document_hash,omitempty"`
+	DocumentContent     string          `gorm:"type:text" json:"-"` // Exact bytes both parties sign
 	SAFEType            string          `gorm:"default:'post_money'" json:"safe_type"` // post_money, pre_money, mfn
 	
 	// Status
@@ -117,12 +205,14 @@ type TermSheet struct {
 	InvestorSignature   string          `gorm:"type:text" json:"-"`
 	InvestorSignedAt    *time.Time      `json:"investor_signed_at,omitempty"`
 	InvestorIP          string          `json:"-"`
+	InvestorUserAgent   string          `json:"-"`
 	
 	// Founder Signature
 	FounderSignedName   string          `json:"founder_signed_name,omitempty"`
 	FounderSignature    string          `gorm:"type:text" json:"-"`
 	FounderSignedAt     *time.Time      `json:"founder_signed_at,omitempty"`
 	FounderIP           string          `json:"-"`
+	FounderUserAgent    string          `json:"-"`
 	
 	// Funds Transfer Tracking
 	FundsReceivedAt     *time.Time      `json:"funds_received_at,omitempty"`
@@ -130,7 +220,7 @@ type TermSheet struct {
 	BankReference       string          `json:"bank_reference,omitempty"`
 	
 	// Commission Tracking (for platform revenue)
-	CommissionRate      float64         `gorm:"default:0.02" json:"commission_rate"` // 2% default
+	CommissionRate      float64         `gorm:"not null;default:0" json:"commission_rate"` // Set from Config.CommissionRate
 	CommissionAmount    int64           `json:"commission_amount"` // Calculated: InvestmentAmount * CommissionRate
 	CommissionStatus    string          `gorm:"default:'pending'" json:"commission_status"` // pending, invoiced, paid
 	CommissionInvoiceID *uuid.UUID      `gorm:"type:uuid" json:"commission_invoice_id,omitempty"`
@@ -172,6 +262,23 @@ func (t *TermSheet) NeedsFounderSignature() bool {
 	return t.InvestorSignedAt != nil && t.FounderSignedAt == nil
 }
 
+// IsAwaitingSignature reports whether the term sheet is still collecting signatures
+func (t *TermSheet) IsAwaitingSignature() bool {
+	switch t.Status {
+	case TermSheetStatusDraft, TermSheetStatusPendingInvestor, TermSheetStatusPendingFounder:
+		return true
+	}
+	return false
+}
+
+// IsExpired reports whether an unsigned term sheet has passed its signing deadline
+func (t *TermSheet) IsExpired() bool {
+	if t.Status == TermSheetStatusExpired {
+		return true
+	}
+	return t.IsAwaitingSignature() && t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
 func (t *TermSheet) IsFundsReceived() bool {
 	return t.FundsReceivedAt != nil
 }
@@ -181,6 +288,22 @@ func (t *TermSheet) CommissionDue() string {
 	return FormatCurrency(t.CommissionAmount, t.Currency)
 }
 
+// Term sheet commission statuses
+const (
+	CommissionStatusPending  = "pending"
+	CommissionStatusInvoiced = "invoiced"
+	CommissionStatusPaid     = "paid"
+)
+
+// Commission invoice statuses
+const (
+	InvoiceStatusDraft     = "draft"
+	InvoiceStatusSent      = "sent"
+	InvoiceStatusPaid      = "paid"
+	InvoiceStatusOverdue   = "overdue"
+	InvoiceStatusCancelled = "cancelled"
+)
+
 // PlatformCommission represents a commission invoice
 type PlatformCommission struct {
 	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -188,7 +311,7 @@ type PlatformCommission struct {
 	
 	// Invoice Details
 	InvoiceNumber     string     `gorm:"uniqueIndex" json:"invoice_number"`
-	Amount            int64      `gorm:"not null" json:"amount"` // Commission amount in cents
+	Amount            int64      `gorm:"not null" json:"amount"` // Commission amount in minor units
 	Currency          string     `gorm:"default:'usd'" json:"currency"`
 	
 	// Who to invoice (developer/startup)
@@ -229,6 +352,22 @@ func (pc *PlatformCommission) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// FormattedAmount returns the invoice amount in formatted currency
+func (pc *PlatformCommission) FormattedAmount() string {
+	return FormatCurrency(pc.Amount, pc.Currency)
+}
+
+// InvoiceCounter tracks the last issued invoice number per year
+type InvoiceCounter struct {
+	Year       int `gorm:"primary_key;autoIncrement:false" json:"year"`
+	LastNumber int `gorm:"not null;default:0" json:"last_number"`
+}
+
+// FormatInvoiceNumber builds a sequential invoice number, e.g. AV-2025-00042
+func FormatInvoiceNumber(year, number int) string {
+	return fmt.Sprintf("AV-%d-%05d", year, number)
+}
+
 // OfferResponse for API
 type OfferResponse struct {
 	ID              uuid.UUID   `json:"id"`
@@ -238,10 +377,21 @@ type OfferResponse struct {
 	InvestorName    string      `json:"investor_name,omitempty"`
 	Amount          int64       `json:"amount"`
 	AmountFormatted string      `json:"amount_formatted"`
+	Currency        string      `json:"currency"`
+	ValuationCap    int64       `json:"valuation_cap,omitempty"`
+	DiscountRate    float64     `json:"discount_rate,omitempty"`
+	HasMFN          bool        `json:"has_mfn"`
+	ProRataRights   bool        `json:"pro_rata_rights"`
 	Status          OfferStatus `json:"status"`
 	Message         string      `json:"message,omitempty"`
+	ResponseMessage string      `json:"response_message,omitempty"`
+	MeetingRequestID *uuid.UUID `json:"meeting_request_id,omitempty"`
+	CurrentRevision int         `json:"current_revision"`
+	AwaitingResponseFrom UserRole `json:"awaiting_response_from,omitempty"`
 	HasTermSheet    bool        `json:"has_term_sheet"`
 	TermSheetStatus string      `json:"term_sheet_status,omitempty"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"`
+	RespondedAt     *time.Time  `json:"responded_at,omitempty"`
 	CreatedAt       time.Time   `json:"created_at"`
 }
 
@@ -252,9 +402,19 @@ func (o *InvestmentOffer) ToResponse() OfferResponse {
 		InvestorID:      o.InvestorID,
 		Amount:          o.Amount,
 		AmountFormatted: FormatCurrency(o.Amount, o.Currency),
+		Currency:        o.Currency,
+		ValuationCap:    o.ValuationCap,
+		DiscountRate:    o.DiscountRate,
+		HasMFN:          o.HasMFN,
+		ProRataRights:   o.ProRataRights,
 		Status:          o.Status,
 		Message:         o.Message,
+		ResponseMessage: o.ResponseMessage,
+		MeetingRequestID: o.MeetingRequestID,
+		CurrentRevision: o.CurrentRevision,
 		HasTermSheet:    o.TermSheet != nil,
+		ExpiresAt:       o.ExpiresAt,
+		RespondedAt:     o.RespondedAt,
 		CreatedAt:       o.CreatedAt,
 	}
 	
@@ -267,6 +427,9 @@ func (o *InvestmentOffer) ToResponse() OfferResponse {
 	if o.TermSheet != nil {
 		resp.TermSheetStatus = string(o.TermSheet.Status)
 	}
+	if o.CanRespond() {
+		resp.AwaitingResponseFrom = o.AwaitingResponseFrom()
+	}
 	
 	return resp
 }
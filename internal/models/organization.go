@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationRole is a member's role in an investor organisation
+type OrganizationRole string
+
+const (
+	OrgRoleOwner   OrganizationRole = "owner"   // Manages members and the signatory; buys credits
+	OrgRolePartner OrganizationRole = "partner" // Buys credits and unlocks projects
+	OrgRoleAnalyst OrganizationRole = "analyst" // Unlocks projects from the pool
+)
+
+// IsValidOrganizationRole reports whether role is a known member role
+func IsValidOrganizationRole(role OrganizationRole) bool {
+	switch role {
+	case OrgRoleOwner, OrgRolePartner, OrgRoleAnalyst:
+		return true
+	}
+	return false
+}
+
+// Organization groups the partners of an institutional investor. Members spend
+// from a shared credit pool, see every project any member has unlocked, and are
+// covered by one master NDA signed by the organisation's authorised signatory.
+type Organization struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string     `gorm:"not null" json:"name"`
+	SignatoryID *uuid.UUID `gorm:"type:uuid" json:"signatory_id,omitempty"` // Member authorised to sign the master NDA
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Signatory *User                `gorm:"foreignKey:SignatoryID" json:"signatory,omitempty"`
+	Members   []OrganizationMember `gorm:"foreignKey:OrganizationID" json:"members,omitempty"`
+}
+
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// OrganizationMember links an investor to their organisation. An investor
+// belongs to at most one organisation.
+type OrganizationMember struct {
+	ID             uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID        `gorm:"type:uuid;not null;index" json:"organization_id"`
+	UserID         uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Role           OrganizationRole `gorm:"type:varchar(20);not null" json:"role"`
+	AddedByID      *uuid.UUID       `gorm:"type:uuid" json:"added_by_id,omitempty"`
+	JoinedAt       time.Time        `gorm:"not null" json:"joined_at"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	User         *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (m *OrganizationMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.JoinedAt.IsZero() {
+		m.JoinedAt = time.Now()
+	}
+	return nil
+}
+
+// CanManageMembers reports whether the member can add, remove and re-role members
+func (m *OrganizationMember) CanManageMembers() bool {
+	return m.Role == OrgRoleOwner
+}
+
+// CanPurchaseCredits reports whether the member can buy credits for the pool
+func (m *OrganizationMember) CanPurchaseCredits() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRolePartner
+}
+
+// CanSignForOrganization reports whether the member may be made the signatory
+func (m *OrganizationMember) CanSignForOrganization() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRolePartner
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Passkey is a WebAuthn credential registered to a user. A user may have one
+// per device or password manager.
+type Passkey struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CredentialID   string     `gorm:"not null;uniqueIndex" json:"credential_id"` // base64url, as the browser reports it
+	PublicKey      []byte     `gorm:"not null" json:"-"`                         // COSE_Key
+	Algorithm      int64      `json:"algorithm"`
+	SignCount      int64      `gorm:"not null;default:0" json:"sign_count"` // Must increase on every use unless always zero
+	AAGUID         string     `json:"aaguid,omitempty"`                     // Identifies the authenticator model
+	Transports     string     `json:"transports,omitempty"`                 // Comma-separated hints, e.g. "internal,hybrid"
+	Name           string     `gorm:"not null" json:"name"`
+	BackupEligible bool       `json:"backup_eligible"` // Synced passkey, e.g. iCloud Keychain
+	BackedUp       bool       `json:"backed_up"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (p *Passkey) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// Purposes a passkey challenge can be issued for
+const (
+	PasskeyChallengeRegister = "register"
+	PasskeyChallengeLogin    = "login"
+)
+
+// PasskeyChallenge is a challenge issued for a registration or login ceremony.
+// It is deleted when used, so a signed response cannot be replayed.
+type PasskeyChallenge struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Challenge string     `gorm:"not null;uniqueIndex" json:"challenge"`
+	Purpose   string     `gorm:"not null" json:"purpose"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"` // Registering user; empty for logins
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (c *PasskeyChallenge) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,16 +14,33 @@ const (
 	PaymentStatusCompleted PaymentStatus = "completed"
 	PaymentStatusFailed    PaymentStatus = "failed"
 	PaymentStatusRefunded  PaymentStatus = "refunded"
+	PaymentStatusDisputed  PaymentStatus = "disputed"
+)
+
+// PaymentType distinguishes paid credit purchases from admin grants
+type PaymentType string
+
+const (
+	PaymentTypePurchase PaymentType = "purchase"
+	PaymentTypeGrant    PaymentType = "grant"
 )
 
 type Payment struct {
 	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	InvestorID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"investor_id"`
+	Type               PaymentType    `gorm:"type:varchar(20);not null;default:'purchase'" json:"type"`
+	GrantedByID        *uuid.UUID     `gorm:"type:uuid" json:"granted_by_id,omitempty"` // Admin who comped the credits
+	OrganizationID     *uuid.UUID     `gorm:"type:uuid;index" json:"organization_id,omitempty"` // Credits go to the organisation's pool
 	
 	// Amount
-	Amount             int64          `gorm:"not null" json:"amount"` // In cents
+	Amount             int64          `gorm:"not null" json:"amount"` // In minor units
 	Currency           string         `gorm:"not null;default:'usd'" json:"currency"`
 	
+	// Pricing
+	PackageID          *uuid.UUID     `gorm:"type:uuid;index" json:"package_id,omitempty"`
+	PromoCodeID        *uuid.UUID     `gorm:"type:uuid;index" json:"promo_code_id,omitempty"`
+	DiscountAmount     int64          `gorm:"not null;default:0" json:"discount_amount"` // In minor units
+	
 	// Stripe
 	StripePaymentID    string         `gorm:"index" json:"stripe_payment_id,omitempty"`
 	StripeClientSecret string         `json:"-"`
@@ -40,10 +56,20 @@ type Payment struct {
 	Description        string         `json:"description,omitempty"`
 	ReceiptURL         string         `json:"receipt_url,omitempty"`
 	
+	// Refunds and disputes
+	AmountRefunded     int64          `gorm:"not null;default:0" json:"amount_refunded"` // In minor units
+	RefundPending      int64          `gorm:"not null;default:0" json:"-"` // Reserved by a refund in flight at the provider
+	StripeRefundID     string         `json:"stripe_refund_id,omitempty"`
+	RefundReason       string         `json:"refund_reason,omitempty"`
+	RefundedAt         *time.Time     `json:"refunded_at,omitempty"`
+	DisputedAt         *time.Time     `json:"disputed_at,omitempty"`
+	
 	// Timestamps
 	CreatedAt          time.Time      `json:"created_at"`
 	UpdatedAt          time.Time      `json:"updated_at"`
 	CompletedAt        *time.Time     `json:"completed_at,omitempty"`
+	ExpiresAt          *time.Time     `gorm:"index" json:"expires_at,omitempty"` // Unused credits lapse after this
+	ReminderSentAt     *time.Time     `json:"-"` // Pre-expiry reminder
 	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relations
@@ -55,11 +81,24 @@ func (p *Payment) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
 	}
+	if p.Type == "" {
+		p.Type = PaymentTypePurchase
+	}
 	return nil
 }
 
+// IsGrant reports whether the credits were comped by an admin rather than bought
+func (p *Payment) IsGrant() bool {
+	return p.Type == PaymentTypeGrant
+}
+
 func (p *Payment) CanViewMore() bool {
-	return p.Status == PaymentStatusCompleted && p.ProjectsRemaining > 0
+	return p.Status == PaymentStatusCompleted && p.ProjectsRemaining > 0 && !p.IsExpired()
+}
+
+// IsExpired reports whether the payment's credits have passed their expiry date
+func (p *Payment) IsExpired() bool {
+	return p.ExpiresAt != nil && !time.Now().Before(*p.ExpiresAt)
 }
 
 func (p *Payment) UseCredit() bool {
@@ -70,9 +109,42 @@ func (p *Payment) UseCredit() bool {
 	return false
 }
 
+// RefundableAmount is what has been paid and not yet refunded, in minor units
+func (p *Payment) RefundableAmount() int64 {
+	return p.Amount - p.AmountRefunded
+}
+
+// ProRataRefundAmount is the value of the unused credits, in minor units, rounded down
+func (p *Payment) ProRataRefundAmount() int64 {
+	if p.ProjectsTotal <= 0 {
+		return 0
+	}
+	amount := p.Amount * int64(p.ProjectsRemaining) / int64(p.ProjectsTotal)
+	if amount > p.RefundableAmount() {
+		amount = p.RefundableAmount()
+	}
+	return amount
+}
+
+// Refund types
+const (
+	RefundTypeFull    = "full"
+	RefundTypeProRata = "pro_rata"
+)
+
+// StripeWebhookEvent records a Stripe event that has been processed, so that
+// redelivered events are acknowledged without being applied twice
+type StripeWebhookEvent struct {
+	ID          string    `gorm:"primary_key;type:varchar(255)" json:"id"` // Stripe event ID (evt_...)
+	Type        string    `gorm:"type:varchar(100);index" json:"type"`
+	ProcessedAt time.Time `gorm:"not null" json:"processed_at"`
+}
+
 // PaymentResponse for API
 type PaymentResponse struct {
 	ID                uuid.UUID     `json:"id"`
+	Type              PaymentType   `json:"type"`
+	Description       string        `json:"description,omitempty"`
 	Amount            int64         `json:"amount"`
 	AmountFormatted   string        `json:"amount_formatted"`
 	Currency          string        `json:"currency"`
@@ -82,11 +154,14 @@ type PaymentResponse struct {
 	ReceiptURL        string        `json:"receipt_url,omitempty"`
 	CreatedAt         time.Time     `json:"created_at"`
 	CompletedAt       *time.Time    `json:"completed_at,omitempty"`
+	ExpiresAt         *time.Time    `json:"expires_at,omitempty"`
 }
 
 func (p *Payment) ToResponse() PaymentResponse {
 	return PaymentResponse{
 		ID:                p.ID,
+		Type:              p.Type,
+		Description:       p.Description,
 		Amount:            p.Amount,
 		AmountFormatted:   FormatCurrency(p.Amount, p.Currency),
 		Currency:          p.Currency,
@@ -96,17 +171,26 @@ func (p *Payment) ToResponse() PaymentResponse {
 		ReceiptURL:        p.ReceiptURL,
 		CreatedAt:         p.CreatedAt,
 		CompletedAt:       p.CompletedAt,
+		ExpiresAt:         p.ExpiresAt,
 	}
 }
 
 // ProjectView records which projects an investor has unlocked
 type ProjectView struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	InvestorID  uuid.UUID `gorm:"type:uuid;not null;index" json:"investor_id"`
-	ProjectID   uuid.UUID `gorm:"type:uuid;not null;index" json:"project_id"`
-	PaymentID   uuid.UUID `gorm:"type:uuid;not null;index" json:"payment_id"`
+	InvestorID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_project_view_investor_project" json:"investor_id"`
+	ProjectID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_project_view_investor_project;uniqueIndex:idx_project_view_org_project;index" json:"project_id"`
+	PaymentID   *uuid.UUID `gorm:"type:uuid;index" json:"payment_id,omitempty"` // Nil for complimentary unlocks
 	ViewedAt    time.Time `gorm:"not null" json:"viewed_at"`
 	
+	// Unlocks by organisation members are shared with the whole organisation
+	OrganizationID *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_project_view_org_project,where:organization_id IS NOT NULL" json:"organization_id,omitempty"`
+	
+	// Complimentary unlocks are granted by an admin without spending a credit
+	IsComplimentary bool       `gorm:"default:false" json:"is_complimentary"`
+	GrantedByID     *uuid.UUID `gorm:"type:uuid" json:"granted_by_id,omitempty"`
+	GrantReason     string     `json:"grant_reason,omitempty"`
+	
 	// Relations
 	Investor    *User     `gorm:"foreignKey:InvestorID" json:"investor,omitempty"`
 	Project     *Project  `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
@@ -131,19 +215,3 @@ type PaymentStatusResponse struct {
 	ProjectsTotal     int             `json:"projects_total"`
 	Message           string          `json:"message,omitempty"`
 }
-
-// Helper function
-func FormatCurrency(amount int64, currency string) string {
-	major := float64(amount) / 100
-	formatted := fmt.Sprintf("%.2f", major)
-	switch currency {
-	case "zar":
-		return "R" + formatted
-	case "eur":
-		return "€" + formatted
-	case "gbp":
-		return "£" + formatted
-	default:
-		return "$" + formatted
-	}
-}
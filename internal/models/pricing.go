@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreditPackage is an admin-managed bundle of project view credits offered at checkout
+type CreditPackage struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name         string    `gorm:"not null" json:"name"`
+	Description  string    `json:"description,omitempty"`
+	Credits      int       `gorm:"not null" json:"credits"`
+	Amount       int64     `gorm:"not null" json:"amount"` // In minor units
+	Currency     string    `gorm:"not null;default:'usd'" json:"currency"`
+	DisplayOrder int       `gorm:"default:0" json:"display_order"`
+	IsActive     bool      `gorm:"not null" json:"is_active"`
+
+	// Prices in other currencies; Amount and Currency are the base price
+	Prices []CreditPackagePrice `gorm:"foreignKey:PackageID" json:"prices,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (p *CreditPackage) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// FormattedAmount returns the package price with its currency symbol
+func (p *CreditPackage) FormattedAmount() string {
+	return FormatCurrency(p.Amount, p.Currency)
+}
+
+// PriceIn returns the package price in a currency. Prices must be loaded to find
+// prices other than the base price.
+func (p *CreditPackage) PriceIn(currency string) (int64, bool) {
+	if strings.EqualFold(currency, p.Currency) {
+		return p.Amount, true
+	}
+	for _, price := range p.Prices {
+		if strings.EqualFold(currency, price.Currency) {
+			return price.Amount, true
+		}
+	}
+	return 0, false
+}
+
+// CreditPackagePrice is a credit package's price in a currency other than its base currency
+type CreditPackagePrice struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PackageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_package_price_currency" json:"package_id"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_package_price_currency" json:"currency"`
+	Amount    int64     `gorm:"not null" json:"amount"` // In minor units of Currency
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (p *CreditPackagePrice) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// FormattedAmount returns the price with its currency symbol
+func (p *CreditPackagePrice) FormattedAmount() string {
+	return FormatCurrency(p.Amount, p.Currency)
+}
+
+// Promo code discount types
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// PromoCode is a coupon investors can apply to a credit package at checkout
+type PromoCode struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code        string    `gorm:"uniqueIndex;not null" json:"code"` // Stored upper-case
+	Description string    `json:"description,omitempty"`
+
+	// Discount
+	DiscountType  string     `gorm:"type:varchar(20);not null" json:"discount_type"` // percent, fixed
+	DiscountValue int64      `gorm:"not null" json:"discount_value"`                 // Whole percent, or minor units
+	Currency      string     `json:"currency,omitempty"`                             // Required for fixed discounts
+	PackageID     *uuid.UUID `gorm:"type:uuid" json:"package_id,omitempty"`          // Restrict to one package
+
+	// Limits
+	MaxRedemptions int        `gorm:"default:0" json:"max_redemptions"` // 0 = unlimited
+	MaxPerUser     int        `gorm:"not null" json:"max_per_user"`     // 0 = unlimited
+	Redemptions    int        `gorm:"default:0" json:"redemptions"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	IsActive       bool       `gorm:"not null" json:"is_active"`
+
+	CreatedByID uuid.UUID      `gorm:"type:uuid" json:"created_by_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (p *PromoCode) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	p.Code = NormalizePromoCode(p.Code)
+	return nil
+}
+
+// NormalizePromoCode makes codes case- and whitespace-insensitive
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsRedeemableAt reports whether the code is active, within its validity window
+// and below its overall usage cap
+func (p *PromoCode) IsRedeemableAt(now time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.ExpiresAt != nil && !now.Before(*p.ExpiresAt) {
+		return false
+	}
+	return p.MaxRedemptions == 0 || p.Redemptions < p.MaxRedemptions
+}
+
+// DiscountFor returns the discount, in minor units, the code gives on a price.
+// Fixed discounts only apply in their own currency, and the discount never
+// exceeds the price.
+func (p *PromoCode) DiscountFor(amount int64, currency string) int64 {
+	var discount int64
+	switch p.DiscountType {
+	case DiscountTypePercent:
+		discount = amount * p.DiscountValue / 100
+	case DiscountTypeFixed:
+		if strings.EqualFold(p.Currency, currency) {
+			discount = p.DiscountValue
+		}
+	}
+	if discount > amount {
+		discount = amount
+	}
+	return discount
+}
+
+// Label describes the discount for display, e.g. "20% off" or "$50.00 off"
+func (p *PromoCode) Label() string {
+	if p.DiscountType == DiscountTypePercent {
+		return fmt.Sprintf("%d%% off", p.DiscountValue)
+	}
+	return FormatCurrency(p.DiscountValue, p.Currency) + " off"
+}
+
+// PromoRedemption records a promo code applied to a payment
+type PromoRedemption struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PromoCodeID    uuid.UUID `gorm:"type:uuid;not null;index" json:"promo_code_id"`
+	InvestorID     uuid.UUID `gorm:"type:uuid;not null;index" json:"investor_id"`
+	PaymentID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"payment_id"`
+	DiscountAmount int64     `gorm:"not null" json:"discount_amount"` // In minor units
+	Currency       string    `gorm:"not null" json:"currency"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Relations
+	PromoCode *PromoCode `gorm:"foreignKey:PromoCodeID" json:"promo_code,omitempty"`
+	Investor  *User      `gorm:"foreignKey:InvestorID" json:"investor,omitempty"`
+	Payment   *Payment   `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+}
+
+func (r *PromoRedemption) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// CheckoutQuote is the price an investor will pay for a package, after any promo code
+type CheckoutQuote struct {
+	Package        *CreditPackage `json:"package"`
+	PromoCode      string         `json:"promo_code,omitempty"`
+	PromoLabel     string         `json:"promo_label,omitempty"`
+	Subtotal       int64          `json:"subtotal"`
+	Discount       int64          `json:"discount"`
+	Total          int64          `json:"total"`
+	Currency       string         `json:"currency"`
+	TotalFormatted string         `json:"total_formatted"`
+
+	promo *PromoCode
+}
+
+// Promo returns the promo code applied to the quote, if any
+func (q *CheckoutQuote) Promo() *PromoCode {
+	return q.promo
+}
+
+// NewCheckoutQuote prices a package in a currency with an optional promo code.
+// The caller checks that the package is sold in the currency.
+func NewCheckoutQuote(pkg *CreditPackage, currency string, promo *PromoCode) *CheckoutQuote {
+	amount, _ := pkg.PriceIn(currency)
+	q := &CheckoutQuote{
+		Package:  pkg,
+		Subtotal: amount,
+		Currency: strings.ToLower(currency),
+		promo:    promo,
+	}
+	if promo != nil {
+		q.PromoCode = promo.Code
+		q.PromoLabel = promo.Label()
+		q.Discount = promo.DiscountFor(q.Subtotal, q.Currency)
+	}
+	q.Total = q.Subtotal - q.Discount
+	q.TotalFormatted = FormatCurrency(q.Total, q.Currency)
+	return q
+}
@@ -50,6 +50,9 @@ type Project struct {
 	MaxInvestment    int64          `json:"max_investment,omitempty"`
 	EquityOffered    float64        `json:"equity_offered,omitempty"`
 	ValuationCap     int64          `json:"valuation_cap,omitempty"`
+	FundingGoal      int64          `json:"funding_goal,omitempty"`  // Raise target in minor units of Currency
+	AmountRaised     int64          `gorm:"default:0" json:"amount_raised"` // Confirmed funds received
+	Currency         string         `gorm:"default:'usd'" json:"currency"` // Currency of the round; offers are made in it
 	
 	// Links
 	WebsiteURL       string         `json:"website_url,omitempty"`
@@ -104,6 +107,11 @@ func (p *Project) IsPublished() bool {
 	return p.Status == ProjectStatusApproved
 }
 
+// IsFundingGoalMet reports whether confirmed funds have reached the raise target
+func (p *Project) IsFundingGoalMet() bool {
+	return p.FundingGoal > 0 && p.AmountRaised >= p.FundingGoal
+}
+
 func (p *Project) CanEdit() bool {
 	return p.Status == ProjectStatusDraft || p.Status == ProjectStatusRejected
 }
@@ -116,6 +124,7 @@ type ProjectPublicView struct {
 	CategoryID    uuid.UUID     `json:"category_id"`
 	CategoryName  string        `json:"category_name"`
 	MinInvestment int64         `json:"min_investment"`
+	Currency      string        `json:"currency"`
 	PrimaryImage  string        `json:"primary_image,omitempty"`
 	LogoURL       string        `json:"logo_url,omitempty"`
 	CreatedAt     time.Time     `json:"created_at"`
@@ -130,6 +139,7 @@ func (p *Project) ToPublicView(categoryName string, isUnlocked bool) ProjectPubl
 		CategoryID:    p.CategoryID,
 		CategoryName:  categoryName,
 		MinInvestment: p.MinInvestment,
+		Currency:      p.Currency,
 		PrimaryImage:  p.PrimaryImage,
 		LogoURL:       p.LogoURL,
 		CreatedAt:     p.CreatedAt,
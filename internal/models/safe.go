@@ -0,0 +1,145 @@
+package models
+
+// SAFETemplateFor returns the document template for the given SAFE type
+func SAFETemplateFor(safeType string) string {
+	switch safeType {
+	case SAFETypePreMoney:
+		return SAFEPreMoneyTemplate
+	case SAFETypeMFN:
+		return SAFEMFNTemplate
+	default:
+		return SAFEPostMoneyTemplate
+	}
+}
+
+// Parties block shared by all SAFE variants
+const safePartiesSection = `
+THIS CERTIFIES THAT in exchange for the payment by {{.InvestorName}} (the "Investor")
+of {{.PurchaseAmount}} (the "Purchase Amount") on or about {{.DocumentDate}},
+{{.CompanyName}}, a {{.CompanyJurisdiction}} {{.CompanyEntityType}} (the "Company"),
+issues to the Investor the right to certain shares of the Company's Capital Stock,
+subject to the terms described below.
+{{if .CompanyRegistration}}
+Company Registration Number: {{.CompanyRegistration}}
+{{end}}
+{{if eq .InvestorType "institutional"}}
+The Investor is a legal entity registered as {{.InvestorRegistration}} in {{.InvestorJurisdiction}},
+with its principal address at {{.InvestorAddress}}, represented by {{.InvestorSignatory}} ({{.InvestorTitle}}).
+{{end}}
+`
+
+// Closing sections and signature block shared by all SAFE variants
+const safeClosingSection = `
+{{if .ProRataRights}}
+PRO RATA RIGHTS:
+The Investor shall be entitled to participate, on a pro rata basis, in the next
+Equity Financing of the Company following the conversion of this SAFE.
+{{end}}
+
+LIQUIDITY EVENT:
+If there is a Liquidity Event before the termination of this SAFE, the Investor will
+automatically be entitled to receive a portion of the proceeds equal to the greater of
+(i) the Purchase Amount and (ii) the amount payable on the number of shares of Common
+Stock equal to the Purchase Amount divided by the Liquidity Price.
+
+DISSOLUTION EVENT:
+If there is a Dissolution Event before this SAFE terminates, the Investor will
+automatically be entitled to receive a portion of proceeds equal to the Purchase Amount,
+due and payable immediately prior to the consummation of the Dissolution Event.
+
+MISCELLANEOUS:
+This SAFE is not a debt instrument and the Investor is not entitled to voting rights
+or dividends as a stockholder by virtue of this SAFE. This SAFE was arranged through the
+AngelVault Platform, which is not a party to this instrument.
+
+IN WITNESS WHEREOF, the undersigned have caused this SAFE to be duly executed.
+
+COMPANY: {{.CompanyName}}
+Signed: ______________________
+Date: ________________________
+
+INVESTOR: {{.InvestorName}}
+Signed: ______________________
+Date: ________________________
+
+Term Sheet Reference: {{.TermSheetID}}
+Document Version: {{.Version}}
+`
+
+// Post-money valuation cap SAFE Template
+const SAFEPostMoneyTemplate = `
+SAFE
+(Simple Agreement for Future Equity)
+POST-MONEY VALUATION CAP
+` + safePartiesSection + `
+1. EVENTS
+(a) Equity Financing. If there is an Equity Financing before the termination of this
+SAFE, on the initial closing of such Equity Financing, this SAFE will automatically
+convert into shares of Safe Preferred Stock equal to the Purchase Amount divided by the
+Conversion Price.
+
+2. DEFINITIONS
+{{if .ValuationCap}}"Post-Money Valuation Cap" means {{.ValuationCap}}.
+"Safe Price" means the Post-Money Valuation Cap divided by the Company Capitalization,
+which includes all outstanding SAFEs and convertible securities.
+{{end}}{{if .DiscountPercent}}"Discount Rate" means {{.DiscountRatePercent}} (a {{.DiscountPercent}} discount).
+"Discount Price" means the price per share of Standard Preferred Stock sold in the
+Equity Financing multiplied by the Discount Rate.
+{{end}}"Conversion Price" means {{if and .ValuationCap .DiscountPercent}}either (1) the Safe Price or (2) the Discount Price,
+whichever calculation results in a greater number of shares of Safe Preferred Stock{{else if .ValuationCap}}the Safe Price{{else if .DiscountPercent}}the Discount Price{{else}}the price per share of Standard Preferred Stock sold in the Equity Financing{{end}}.
+{{if .HasMFN}}
+3. MOST FAVORED NATION
+If the Company issues any Subsequent Convertible Securities prior to termination of this
+SAFE with terms more favorable to the holder, the Company will promptly notify the
+Investor and, at the Investor's election, amend this SAFE to match those terms.
+{{end}}` + safeClosingSection
+
+// Pre-money valuation cap SAFE Template
+const SAFEPreMoneyTemplate = `
+SAFE
+(Simple Agreement for Future Equity)
+PRE-MONEY VALUATION CAP
+` + safePartiesSection + `
+1. EVENTS
+(a) Equity Financing. If there is an Equity Financing before the termination of this
+SAFE, on the initial closing of such Equity Financing, this SAFE will automatically
+convert into shares of Safe Preferred Stock equal to the Purchase Amount divided by the
+Conversion Price.
+
+2. DEFINITIONS
+{{if .ValuationCap}}"Pre-Money Valuation Cap" means {{.ValuationCap}}.
+"Safe Price" means the Pre-Money Valuation Cap divided by the Company Capitalization
+immediately prior to the Equity Financing, excluding all outstanding SAFEs and
+convertible securities.
+{{end}}{{if .DiscountPercent}}"Discount Rate" means {{.DiscountRatePercent}} (a {{.DiscountPercent}} discount).
+"Discount Price" means the price per share of Standard Preferred Stock sold in the
+Equity Financing multiplied by the Discount Rate.
+{{end}}"Conversion Price" means {{if and .ValuationCap .DiscountPercent}}either (1) the Safe Price or (2) the Discount Price,
+whichever calculation results in a greater number of shares of Safe Preferred Stock{{else if .ValuationCap}}the Safe Price{{else if .DiscountPercent}}the Discount Price{{else}}the price per share of Standard Preferred Stock sold in the Equity Financing{{end}}.
+{{if .HasMFN}}
+3. MOST FAVORED NATION
+If the Company issues any Subsequent Convertible Securities prior to termination of this
+SAFE with terms more favorable to the holder, the Company will promptly notify the
+Investor and, at the Investor's election, amend this SAFE to match those terms.
+{{end}}` + safeClosingSection
+
+// MFN-only SAFE Template (no valuation cap, no discount)
+const SAFEMFNTemplate = `
+SAFE
+(Simple Agreement for Future Equity)
+MFN ONLY - NO VALUATION CAP, NO DISCOUNT
+` + safePartiesSection + `
+1. EVENTS
+(a) Equity Financing. If there is an Equity Financing before the termination of this
+SAFE, on the initial closing of such Equity Financing, this SAFE will automatically
+convert into shares of Standard Preferred Stock equal to the Purchase Amount divided by
+the price per share of the Standard Preferred Stock.
+
+2. MOST FAVORED NATION
+If the Company issues any Subsequent Convertible Securities prior to termination of this
+SAFE, the Company will promptly provide the Investor with written notice thereof,
+together with copies of all documentation relating to such securities. If the Investor
+determines that the terms of the Subsequent Convertible Securities are preferable, the
+Investor will notify the Company and this SAFE will be amended to be identical to the
+instrument(s) evidencing the Subsequent Convertible Securities.
+` + safeClosingSection
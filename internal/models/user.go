@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +23,22 @@ const (
 	AuthProviderLinkedIn AuthProvider = "linkedin"
 )
 
+// oidcProviderPrefix marks providers configured through OIDC_PROVIDERS
+const oidcProviderPrefix = "oidc:"
+
+// OIDCProvider returns the AuthProvider for a configured OIDC provider name
+func OIDCProvider(name string) AuthProvider {
+	return AuthProvider(oidcProviderPrefix + name)
+}
+
+// OIDCName returns the configured name of an OIDC provider
+func (p AuthProvider) OIDCName() (string, bool) {
+	if !strings.HasPrefix(string(p), oidcProviderPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(string(p), oidcProviderPrefix), true
+}
+
 type User struct {
 	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	Email            string         `gorm:"uniqueIndex;not null" json:"email"`
@@ -31,7 +48,9 @@ type User struct {
 	CompanyName      string         `json:"company_name,omitempty"`
 	Role             UserRole       `gorm:"type:varchar(20);not null;default:'investor'" json:"role"`
 	
-	// OAuth fields
+	// OAuth fields. AuthProvider is how the account was created; linked
+	// sign-ins live in UserIdentity. OAuthID is only read for accounts created
+	// before identities existed.
 	AuthProvider     AuthProvider   `gorm:"type:varchar(20);default:'email'" json:"auth_provider"`
 	OAuthID          string         `gorm:"index" json:"-"`
 	ProfileImageURL  string         `json:"profile_image_url,omitempty"`
@@ -44,6 +63,15 @@ type User struct {
 	PasswordResetToken   string     `json:"-"`
 	PasswordResetExpires *time.Time `json:"-"`
 	
+	// Two-factor authentication (TOTP). The secret is set at enrollment and only
+	// takes effect once a code has been verified.
+	TOTPSecret       string         `json:"-"`
+	TOTPEnabled      bool           `gorm:"default:false" json:"totp_enabled"`
+	TOTPEnabledAt    *time.Time     `json:"totp_enabled_at,omitempty"`
+	TOTPLastCounter  int64          `gorm:"default:0" json:"-"` // Last time step accepted, so codes can't be replayed
+	TwoFactorFailures    int        `gorm:"not null;default:0" json:"-"` // Wrong codes at sign-in since the last success or lockout
+	TwoFactorLockedUntil *time.Time `json:"-"`
+	
 	// Status
 	IsActive         bool           `gorm:"default:true" json:"is_active"`
 	LastLoginAt      *time.Time     `json:"last_login_at,omitempty"`
@@ -97,6 +125,12 @@ func (u *User) IsAdmin() bool {
 	return u.Role == RoleAdmin
 }
 
+// RequiresTwoFactor reports whether the user must use TOTP to sign in. It is
+// mandatory for admins.
+func (u *User) RequiresTwoFactor() bool {
+	return u.TOTPEnabled || u.IsAdmin()
+}
+
 // UserResponse is the safe representation for API responses
 type UserResponse struct {
 	ID              uuid.UUID `json:"id"`
@@ -108,6 +142,7 @@ type UserResponse struct {
 	AuthProvider    string    `json:"auth_provider"`
 	ProfileImageURL string    `json:"profile_image_url,omitempty"`
 	EmailVerified   bool      `json:"email_verified"`
+	TOTPEnabled     bool      `json:"totp_enabled"`
 	CreatedAt       time.Time `json:"created_at"`
 }
 
@@ -122,6 +157,7 @@ func (u *User) ToResponse() UserResponse {
 		AuthProvider:    string(u.AuthProvider),
 		ProfileImageURL: u.ProfileImageURL,
 		EmailVerified:   u.EmailVerified,
+		TOTPEnabled:     u.TOTPEnabled,
 		CreatedAt:       u.CreatedAt,
 	}
 }
@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "client-id"
+
+// testIssuer is an OpenID provider serving discovery and a JWKS it can rotate
+type testIssuer struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string]*rsa.PrivateKey
+	jwksServed int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	iss := &testIssuer{keys: make(map[string]*rsa.PrivateKey)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                iss.server.URL,
+			AuthorizationEndpoint: iss.server.URL + "/authorize",
+			TokenEndpoint:         iss.server.URL + "/token",
+			JWKSURI:               iss.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+
+		iss.jwksServed++
+		keys := []jsonWebKey{}
+		for kid, key := range iss.keys {
+			keys = append(keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+
+	iss.addKey(t, "key-1")
+	return iss
+}
+
+// addKey publishes a new signing key, as a provider does when rotating
+func (iss *testIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.keys[kid] = key
+	iss.mu.Unlock()
+}
+
+func (iss *testIssuer) fetches() int {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	return iss.jwksServed
+}
+
+// claims are those of a valid ID token for testClientID with nonce
+func (iss *testIssuer) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   iss.server.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"nonce": nonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign issues an RS256 token with the named key
+func (iss *testIssuer) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	// Unpublished key IDs are signed with the first key
+	iss.mu.Lock()
+	key, ok := iss.keys[kid]
+	if !ok {
+		key = iss.keys["key-1"]
+	}
+	iss.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// verifier discovers the issuer the way the server does
+func (iss *testIssuer) verifier(t *testing.T) *Verifier {
+	t.Helper()
+
+	client := iss.server.Client()
+	doc, err := Discover(context.Background(), client, iss.server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{Issuer: doc.Issuer, ClientID: testClientID, Keys: NewKeySet(client, doc.JWKSURI)}
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	iss := newTestIssuer(t)
+
+	// The document names the server's root, not this path
+	if _, err := Discover(context.Background(), iss.server.Client(), iss.server.URL+"/tenant"); err == nil {
+		t.Fatal("accepted a discovery document for another issuer")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier(t)
+	const nonce = "nonce-1"
+
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := iss.claims(nonce)
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, iss.claims(nonce)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HS256 keyed with the public modulus, the classic algorithm confusion
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, iss.claims(nonce))
+	hmac.Header["kid"] = "key-1"
+	symmetric, err := hmac.SignedString(iss.keys["key-1"].N.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		ok    bool
+	}{
+		{"valid", iss.sign(t, "key-1", iss.claims(nonce)), nonce, true},
+		{"wrong issuer", iss.sign(t, "key-1", with("iss", "https://evil.test")), nonce, false},
+		{"wrong audience", iss.sign(t, "key-1", with("aud", "other-client")), nonce, false},
+		{"other audience without azp", iss.sign(t, "key-1", with("aud", []string{testClientID, "other-client"})), nonce, false},
+		{"wrong nonce", iss.sign(t, "key-1", iss.claims("nonce-2")), nonce, false},
+		{"missing nonce", iss.sign(t, "key-1", with("nonce", nil)), nonce, false},
+		{"no nonce expected", iss.sign(t, "key-1", with("nonce", "")), "", false},
+		{"expired", iss.sign(t, "key-1", with("exp", time.Now().Add(-2*time.Minute).Unix())), nonce, false},
+		{"no expiry", iss.sign(t, "key-1", with("exp", nil)), nonce, false},
+		{"issued in the future", iss.sign(t, "key-1", with("iat", time.Now().Add(time.Hour).Unix())), nonce, false},
+		{"alg none", unsigned, nonce, false},
+		{"alg HS256", symmetric, nonce, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token, tt.nonce)
+			if tt.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Fatalf("got claims %v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), "invalid ID token") {
+				t.Fatalf("got %v, want an invalid ID token error", err)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyRefetchIsRateLimited(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier(t)
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, iss.sign(t, "key-1", iss.claims("n")), "n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := iss.fetches(); got != 1 {
+		t.Fatalf("got %d JWKS fetches, want 1", got)
+	}
+
+	// Tokens naming keys the provider never published do not reach it again
+	for i := 0; i < 5; i++ {
+		if _, err := v.Verify(ctx, iss.sign(t, "forged", iss.claims("n")), "n"); err == nil {
+			t.Fatal("accepted a token with an unknown key ID")
+		}
+	}
+	if got := iss.fetches(); got != 1 {
+		t.Fatalf("got %d JWKS fetches after unknown key IDs, want 1", got)
+	}
+
+	// A rotated key is picked up once the refresh interval has passed
+	iss.addKey(t, "key-2")
+	rotated := iss.sign(t, "key-2", iss.claims("n"))
+	if _, err := v.Verify(ctx, rotated, "n"); err == nil {
+		t.Fatal("accepted a token before its key was fetched")
+	}
+	v.Keys.mu.Lock()
+	v.Keys.fetchedAt = time.Now().Add(-keyRefreshInterval)
+	v.Keys.mu.Unlock()
+
+	if _, err := v.Verify(ctx, rotated, "n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := iss.fetches(); got != 2 {
+		t.Fatalf("got %d JWKS fetches after rotation, want 2", got)
+	}
+
+	// Known keys are served from the cache
+	if _, err := v.Verify(ctx, iss.sign(t, "key-1", iss.claims("n")), "n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := iss.fetches(); got != 2 {
+		t.Fatalf("got %d JWKS fetches for a cached key, want 2", got)
+	}
+}
@@ -0,0 +1,259 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// FakeSignatureHeader carries the HMAC of fake webhook deliveries
+const FakeSignatureHeader = "X-Fake-Signature"
+
+var (
+	ErrFakeIntentNotFound = errors.New("fake intent not found")
+	ErrFakeRefundTooLarge = errors.New("refund exceeds the unrefunded amount")
+)
+
+// FakeProvider is a deterministic in-memory processor for offline runs and
+// integration tests. IDs are sequential (pi_fake_0001, re_fake_0001, ...) and
+// nothing leaves the process. Tests drive intents with Succeed and Fail, and
+// deliver webhooks built with the Event helpers and signed with SignWebhook.
+type FakeProvider struct {
+	// AutoSucceed makes new intents succeed immediately, as if the customer paid
+	AutoSucceed bool
+
+	secret   string
+	mu       sync.Mutex
+	seq      int
+	intents  map[string]*Intent
+	refunded map[string]int64
+	refunds  []Refund
+	keys     map[string]any // Idempotency key -> previous result
+}
+
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	if webhookSecret == "" {
+		webhookSecret = "whsec_fake"
+	}
+	return &FakeProvider{
+		secret:   webhookSecret,
+		intents:  make(map[string]*Intent),
+		refunded: make(map[string]int64),
+		keys:     make(map[string]any),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) nextID(prefix string) string {
+	p.seq++
+	return fmt.Sprintf("%s_fake_%04d", prefix, p.seq)
+}
+
+func (p *FakeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	if params.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prev, ok := p.keys[params.IdempotencyKey].(*Intent); ok && params.IdempotencyKey != "" {
+		copied := *prev
+		return &copied, nil
+	}
+
+	id := p.nextID("pi")
+	intent := &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Amount:       params.Amount,
+		Currency:     params.Currency,
+		Status:       IntentStatusPending,
+		Metadata:     params.Metadata,
+	}
+	if p.AutoSucceed {
+		p.succeed(intent)
+	}
+
+	p.intents[id] = intent
+	if params.IdempotencyKey != "" {
+		p.keys[params.IdempotencyKey] = intent
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) GetIntent(id string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[id]
+	if !ok {
+		return nil, ErrFakeIntentNotFound
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) Refund(params RefundParams) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prev, ok := p.keys[params.IdempotencyKey].(*Refund); ok && params.IdempotencyKey != "" {
+		copied := *prev
+		return &copied, nil
+	}
+
+	intent, ok := p.intents[params.IntentID]
+	if !ok {
+		return nil, ErrFakeIntentNotFound
+	}
+	if intent.Status != IntentStatusSucceeded {
+		return nil, errors.New("intent has not succeeded")
+	}
+	if params.Amount <= 0 || p.refunded[intent.ID]+params.Amount > intent.Amount {
+		return nil, ErrFakeRefundTooLarge
+	}
+
+	p.refunded[intent.ID] += params.Amount
+	refund := &Refund{ID: p.nextID("re"), Amount: params.Amount}
+	p.refunds = append(p.refunds, *refund)
+	if params.IdempotencyKey != "" {
+		p.keys[params.IdempotencyKey] = refund
+	}
+
+	copied := *refund
+	return &copied, nil
+}
+
+// Succeed marks an intent as paid
+func (p *FakeProvider) Succeed(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[id]
+	if !ok {
+		return ErrFakeIntentNotFound
+	}
+	p.succeed(intent)
+	return nil
+}
+
+func (p *FakeProvider) succeed(intent *Intent) {
+	intent.Status = IntentStatusSucceeded
+	intent.FailureMessage = ""
+	intent.ReceiptURL = "https://payments.invalid/receipts/" + intent.ID
+}
+
+// Fail marks an intent as declined
+func (p *FakeProvider) Fail(id, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[id]
+	if !ok {
+		return ErrFakeIntentNotFound
+	}
+	intent.Status = IntentStatusFailed
+	intent.FailureMessage = message
+	return nil
+}
+
+// Refunds returns every refund created so far, oldest first
+func (p *FakeProvider) Refunds() []Refund {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Refund(nil), p.refunds...)
+}
+
+// IntentEvent builds a webhook event reporting an intent's current state
+func (p *FakeProvider) IntentEvent(id string) (*Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[id]
+	if !ok {
+		return nil, ErrFakeIntentNotFound
+	}
+
+	eventType := EventPaymentSucceeded
+	if intent.Status != IntentStatusSucceeded {
+		eventType = EventPaymentFailed
+	}
+	copied := *intent
+	return &Event{ID: p.nextID("evt"), Type: eventType, RawType: string(eventType), Intent: &copied, IntentID: id}, nil
+}
+
+// RefundEvent builds a webhook event reporting an intent's refunds so far
+func (p *FakeProvider) RefundEvent(id string) (*Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[id]
+	if !ok {
+		return nil, ErrFakeIntentNotFound
+	}
+	return &Event{
+		ID:             p.nextID("evt"),
+		Type:           EventChargeRefunded,
+		RawType:        string(EventChargeRefunded),
+		IntentID:       id,
+		AmountRefunded: p.refunded[id],
+		FullyRefunded:  p.refunded[id] >= intent.Amount,
+	}, nil
+}
+
+// DisputeEvent builds a webhook event opening a chargeback on an intent
+func (p *FakeProvider) DisputeEvent(id, reason string) *Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return &Event{
+		ID:            p.nextID("evt"),
+		Type:          EventDisputeCreated,
+		RawType:       string(EventDisputeCreated),
+		IntentID:      id,
+		DisputeReason: reason,
+	}
+}
+
+func (p *FakeProvider) SignatureHeader() string {
+	return FakeSignatureHeader
+}
+
+// SignWebhook serializes an event as a webhook delivery and returns the payload
+// with its signature
+func (p *FakeProvider) SignWebhook(event *Event) ([]byte, string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.sign(payload), nil
+}
+
+func (p *FakeProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakeProvider) ParseWebhook(payload []byte, signature string) (*Event, error) {
+	if !hmac.Equal([]byte(signature), []byte(p.sign(payload))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode event: %w", err)
+	}
+	return &event, nil
+}
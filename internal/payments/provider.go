@@ -0,0 +1,143 @@
+// Package payments abstracts the card processor behind a small Provider interface
+// so the payment service can run against Stripe in production and a deterministic
+// in-memory fake offline.
+package payments
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ukuvago/angelvault/internal/config"
+)
+
+// ErrInvalidSignature is returned when a webhook payload fails verification.
+// Processors should not retry these deliveries.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// IntentStatus is the processor-neutral state of a payment intent
+type IntentStatus string
+
+const (
+	IntentStatusPending   IntentStatus = "pending" // Awaiting payment details or confirmation
+	IntentStatusSucceeded IntentStatus = "succeeded"
+	IntentStatusFailed    IntentStatus = "failed"
+	IntentStatusCancelled IntentStatus = "cancelled"
+)
+
+// Intent is a request to collect a payment
+type Intent struct {
+	ID             string
+	ClientSecret   string
+	Amount         int64 // Minor units
+	Currency       string
+	Status         IntentStatus
+	Metadata       map[string]string
+	ReceiptURL     string
+	FailureMessage string
+}
+
+// IntentParams describes the payment to collect
+type IntentParams struct {
+	Amount         int64 // Minor units
+	Currency       string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// RefundParams describes a full or partial refund of a succeeded intent
+type RefundParams struct {
+	IntentID       string
+	Amount         int64 // Minor units
+	Reason         string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// Refund is a refund created by the processor
+type Refund struct {
+	ID     string
+	Amount int64
+}
+
+// EventType is the processor-neutral kind of a webhook event
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+	EventChargeRefunded   EventType = "charge.refunded"
+	EventDisputeCreated   EventType = "dispute.created"
+	EventUnhandled        EventType = "unhandled"
+)
+
+// Event is a verified webhook event translated from the processor's format
+type Event struct {
+	ID      string // Unique per processor; used for idempotency
+	Type    EventType
+	RawType string // The processor's own event type
+
+	// Payment events
+	Intent *Intent
+
+	// Refund and dispute events
+	IntentID       string
+	AmountRefunded int64 // Cumulative, in minor units
+	FullyRefunded  bool
+	DisputeReason  string
+}
+
+// Provider is a card processor
+type Provider interface {
+	// Name identifies the processor, e.g. "stripe"
+	Name() string
+
+	// CreateIntent starts collecting a payment
+	CreateIntent(params IntentParams) (*Intent, error)
+
+	// GetIntent retrieves the current state of an intent
+	GetIntent(id string) (*Intent, error)
+
+	// Refund returns money from a succeeded intent
+	Refund(params RefundParams) (*Refund, error)
+
+	// SignatureHeader is the HTTP header that carries the webhook signature
+	SignatureHeader() string
+
+	// ParseWebhook verifies a webhook delivery and translates it. Verification
+	// failures wrap ErrInvalidSignature.
+	ParseWebhook(payload []byte, signature string) (*Event, error)
+}
+
+// Provider names accepted in PAYMENT_PROVIDER
+const (
+	ProviderStripe = "stripe"
+	ProviderFake   = "fake"
+)
+
+// NewProvider builds the configured processor. It returns nil in demo mode, when
+// no processor is configured.
+func NewProvider(cfg *config.Config) (Provider, error) {
+	name := cfg.PaymentProvider
+	if name == "" && cfg.StripeSecretKey != "" {
+		name = ProviderStripe
+	}
+
+	switch name {
+	case "":
+		return nil, nil
+	case ProviderStripe:
+		if cfg.StripeSecretKey == "" {
+			return nil, errors.New("STRIPE_SECRET_KEY is required for the stripe payment provider")
+		}
+		return NewStripeProvider(cfg.StripeSecretKey, cfg.StripeWebhookSecret), nil
+	case ProviderFake:
+		if cfg.IsProduction() {
+			return nil, errors.New("the fake payment provider cannot be used in production")
+		}
+		fake := NewFakeProvider(cfg.StripeWebhookSecret)
+		fake.AutoSucceed = true
+		return fake, nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
@@ -0,0 +1,166 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/refund"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// StripeProvider processes payments through Stripe PaymentIntents
+type StripeProvider struct {
+	webhookSecret string
+}
+
+func NewStripeProvider(secretKey, webhookSecret string) *StripeProvider {
+	stripe.Key = secretKey
+	return &StripeProvider{webhookSecret: webhookSecret}
+}
+
+func (p *StripeProvider) Name() string {
+	return ProviderStripe
+}
+
+func (p *StripeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	piParams := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(params.Amount),
+		Currency: stripe.String(params.Currency),
+		Metadata: params.Metadata,
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+	}
+	if params.IdempotencyKey != "" {
+		piParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+
+	pi, err := paymentintent.New(piParams)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(pi), nil
+}
+
+func (p *StripeProvider) GetIntent(id string) (*Intent, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge")
+
+	pi, err := paymentintent.Get(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(pi), nil
+}
+
+func (p *StripeProvider) Refund(params RefundParams) (*Refund, error) {
+	refundParams := &stripe.RefundParams{
+		PaymentIntent: stripe.String(params.IntentID),
+		Amount:        stripe.Int64(params.Amount),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		Metadata:      params.Metadata,
+	}
+	if params.IdempotencyKey != "" {
+		refundParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+
+	r, err := refund.New(refundParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: r.ID, Amount: r.Amount}, nil
+}
+
+func (p *StripeProvider) SignatureHeader() string {
+	return "Stripe-Signature"
+}
+
+func (p *StripeProvider) ParseWebhook(payload []byte, signature string) (*Event, error) {
+	if p.webhookSecret == "" {
+		return nil, errors.New("webhook secret not configured")
+	}
+
+	raw, err := webhook.ConstructEventWithOptions(payload, signature, p.webhookSecret,
+		webhook.ConstructEventOptions{IgnoreAPIVersionMismatch: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	event := &Event{ID: raw.ID, Type: EventUnhandled, RawType: string(raw.Type)}
+
+	switch raw.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(raw.Data.Raw, &pi); err != nil {
+			return nil, fmt.Errorf("decode payment intent: %w", err)
+		}
+		event.Type = EventPaymentSucceeded
+		if raw.Type == "payment_intent.payment_failed" {
+			event.Type = EventPaymentFailed
+		}
+		event.Intent = stripeIntent(&pi)
+		event.IntentID = pi.ID
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(raw.Data.Raw, &charge); err != nil {
+			return nil, fmt.Errorf("decode charge: %w", err)
+		}
+		event.Type = EventChargeRefunded
+		if charge.PaymentIntent != nil {
+			event.IntentID = charge.PaymentIntent.ID
+		}
+		event.AmountRefunded = charge.AmountRefunded
+		event.FullyRefunded = charge.Refunded
+
+	case "charge.dispute.created":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(raw.Data.Raw, &dispute); err != nil {
+			return nil, fmt.Errorf("decode dispute: %w", err)
+		}
+		event.Type = EventDisputeCreated
+		if dispute.PaymentIntent != nil {
+			event.IntentID = dispute.PaymentIntent.ID
+		} else if dispute.Charge != nil && dispute.Charge.PaymentIntent != nil {
+			event.IntentID = dispute.Charge.PaymentIntent.ID
+		}
+		event.DisputeReason = string(dispute.Reason)
+	}
+
+	return event, nil
+}
+
+// stripeIntent translates a Stripe PaymentIntent
+func stripeIntent(pi *stripe.PaymentIntent) *Intent {
+	intent := &Intent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Amount:       pi.Amount,
+		Currency:     string(pi.Currency),
+		Metadata:     pi.Metadata,
+	}
+
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		intent.Status = IntentStatusSucceeded
+	case stripe.PaymentIntentStatusCanceled:
+		intent.Status = IntentStatusCancelled
+	default:
+		intent.Status = IntentStatusPending
+	}
+
+	if pi.LatestCharge != nil {
+		intent.ReceiptURL = pi.LatestCharge.ReceiptURL
+	}
+	if pi.LastPaymentError != nil {
+		intent.FailureMessage = pi.LastPaymentError.Msg
+		if intent.Status == IntentStatusPending {
+			intent.Status = IntentStatusFailed
+		}
+	}
+
+	return intent
+}
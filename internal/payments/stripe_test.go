@@ -0,0 +1,107 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func stripeEventPayload(t *testing.T, id, eventType string, object map[string]interface{}) []byte {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"created":     time.Now().Unix(),
+		"data":        map[string]interface{}{"object": object},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func signStripePayload(payload []byte, secret string, at time.Time) string {
+	return webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    secret,
+		Timestamp: at,
+	}).Header
+}
+
+func TestStripeParseWebhookSucceeded(t *testing.T) {
+	provider := NewStripeProvider("sk_test", testWebhookSecret)
+	payload := stripeEventPayload(t, "evt_1", "payment_intent.succeeded", map[string]interface{}{
+		"id":       "pi_1",
+		"object":   "payment_intent",
+		"amount":   50000,
+		"currency": "usd",
+		"status":   "succeeded",
+		"metadata": map[string]string{"type": "view_credits", "payment_id": "p1"},
+	})
+
+	event, err := provider.ParseWebhook(payload, signStripePayload(payload, testWebhookSecret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "evt_1" || event.Type != EventPaymentSucceeded || event.IntentID != "pi_1" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.Intent.Amount != 50000 || event.Intent.Status != IntentStatusSucceeded || event.Intent.Metadata["payment_id"] != "p1" {
+		t.Fatalf("unexpected intent %+v", event.Intent)
+	}
+}
+
+func TestStripeParseWebhookChargeRefunded(t *testing.T) {
+	provider := NewStripeProvider("sk_test", testWebhookSecret)
+	payload := stripeEventPayload(t, "evt_2", "charge.refunded", map[string]interface{}{
+		"id":              "ch_1",
+		"object":          "charge",
+		"payment_intent":  "pi_1",
+		"amount_refunded": 20000,
+		"refunded":        false,
+	})
+
+	event, err := provider.ParseWebhook(payload, signStripePayload(payload, testWebhookSecret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventChargeRefunded || event.IntentID != "pi_1" || event.AmountRefunded != 20000 || event.FullyRefunded {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+func TestStripeParseWebhookRejectsBadSignatures(t *testing.T) {
+	provider := NewStripeProvider("sk_test", testWebhookSecret)
+	payload := stripeEventPayload(t, "evt_3", "payment_intent.succeeded", map[string]interface{}{
+		"id":     "pi_1",
+		"object": "payment_intent",
+	})
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+	}{
+		{"wrong secret", payload, signStripePayload(payload, "whsec_other", time.Now())},
+		{"tampered payload", append([]byte(" "), payload...), signStripePayload(payload, testWebhookSecret, time.Now())},
+		{"stale timestamp", payload, signStripePayload(payload, testWebhookSecret, time.Now().Add(-time.Hour))},
+		{"missing signature", payload, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.ParseWebhook(tt.payload, tt.signature)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("got %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
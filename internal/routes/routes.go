@@ -1,11 +1,15 @@
 package routes
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/handlers"
+	"github.com/ukuvago/angelvault/internal/mail"
 	"github.com/ukuvago/angelvault/internal/middleware"
 	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/payments"
 	"github.com/ukuvago/angelvault/internal/services"
 )
 
@@ -15,6 +19,7 @@ type Router struct {
 
 	// Services
 	authService      *services.AuthService
+	emailService     *services.EmailService
 	oauthService     *services.OAuthService
 	paymentService   *services.PaymentService
 	ndaService       *services.NDAService
@@ -23,6 +28,15 @@ type Router struct {
 	auditService     *services.AuditService
 	meetingService   *services.MeetingService
 	readinessService *services.ReadinessService
+	offerService     *services.OfferService
+	termSheetService *services.TermSheetService
+	commissionService *services.CommissionService
+	documentService  *services.DocumentService
+	pricingService   *services.PricingService
+	ledgerService    *services.LedgerService
+	currencyService  *services.CurrencyService
+	organizationService *services.OrganizationService
+	scheduler        *services.Scheduler
 
 	// Handlers
 	authHandler      *handlers.AuthHandler
@@ -34,9 +48,19 @@ type Router struct {
 	auditHandler     *handlers.AuditHandler
 	meetingHandler   *handlers.MeetingHandler
 	readinessHandler *handlers.ReadinessHandler
+	offerHandler     *handlers.OfferHandler
+	termSheetHandler *handlers.TermSheetHandler
+	commissionHandler *handlers.CommissionHandler
+	documentHandler  *handlers.DocumentHandler
+	pricingHandler   *handlers.PricingHandler
+	ledgerHandler    *handlers.LedgerHandler
+	currencyHandler  *handlers.CurrencyHandler
+	organizationHandler *handlers.OrganizationHandler
 }
 
-func NewRouter(cfg *config.Config) *Router {
+// NewRouter wires services and handlers. provider is the payment processor, or
+// nil for demo mode; mailer delivers email, or is nil when mail is disabled.
+func NewRouter(cfg *config.Config, provider payments.Provider, mailer mail.Mailer) *Router {
 	// Set Gin mode
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
@@ -45,15 +69,43 @@ func NewRouter(cfg *config.Config) *Router {
 	engine := gin.New()
 
 	// Initialize services
-	authService := services.NewAuthService(cfg)
+	emailService := services.NewEmailService(cfg, mailer)
+	auditService := services.NewAuditService(cfg)
+	authService := services.NewAuthService(cfg, auditService, emailService)
 	oauthService := services.NewOAuthService(cfg)
-	paymentService := services.NewPaymentService(cfg)
-	ndaService := services.NewNDAService(cfg)
+	pricingService := services.NewPricingService(cfg, auditService)
+	paymentService := services.NewPaymentService(cfg, auditService, pricingService, provider)
+	ndaService := services.NewNDAService(cfg, auditService, emailService)
 	projectService := services.NewProjectService(cfg, paymentService, ndaService)
-	adminService := services.NewAdminService(cfg)
-	auditService := services.NewAuditService(cfg)
-	meetingService := services.NewMeetingService(cfg, ndaService)
+	adminService := services.NewAdminService(cfg, auditService, emailService)
+	meetingService := services.NewMeetingService(cfg, ndaService, emailService)
 	readinessService := services.NewReadinessService(cfg)
+	offerService := services.NewOfferService(cfg, auditService)
+	termSheetService := services.NewTermSheetService(cfg, auditService)
+	commissionService := services.NewCommissionService(cfg, auditService)
+	documentService := services.NewDocumentService(cfg)
+	ledgerService := services.NewLedgerService(cfg)
+	currencyService := services.NewCurrencyService(cfg, auditService)
+	organizationService := services.NewOrganizationService(cfg, auditService)
+
+	// Without a mailer, leave reminders unclaimed so they go out once one is set up
+	if mailer != nil {
+		paymentService.OnCreditsExpiring(emailService.SendCreditsExpiring)
+	}
+
+	// Background sweeps
+	scheduler := services.NewScheduler(cfg.SweepInterval)
+	scheduler.Register("meeting_requests.expire", meetingService.ExpirePendingRequests)
+	scheduler.Register("term_sheets.expire", termSheetService.ExpireUnsignedTermSheets)
+	scheduler.Register("commissions.overdue", commissionService.MarkOverdueInvoices)
+	scheduler.Register("payments.credit_reminders", paymentService.SendCreditExpiryReminders)
+	scheduler.Register("payments.credits_expire", paymentService.ExpireCredits)
+	scheduler.Register("promo_codes.release_abandoned", pricingService.ReleaseAbandonedPromos)
+	scheduler.Register("sessions.purge", authService.PurgeSessions)
+	scheduler.Register("passkeys.purge_challenges", authService.PurgePasskeyChallenges)
+	scheduler.Register("two_factor_challenges.purge", authService.PurgeTwoFactorChallenges)
+	scheduler.Register("oauth_states.purge", oauthService.PurgeStates)
+	scheduler.Register("login_codes.purge", authService.PurgeLoginCodes)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, oauthService, cfg)
@@ -65,11 +117,20 @@ func NewRouter(cfg *config.Config) *Router {
 	auditHandler := handlers.NewAuditHandler(auditService)
 	meetingHandler := handlers.NewMeetingHandler(meetingService)
 	readinessHandler := handlers.NewReadinessHandler(readinessService)
+	offerHandler := handlers.NewOfferHandler(offerService)
+	termSheetHandler := handlers.NewTermSheetHandler(termSheetService)
+	commissionHandler := handlers.NewCommissionHandler(commissionService)
+	documentHandler := handlers.NewDocumentHandler(documentService)
+	pricingHandler := handlers.NewPricingHandler(pricingService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	currencyHandler := handlers.NewCurrencyHandler(currencyService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService)
 
 	return &Router{
 		config:           cfg,
 		engine:           engine,
 		authService:      authService,
+		emailService:     emailService,
 		oauthService:     oauthService,
 		paymentService:   paymentService,
 		ndaService:       ndaService,
@@ -78,6 +139,15 @@ func NewRouter(cfg *config.Config) *Router {
 		auditService:     auditService,
 		meetingService:   meetingService,
 		readinessService: readinessService,
+		offerService:     offerService,
+		termSheetService: termSheetService,
+		commissionService: commissionService,
+		documentService:  documentService,
+		pricingService:   pricingService,
+		ledgerService:    ledgerService,
+		currencyService:  currencyService,
+		organizationService: organizationService,
+		scheduler:        scheduler,
 		authHandler:      authHandler,
 		projectHandler:   projectHandler,
 		paymentHandler:   paymentHandler,
@@ -87,9 +157,27 @@ func NewRouter(cfg *config.Config) *Router {
 		auditHandler:     auditHandler,
 		meetingHandler:   meetingHandler,
 		readinessHandler: readinessHandler,
+		offerHandler:     offerHandler,
+		termSheetHandler: termSheetHandler,
+		commissionHandler: commissionHandler,
+		documentHandler:  documentHandler,
+		pricingHandler:   pricingHandler,
+		ledgerHandler:    ledgerHandler,
+		currencyHandler:  currencyHandler,
+		organizationHandler: organizationHandler,
 	}
 }
 
+// StartBackgroundJobs starts periodic expiry sweeps until ctx is cancelled
+func (r *Router) StartBackgroundJobs(ctx context.Context) {
+	r.scheduler.Start(ctx)
+}
+
+// WaitForEmails blocks until emails being sent in the background are delivered
+func (r *Router) WaitForEmails() {
+	r.emailService.Wait()
+}
+
 func (r *Router) Setup() *gin.Engine {
 	// Global middleware
 	r.engine.Use(gin.Recovery())
@@ -145,9 +233,12 @@ func (r *Router) setupPublicRoutes(api *gin.RouterGroup) {
 
 	// Stripe config (public)
 	api.GET("/config/stripe", r.paymentHandler.GetStripeConfig)
+	api.GET("/config/currencies", r.currencyHandler.ListCurrencies)
 
-	// Stripe webhook (no auth, but verified by signature)
-	api.POST("/webhooks/stripe", r.paymentHandler.HandleStripeWebhook)
+	// Payment provider webhooks (no auth, but verified by signature). The Stripe
+	// path is kept for existing dashboard endpoints.
+	api.POST("/webhooks/stripe", r.paymentHandler.HandleWebhook)
+	api.POST("/webhooks/payments", r.paymentHandler.HandleWebhook)
 }
 
 func (r *Router) setupAuthRoutes(api *gin.RouterGroup) {
@@ -157,12 +248,21 @@ func (r *Router) setupAuthRoutes(api *gin.RouterGroup) {
 		// Standard auth
 		auth.POST("/register", r.authHandler.Register)
 		auth.POST("/login", r.authHandler.Login)
+		auth.POST("/refresh", r.authHandler.Refresh)
+
+		// Second step of a sign-in challenged for two-factor authentication
+		auth.POST("/2fa/login", r.authHandler.TwoFactorLogin)
+		auth.POST("/2fa/login/setup", r.authHandler.TwoFactorLoginSetup)
+		auth.POST("/2fa/login/activate", r.authHandler.TwoFactorLoginActivate)
+		auth.POST("/passkeys/login/begin", r.authHandler.BeginPasskeyLogin)
+		auth.POST("/passkeys/login/finish", r.authHandler.FinishPasskeyLogin)
 		auth.POST("/password/reset-request", r.authHandler.RequestPasswordReset)
 		auth.POST("/password/reset", r.authHandler.ResetPassword)
 		auth.GET("/verify-email", r.authHandler.VerifyEmail)
 
 		// OAuth providers
 		auth.GET("/providers", r.authHandler.GetOAuthProviders)
+		auth.POST("/oauth/exchange", r.authHandler.ExchangeLoginCode)
 
 		// Google OAuth
 		auth.GET("/google", r.authHandler.GoogleAuthURL)
@@ -175,6 +275,10 @@ func (r *Router) setupAuthRoutes(api *gin.RouterGroup) {
 		// Apple OAuth
 		auth.GET("/apple", r.authHandler.AppleAuthURL)
 		auth.POST("/apple/callback", r.authHandler.AppleCallback)
+
+		// OpenID Connect providers from OIDC_PROVIDERS
+		auth.GET("/oidc/:provider", r.authHandler.OIDCAuthURL)
+		auth.GET("/oidc/:provider/callback", r.authHandler.OIDCCallback)
 	}
 
 	// Protected auth routes
@@ -182,8 +286,28 @@ func (r *Router) setupAuthRoutes(api *gin.RouterGroup) {
 	authProtected.Use(middleware.AuthMiddleware(r.config))
 	{
 		authProtected.GET("/me", r.authHandler.GetCurrentUser)
+		authProtected.POST("/logout", r.authHandler.Logout)
 		authProtected.PUT("/profile", r.authHandler.UpdateProfile)
 		authProtected.PUT("/password", r.authHandler.ChangePassword)
+
+		// Two-factor authentication
+		authProtected.GET("/2fa", r.authHandler.GetTwoFactorStatus)
+		authProtected.POST("/2fa/setup", r.authHandler.SetupTwoFactor)
+		authProtected.POST("/2fa/enable", r.authHandler.EnableTwoFactor)
+		authProtected.POST("/2fa/disable", r.authHandler.DisableTwoFactor)
+		authProtected.POST("/2fa/recovery-codes", r.authHandler.RegenerateRecoveryCodes)
+
+		// Passkeys
+		authProtected.GET("/passkeys", r.authHandler.ListPasskeys)
+		authProtected.POST("/passkeys/register/begin", r.authHandler.BeginPasskeyRegistration)
+		authProtected.POST("/passkeys/register/finish", r.authHandler.FinishPasskeyRegistration)
+		authProtected.PUT("/passkeys/:id", r.authHandler.RenamePasskey)
+		authProtected.DELETE("/passkeys/:id", r.authHandler.DeletePasskey)
+
+		// Linked sign-in providers
+		authProtected.GET("/identities", r.authHandler.ListIdentities)
+		authProtected.POST("/identities/:provider/link", r.authHandler.LinkIdentity)
+		authProtected.DELETE("/identities/:id", r.authHandler.UnlinkIdentity)
 	}
 }
 
@@ -223,6 +347,25 @@ func (r *Router) setupProtectedRoutes(api *gin.RouterGroup) {
 		developer.POST("/meetings/:id/complete", r.meetingHandler.CompleteMeeting)
 		developer.GET("/meetings/:id/messages", r.meetingHandler.GetMessages)
 		developer.POST("/meetings/:id/messages", r.meetingHandler.SendMessage)
+
+		// Investment offers (from investors)
+		developer.GET("/offers", r.offerHandler.GetDeveloperOffers)
+		developer.GET("/offers/:id", r.offerHandler.GetOffer)
+		developer.GET("/offers/:id/revisions", r.offerHandler.GetOfferRevisions)
+		developer.POST("/offers/:id/counter", r.offerHandler.CounterOffer)
+		developer.POST("/offers/:id/accept", r.offerHandler.AcceptOffer)
+		developer.POST("/offers/:id/reject", r.offerHandler.RejectOffer)
+
+		// Term sheets (investor signs first, founder countersigns)
+		developer.POST("/term-sheets", r.termSheetHandler.GenerateTermSheet)
+		developer.GET("/term-sheets", r.termSheetHandler.GetDeveloperTermSheets)
+		developer.GET("/term-sheets/:id", r.termSheetHandler.GetTermSheet)
+		developer.GET("/term-sheets/:id/document", r.termSheetHandler.GetTermSheetDocument)
+		developer.POST("/term-sheets/:id/sign", r.termSheetHandler.FounderSign)
+
+		// Commission invoices
+		developer.GET("/commissions", r.commissionHandler.GetDeveloperCommissions)
+		developer.GET("/commissions/:id/pdf", r.documentHandler.DownloadCommissionInvoice)
 	}
 
 	// Investor routes
@@ -238,10 +381,14 @@ func (r *Router) setupProtectedRoutes(api *gin.RouterGroup) {
 		
 		// Payments
 		investor.GET("/payments/status", r.paymentHandler.GetPaymentStatus)
+		investor.GET("/payments/packages", r.pricingHandler.ListPackages)
+		investor.POST("/payments/quote", r.pricingHandler.GetQuote)
 		investor.POST("/payments/create-intent", r.paymentHandler.CreatePaymentIntent)
 		investor.POST("/payments/confirm", r.paymentHandler.ConfirmPayment)
 		investor.GET("/payments/history", r.paymentHandler.GetPaymentHistory)
 		investor.GET("/payments/viewed", r.paymentHandler.GetViewedProjects)
+		investor.GET("/payments/ledger", r.ledgerHandler.GetMyLedger)
+		investor.GET("/payments/:id/receipt", r.documentHandler.DownloadPaymentReceipt)
 
 		// NDA
 		investor.GET("/nda/status", r.ndaHandler.GetMasterNDAStatus)
@@ -265,6 +412,32 @@ func (r *Router) setupProtectedRoutes(api *gin.RouterGroup) {
 		investor.GET("/meetings/:id/messages", r.meetingHandler.GetMessages)
 		investor.POST("/meetings/:id/messages", r.meetingHandler.SendMessage)
 		investor.GET("/messages/unread", r.meetingHandler.GetUnreadCount)
+
+		// Investment offers
+		investor.POST("/offers", r.offerHandler.CreateOffer)
+		investor.GET("/offers", r.offerHandler.GetInvestorOffers)
+		investor.GET("/offers/:id", r.offerHandler.GetOffer)
+		investor.GET("/offers/:id/revisions", r.offerHandler.GetOfferRevisions)
+		investor.POST("/offers/:id/counter", r.offerHandler.CounterOffer)
+		investor.POST("/offers/:id/accept", r.offerHandler.AcceptOffer)
+		investor.POST("/offers/:id/withdraw", r.offerHandler.WithdrawOffer)
+
+		// Term sheets
+		investor.POST("/term-sheets", r.termSheetHandler.GenerateTermSheet)
+		investor.GET("/term-sheets", r.termSheetHandler.GetInvestorTermSheets)
+		investor.GET("/term-sheets/:id", r.termSheetHandler.GetTermSheet)
+		investor.GET("/term-sheets/:id/document", r.termSheetHandler.GetTermSheetDocument)
+		investor.POST("/term-sheets/:id/sign", r.termSheetHandler.InvestorSign)
+		
+		// Organisation: pooled credits, shared unlocks and one master NDA
+		investor.GET("/organization", r.organizationHandler.GetMyOrganization)
+		investor.POST("/organization", r.organizationHandler.CreateOrganization)
+		investor.POST("/organization/members", r.organizationHandler.AddMember)
+		investor.PUT("/organization/members/:id", r.organizationHandler.UpdateMemberRole)
+		investor.DELETE("/organization/members/:id", r.organizationHandler.RemoveMember)
+		investor.POST("/organization/leave", r.organizationHandler.LeaveOrganization)
+		investor.PUT("/organization/signatory", r.organizationHandler.SetSignatory)
+		investor.POST("/organization/nda/sign", r.ndaHandler.SignOrganizationNDA)
 	}
 }
 
@@ -294,6 +467,12 @@ func (r *Router) setupAdminRoutes(api *gin.RouterGroup) {
 		admin.GET("/users", r.adminHandler.ListUsers)
 		admin.GET("/users/:id", r.adminHandler.GetUser)
 		admin.PUT("/users/:id", r.adminHandler.UpdateUser)
+		admin.POST("/users/:id/sessions/revoke", r.adminHandler.RevokeUserSessions)
+		admin.POST("/users/:id/2fa/reset", r.adminHandler.ResetTwoFactor)
+		admin.GET("/users/:id/credits", r.ledgerHandler.GetUserCredits)
+		admin.POST("/users/:id/credits/grant", r.paymentHandler.GrantCredits)
+		admin.POST("/users/:id/credits/revoke", r.paymentHandler.RevokeCredits)
+		admin.POST("/users/:id/unlocks", r.paymentHandler.GrantComplimentaryUnlock)
 		admin.POST("/users/developer", r.adminHandler.CreateDeveloper)
 		
 		// Project management
@@ -320,6 +499,41 @@ func (r *Router) setupAdminRoutes(api *gin.RouterGroup) {
 		// Project readiness verification
 		admin.GET("/projects/:id/readiness", r.readinessHandler.GetProjectReadiness)
 		admin.POST("/projects/:id/readiness/verify", r.readinessHandler.VerifyProjectReadiness)
+		
+		// Funds confirmation (raises the platform commission invoice)
+		admin.POST("/term-sheets/:id/confirm-funds", r.commissionHandler.ConfirmFunds)
+		admin.POST("/term-sheets/:id/reinvoice", r.commissionHandler.ReissueInvoice)
+		
+		// Commission invoices
+		admin.GET("/commissions", r.commissionHandler.ListCommissions)
+		admin.GET("/commissions/stats", r.commissionHandler.GetCommissionStats)
+		admin.GET("/commissions/:id", r.commissionHandler.GetCommission)
+		admin.GET("/commissions/:id/pdf", r.documentHandler.DownloadCommissionInvoice)
+		admin.POST("/commissions/:id/send", r.commissionHandler.SendInvoice)
+		admin.POST("/commissions/:id/paid", r.commissionHandler.MarkPaid)
+		admin.POST("/commissions/:id/cancel", r.commissionHandler.CancelInvoice)
+		
+		// Payments
+		admin.POST("/payments/:id/refund", r.paymentHandler.RefundPayment)
+		admin.GET("/credits/reconciliation", r.ledgerHandler.Reconcile)
+		
+		// Credit packages and promo codes
+		admin.GET("/credit-packages", r.pricingHandler.AdminListPackages)
+		admin.POST("/credit-packages", r.pricingHandler.CreatePackage)
+		admin.PUT("/credit-packages/:id", r.pricingHandler.UpdatePackage)
+		admin.DELETE("/credit-packages/:id", r.pricingHandler.DeletePackage)
+		admin.GET("/promo-codes", r.pricingHandler.ListPromoCodes)
+		admin.POST("/promo-codes", r.pricingHandler.CreatePromoCode)
+		admin.PUT("/promo-codes/:id", r.pricingHandler.UpdatePromoCode)
+		admin.GET("/promo-codes/:id/redemptions", r.pricingHandler.GetPromoRedemptions)
+		
+		// Currencies and revenue reporting
+		admin.GET("/exchange-rates", r.currencyHandler.ListExchangeRates)
+		admin.POST("/exchange-rates", r.currencyHandler.SetExchangeRate)
+		admin.GET("/reports/revenue", r.currencyHandler.GetRevenueReport)
+		
+		// Investor organisations
+		admin.GET("/organizations", r.organizationHandler.ListOrganizations)
 	}
 }
 
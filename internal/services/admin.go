@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -9,14 +10,17 @@ import (
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
 )
 
 type AdminService struct {
-	config *config.Config
+	config       *config.Config
+	auditService *AuditService
+	emailService *EmailService
 }
 
-func NewAdminService(cfg *config.Config) *AdminService {
-	return &AdminService{config: cfg}
+func NewAdminService(cfg *config.Config, auditSvc *AuditService, emailSvc *EmailService) *AdminService {
+	return &AdminService{config: cfg, auditService: auditSvc, emailService: emailSvc}
 }
 
 // ========================================
@@ -91,9 +95,110 @@ func (s *AdminService) UpdateUser(userID uuid.UUID, firstName, lastName, company
 		return nil, err
 	}
 
+	if !user.IsActive {
+		if _, err := revokeUserSessions(db, user.ID, models.SessionRevokedDeactivated, nil); err != nil {
+			return nil, err
+		}
+	}
+
 	return &user, nil
 }
 
+// RevokeUserSessions signs a user out of every device. Their access tokens stop
+// working immediately and their refresh tokens can no longer be used.
+func (s *AdminService) RevokeUserSessions(adminID, userID uuid.UUID, ipAddress, userAgent string) (int64, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return 0, errors.New("admin not found")
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return 0, errors.New("user not found")
+	}
+
+	revoked, err := revokeUserSessions(db, user.ID, models.SessionRevokedAdmin, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	s.auditService.LogAction(
+		&admin.ID,
+		admin.Email,
+		admin.Role,
+		models.AuditActionUserSessionsRevoked,
+		"user",
+		&user.ID,
+		user.Email,
+		fmt.Sprintf("Signed %s out of %d sessions", user.Email, revoked),
+		map[string]interface{}{
+			"revoked":     revoked,
+			"target_role": user.Role,
+		},
+		ipAddress,
+		userAgent,
+	)
+
+	return revoked, nil
+}
+
+// ResetTwoFactor removes a user's TOTP secret and recovery codes when they have
+// lost their authenticator, and signs them out everywhere. Admins sign in again
+// straight into enrollment. An admin cannot reset their own 2FA.
+func (s *AdminService) ResetTwoFactor(adminID, userID uuid.UUID, reason, ipAddress, userAgent string) error {
+	db := database.GetDB()
+
+	if adminID == userID {
+		return errors.New("you cannot reset your own two-factor authentication")
+	}
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return errors.New("admin not found")
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if !user.TOTPEnabled && user.TOTPSecret == "" {
+		return errors.New("user does not have two-factor authentication set up")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		_, err := revokeUserSessions(tx, user.ID, models.SessionRevokedTwoFactorReset, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.auditService.LogAction(
+		&admin.ID,
+		admin.Email,
+		admin.Role,
+		models.AuditActionUserTwoFactorReset,
+		"user",
+		&user.ID,
+		user.Email,
+		"Reset two-factor authentication for "+user.Email+": "+reason,
+		map[string]interface{}{
+			"reason":      reason,
+			"was_enabled": user.TOTPEnabled,
+			"target_role": user.Role,
+		},
+		ipAddress,
+		userAgent,
+	)
+
+	return nil
+}
+
 // CreateDeveloperUser creates a new developer account (for admin to create on behalf of founders)
 func (s *AdminService) CreateDeveloperUser(email, firstName, lastName, companyName, password string) (*models.User, error) {
 	db := database.GetDB()
@@ -184,6 +289,12 @@ func (s *AdminService) UpdateAdminUser(adminID uuid.UUID, firstName, lastName st
 		return nil, err
 	}
 
+	if !user.IsActive {
+		if _, err := revokeUserSessions(db, user.ID, models.SessionRevokedDeactivated, nil); err != nil {
+			return nil, err
+		}
+	}
+
 	return &user, nil
 }
 
@@ -200,9 +311,14 @@ func (s *AdminService) DeleteAdminUser(adminID uuid.UUID) error {
 		return errors.New("cannot delete the last active admin")
 	}
 
-	return db.Model(&models.User{}).
+	if err := db.Model(&models.User{}).
 		Where("id = ? AND role = ?", adminID, models.RoleAdmin).
-		Update("is_active", false).Error
+		Update("is_active", false).Error; err != nil {
+		return err
+	}
+
+	_, err := revokeUserSessions(db, adminID, models.SessionRevokedDeactivated, nil)
+	return err
 }
 
 // ListAdminUsers returns all admin users
@@ -266,6 +382,8 @@ type AdminCreateProjectRequest struct {
 	MaxInvestment   int64     `json:"max_investment"`
 	EquityOffered   float64   `json:"equity_offered"`
 	ValuationCap    int64     `json:"valuation_cap"`
+	FundingGoal     int64     `json:"funding_goal"`
+	Currency        string    `json:"currency"` // Defaults to the platform currency
 	
 	// Contact
 	ContactEmail    string    `json:"contact_email" binding:"required,email"`
@@ -352,11 +470,15 @@ func (s *AdminService) CreateProjectForDeveloper(req *AdminCreateProjectRequest)
 		MaxInvestment:    req.MaxInvestment,
 		EquityOffered:    req.EquityOffered,
 		ValuationCap:     req.ValuationCap,
+		FundingGoal:      req.FundingGoal,
 		ContactEmail:     req.ContactEmail,
 		ContactPhone:     req.ContactPhone,
 		WebsiteURL:       req.WebsiteURL,
 		Status:           status,
 	}
+	if err := setProjectCurrency(s.config, project, req.Currency); err != nil {
+		return nil, err
+	}
 
 	if err := db.Create(project).Error; err != nil {
 		return nil, err
@@ -411,9 +533,13 @@ func (s *AdminService) UpdateProject(projectID uuid.UUID, req *AdminCreateProjec
 	project.MaxInvestment = req.MaxInvestment
 	project.EquityOffered = req.EquityOffered
 	project.ValuationCap = req.ValuationCap
+	project.FundingGoal = req.FundingGoal
 	project.ContactEmail = req.ContactEmail
 	project.ContactPhone = req.ContactPhone
 	project.WebsiteURL = req.WebsiteURL
+	if err := setProjectCurrency(s.config, &project, req.Currency); err != nil {
+		return nil, err
+	}
 
 	if req.Status != "" {
 		project.Status = req.Status
@@ -474,6 +600,8 @@ func (s *AdminService) ApproveProject(projectID uuid.UUID) (*models.Project, err
 		return nil, err
 	}
 
+	s.emailService.SendProjectApproved(&project)
+
 	return &project, nil
 }
 
@@ -497,6 +625,8 @@ func (s *AdminService) RejectProject(projectID uuid.UUID, reason string) (*model
 		return nil, err
 	}
 
+	s.emailService.SendProjectRejected(&project)
+
 	return &project, nil
 }
 
@@ -801,7 +931,10 @@ type AdminDashboardStats struct {
 	ApprovedProjects int64 `json:"approved_projects"`
 	FundedProjects   int64 `json:"funded_projects"`
 	TotalPayments    int64 `json:"total_payments"`
-	TotalRevenue     int64 `json:"total_revenue"` // cents
+	TotalRevenue     int64 `json:"total_revenue"` // Minor units of RevenueCurrency
+	RevenueCurrency  string `json:"revenue_currency"`
+	MissingRates     []string `json:"missing_rates"` // Currencies with no FX rate, left out of TotalRevenue
+	RevenueComplete  bool   `json:"revenue_complete"`
 	TotalNDAs        int64 `json:"total_ndas"`
 }
 
@@ -818,18 +951,22 @@ func (s *AdminService) GetDashboardStats() (*AdminDashboardStats, error) {
 	db.Model(&models.Project{}).Where("status = ?", models.ProjectStatusPending).Count(&stats.PendingProjects)
 	db.Model(&models.Project{}).Where("status = ?", models.ProjectStatusApproved).Count(&stats.ApprovedProjects)
 	db.Model(&models.Project{}).Where("status = ?", models.ProjectStatusFunded).Count(&stats.FundedProjects)
-	db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusCompleted).Count(&stats.TotalPayments)
+	db.Model(&models.Payment{}).Where("status = ? AND type = ?", models.PaymentStatusCompleted, models.PaymentTypePurchase).Count(&stats.TotalPayments)
 	db.Model(&models.NDA{}).Count(&stats.TotalNDAs)
 
-	// Sum revenue
-	var revenue struct {
-		Total int64
+	// Sum revenue in the reporting currency
+	stats.RevenueCurrency = s.config.ReportingCurrency
+	converter, err := newReportingConverter(db, stats.RevenueCurrency)
+	if err != nil {
+		return nil, err
+	}
+	stats.TotalRevenue, err = converter.sum(
+		db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusCompleted), "amount")
+	if err != nil {
+		return nil, err
 	}
-	db.Model(&models.Payment{}).
-		Where("status = ?", models.PaymentStatusCompleted).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&revenue)
-	stats.TotalRevenue = revenue.Total
+	stats.MissingRates = converter.missingRates()
+	stats.RevenueComplete = len(stats.MissingRates) == 0
 
 	return stats, nil
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
 )
 
 type AuditService struct {
@@ -135,6 +136,85 @@ func (s *AuditService) LogPaymentAction(
 	)
 }
 
+// LogOfferAction logs investment offer actions
+func (s *AuditService) LogOfferAction(
+	user *models.User,
+	action models.AuditAction,
+	offer *models.InvestmentOffer,
+	project *models.Project,
+	description string,
+	ipAddress string,
+	userAgent string,
+) error {
+	metadata := map[string]interface{}{
+		"amount":      offer.Amount,
+		"currency":    offer.Currency,
+		"investor_id": offer.InvestorID,
+		"project_id":  offer.ProjectID,
+		"status":      offer.Status,
+	}
+
+	entityName := ""
+	if project != nil {
+		entityName = project.Title
+	}
+
+	return s.LogAction(
+		&user.ID,
+		user.Email,
+		user.Role,
+		action,
+		"offer",
+		&offer.ID,
+		entityName,
+		description,
+		metadata,
+		ipAddress,
+		userAgent,
+	)
+}
+
+// LogTermSheetAction logs term sheet lifecycle actions
+func (s *AuditService) LogTermSheetAction(
+	user *models.User,
+	action models.AuditAction,
+	termSheet *models.TermSheet,
+	project *models.Project,
+	description string,
+	ipAddress string,
+	userAgent string,
+) error {
+	metadata := map[string]interface{}{
+		"offer_id":          termSheet.OfferID,
+		"investment_amount": termSheet.InvestmentAmount,
+		"currency":          termSheet.Currency,
+		"safe_type":         termSheet.SAFEType,
+		"status":            termSheet.Status,
+	}
+	if termSheet.DocumentHash != "" {
+		metadata["document_hash"] = termSheet.DocumentHash
+	}
+
+	entityName := ""
+	if project != nil {
+		entityName = project.Title
+	}
+
+	return s.LogAction(
+		&user.ID,
+		user.Email,
+		user.Role,
+		action,
+		"term_sheet",
+		&termSheet.ID,
+		entityName,
+		description,
+		metadata,
+		ipAddress,
+		userAgent,
+	)
+}
+
 // LogInvestorAccess logs when an investor accesses the platform
 func (s *AuditService) LogInvestorAccess(investorID uuid.UUID, ipAddress, userAgent string) (*models.InvestorAccessLog, error) {
 	db := database.GetDB()
@@ -395,35 +475,32 @@ func (s *AuditService) GetDashboardStats() (*models.DashboardStats, error) {
 	db.Model(&models.Project{}).Where("created_at >= ?", weekAgo).Count(&stats.NewProjectsThisWeek)
 
 	// Payment stats
-	db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusCompleted).Count(&stats.TotalPayments)
-
-	var revenueTotal struct{ Total int64 }
-	db.Model(&models.Payment{}).
-		Where("status = ?", models.PaymentStatusCompleted).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&revenueTotal)
-	stats.TotalRevenue = revenueTotal.Total
-
-	var revenueToday struct{ Total int64 }
-	db.Model(&models.Payment{}).
-		Where("status = ? AND completed_at >= ?", models.PaymentStatusCompleted, today).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&revenueToday)
-	stats.RevenueToday = revenueToday.Total
-
-	var revenueWeek struct{ Total int64 }
-	db.Model(&models.Payment{}).
-		Where("status = ? AND completed_at >= ?", models.PaymentStatusCompleted, weekAgo).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&revenueWeek)
-	stats.RevenueThisWeek = revenueWeek.Total
-
-	var revenueMonth struct{ Total int64 }
-	db.Model(&models.Payment{}).
-		Where("status = ? AND completed_at >= ?", models.PaymentStatusCompleted, monthAgo).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&revenueMonth)
-	stats.RevenueThisMonth = revenueMonth.Total
+	db.Model(&models.Payment{}).Where("status = ? AND type = ?", models.PaymentStatusCompleted, models.PaymentTypePurchase).Count(&stats.TotalPayments)
+
+	// Revenue is normalised into the reporting currency
+	stats.RevenueCurrency = s.config.ReportingCurrency
+	completed := func() *gorm.DB {
+		return db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusCompleted)
+	}
+	converter, err := newReportingConverter(db, stats.RevenueCurrency)
+	if err != nil {
+		return nil, err
+	}
+	for _, total := range []struct {
+		into  *int64
+		query *gorm.DB
+	}{
+		{&stats.TotalRevenue, completed()},
+		{&stats.RevenueToday, completed().Where("completed_at >= ?", today)},
+		{&stats.RevenueThisWeek, completed().Where("completed_at >= ?", weekAgo)},
+		{&stats.RevenueThisMonth, completed().Where("completed_at >= ?", monthAgo)},
+	} {
+		if *total.into, err = converter.sum(total.query, "amount"); err != nil {
+			return nil, err
+		}
+	}
+	stats.MissingRates = converter.missingRates()
+	stats.RevenueComplete = len(stats.MissingRates) == 0
 
 	// View stats
 	db.Model(&models.ProjectViewLog{}).Count(&stats.TotalProjectViews)
@@ -512,18 +589,14 @@ func (s *AuditService) GetInvestorDashboardStats(investorID uuid.UUID) (*models.
 	db := database.GetDB()
 	stats := &models.InvestorDashboardStats{}
 
-	// Get active payment and credit info
-	var payment models.Payment
-	err := db.Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-		investorID, models.PaymentStatusCompleted).
-		Order("created_at DESC").
-		First(&payment).Error
+	// Credit info from the ledger
+	summary := creditSummary(db, investorID)
 
-	if err == nil {
-		stats.TotalCredits = payment.ProjectsTotal
-		stats.UsedCredits = payment.ProjectsTotal - payment.ProjectsRemaining
-		stats.RemainingCredits = payment.ProjectsRemaining
-		stats.CanViewMore = payment.ProjectsRemaining > 0
+	if summary.Balance > 0 {
+		stats.TotalCredits = summary.Credited
+		stats.UsedCredits = summary.Used
+		stats.RemainingCredits = summary.Balance
+		stats.CanViewMore = true
 		stats.NeedsPayment = false
 	} else {
 		stats.NeedsPayment = true
@@ -550,8 +623,7 @@ func (s *AuditService) GetInvestorDashboardStats(investorID uuid.UUID) (*models.
 	stats.OffersAccepted = int(offersAccepted)
 
 	// NDA status
-	var masterNDA models.NDA
-	if err := db.Where("investor_id = ?", investorID).Order("signed_at DESC").First(&masterNDA).Error; err == nil {
+	if masterNDA, err := latestMasterNDA(db, investorID); err == nil {
 		stats.HasMasterNDA = true
 		stats.MasterNDAValid = masterNDA.IsValid()
 	}
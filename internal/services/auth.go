@@ -15,14 +15,20 @@ import (
 	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/middleware"
 	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
 )
 
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = time.Hour
+
 type AuthService struct {
-	config *config.Config
+	config       *config.Config
+	auditService *AuditService
+	emailService *EmailService
 }
 
-func NewAuthService(cfg *config.Config) *AuthService {
-	return &AuthService{config: cfg}
+func NewAuthService(cfg *config.Config, auditSvc *AuditService, emailSvc *EmailService) *AuthService {
+	return &AuthService{config: cfg, auditService: auditSvc, emailService: emailSvc}
 }
 
 // RegisterRequest represents registration input
@@ -42,13 +48,23 @@ type LoginRequest struct {
 }
 
 // AuthResponse is returned after successful auth
+//
+// When the user needs a second factor, no tokens are issued yet: the response
+// carries a challenge token to complete at /auth/2fa/login instead.
 type AuthResponse struct {
-	Token string              `json:"token"`
-	User  models.UserResponse `json:"user"`
+	Token        string              `json:"token,omitempty"`         // Short-lived access token
+	RefreshToken string              `json:"refresh_token,omitempty"` // Exchanged at /auth/refresh; rotated on every use
+	ExpiresAt    time.Time           `json:"expires_at"`              // When the access or challenge token expires
+	User         models.UserResponse `json:"user"`
+
+	TwoFactorRequired      bool     `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool     `json:"two_factor_setup_required,omitempty"` // Admins must enroll before signing in
+	ChallengeToken         string   `json:"challenge_token,omitempty"`
+	RecoveryCodes          []string `json:"recovery_codes,omitempty"` // Only when 2FA was just enabled
 }
 
 // Register creates a new user account
-func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
+func (s *AuthService) Register(req *RegisterRequest, ipAddress, userAgent string) (*AuthResponse, error) {
 	db := database.GetDB()
 
 	// Normalize email to lowercase
@@ -91,20 +107,19 @@ func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
 		}
 	}
 
-	// Generate JWT
-	token, err := s.GenerateToken(user)
+	s.emailService.SendVerificationEmail(user)
+
+	// Start a session
+	resp, err := s.startSession(user, ipAddress, userAgent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}, nil
+	return resp, nil
 }
 
 // Login authenticates a user
-func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
+func (s *AuthService) Login(req *LoginRequest, ipAddress, userAgent string) (*AuthResponse, error) {
 	db := database.GetDB()
 
 	// Normalize email to lowercase for case-insensitive lookup
@@ -132,112 +147,106 @@ func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
 	user.LastLoginAt = &now
 	db.Save(&user)
 
-	// Generate JWT
-	token, err := s.GenerateToken(&user)
+	// Start a session, or challenge for a second factor
+	resp, err := s.beginSession(&user, ipAddress, userAgent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}, nil
+	return resp, nil
 }
 
-// LoginWithOAuth authenticates or creates a user via OAuth
-func (s *AuthService) LoginWithOAuth(info *OAuthUserInfo, role models.UserRole) (*AuthResponse, error) {
+// LoginWithOAuth authenticates or creates a user via OAuth and returns a
+// one-time login code for the frontend to exchange for tokens. A provider
+// account is never attached to an existing user just because the emails match;
+// the user must link it while signed in.
+func (s *AuthService) LoginWithOAuth(info *OAuthUserInfo, role models.UserRole) (string, error) {
 	db := database.GetDB()
 
 	// Normalize email to lowercase
 	normalizedEmail := strings.ToLower(strings.TrimSpace(info.Email))
 
-	var user models.User
-
-	// Try to find existing user by OAuth ID
-	err := db.Where("oauth_id = ? AND auth_provider = ?", info.ID, info.Provider).First(&user).Error
-	
+	user, err := findIdentityUser(db, info)
 	if err != nil {
-		// Try to find by email (case-insensitive)
-		err = db.Where("LOWER(email) = ?", normalizedEmail).First(&user).Error
-		
-		if err != nil {
-			// Create new user with normalized email
-			user = models.User{
-				Email:           normalizedEmail,
-				FirstName:       info.FirstName,
-				LastName:        info.LastName,
-				AuthProvider:    info.Provider,
-				OAuthID:         info.ID,
-				ProfileImageURL: info.ProfileImage,
-				EmailVerified:   info.EmailVerified,
-				Role:            role,
-				IsActive:        true,
-			}
+		return "", err
+	}
 
-			if err := db.Create(&user).Error; err != nil {
-				return nil, fmt.Errorf("failed to create user: %w", err)
-			}
+	if user == nil {
+		var existing int64
+		db.Model(&models.User{}).Where("LOWER(email) = ?", normalizedEmail).Count(&existing)
+		if existing > 0 {
+			return "", ErrIdentityNotLinked
+		}
 
-			// Create investor profile if investor
-			if role == models.RoleInvestor {
-				profile := &models.InvestorProfile{
-					UserID: user.ID,
-				}
-				db.Create(profile)
-			}
-		} else {
-			// User exists with this email but different auth provider
-			// Link the OAuth account
-			user.OAuthID = info.ID
-			user.AuthProvider = info.Provider
-			if user.ProfileImageURL == "" {
-				user.ProfileImageURL = info.ProfileImage
+		// Create new user with normalized email
+		user = &models.User{
+			Email:           normalizedEmail,
+			FirstName:       info.FirstName,
+			LastName:        info.LastName,
+			AuthProvider:    info.Provider,
+			ProfileImageURL: info.ProfileImage,
+			EmailVerified:   info.EmailVerified,
+			Role:            role,
+			IsActive:        true,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(user).Error; err != nil {
+				return err
 			}
-			if !user.EmailVerified && info.EmailVerified {
-				user.EmailVerified = true
+			return tx.Create(&models.UserIdentity{
+				UserID:   user.ID,
+				Provider: string(info.Provider),
+				Subject:  info.ID,
+				Email:    normalizedEmail,
+			}).Error
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create user: %w", err)
+		}
+
+		// Create investor profile if investor
+		if role == models.RoleInvestor {
+			profile := &models.InvestorProfile{
+				UserID: user.ID,
 			}
-			db.Save(&user)
+			db.Create(profile)
 		}
 	}
 
 	if !user.IsActive {
-		return nil, errors.New("account is disabled")
+		return "", errors.New("account is disabled")
 	}
 
 	// Update last login
 	now := time.Now()
 	user.LastLoginAt = &now
-	db.Save(&user)
-
-	// Generate JWT
-	token, err := s.GenerateToken(&user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
-	}
+	db.Save(user)
 
-	return &AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}, nil
+	// Tokens are only issued when the code is exchanged, so they never appear
+	// in a redirect URL
+	return issueLoginCode(db, user.ID)
 }
 
-// GenerateToken creates a JWT for the user
-func (s *AuthService) GenerateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(s.config.JWTExpirationHours) * time.Hour)
+// GenerateToken creates a short-lived access token for a session
+func (s *AuthService) GenerateToken(user *models.User, sessionID uuid.UUID) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(time.Duration(s.config.AccessTokenMinutes) * time.Minute)
 
 	claims := &middleware.Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Issuer:    "angelvault",
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
+	signed, err := token.SignedString([]byte(s.config.JWTSecret))
+	return signed, expirationTime, err
 }
 
 // GetUserByID retrieves a user by ID
@@ -344,8 +353,8 @@ func (s *AuthService) UpdateInvestorProfile(userID uuid.UUID, profile *models.In
 	return &existingProfile, nil
 }
 
-// ChangePassword changes user's password
-func (s *AuthService) ChangePassword(userID uuid.UUID, currentPassword, newPassword string) error {
+// ChangePassword changes user's password and signs out the user's other sessions
+func (s *AuthService) ChangePassword(userID, sessionID uuid.UUID, currentPassword, newPassword string) error {
 	db := database.GetDB()
 	
 	var user models.User
@@ -365,11 +374,17 @@ func (s *AuthService) ChangePassword(userID uuid.UUID, currentPassword, newPassw
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 	
-	return db.Save(&user).Error
+	if err := db.Save(&user).Error; err != nil {
+		return err
+	}
+	
+	_, err := revokeUserSessions(db, user.ID, models.SessionRevokedPasswordChange, &sessionID)
+	return err
 }
 
-// RequestPasswordReset initiates password reset
-func (s *AuthService) RequestPasswordReset(email string) (string, error) {
+// RequestPasswordReset emails a reset link to a password account. Unknown
+// addresses succeed silently so callers cannot probe for accounts.
+func (s *AuthService) RequestPasswordReset(email string) error {
 	db := database.GetDB()
 	
 	// Normalize email for case-insensitive lookup
@@ -378,27 +393,28 @@ func (s *AuthService) RequestPasswordReset(email string) (string, error) {
 	var user models.User
 	if err := db.Where("LOWER(email) = ?", normalizedEmail).First(&user).Error; err != nil {
 		// Don't reveal if email exists
-		return "", nil
+		return nil
 	}
 	
 	if user.AuthProvider != models.AuthProviderEmail {
-		return "", nil
+		return nil
 	}
 	
 	token := generateToken()
-	expires := time.Now().Add(time.Hour)
+	expires := time.Now().Add(passwordResetTTL)
 	
 	user.PasswordResetToken = token
 	user.PasswordResetExpires = &expires
 	
 	if err := db.Save(&user).Error; err != nil {
-		return "", fmt.Errorf("failed to save reset token: %w", err)
+		return fmt.Errorf("failed to save reset token: %w", err)
 	}
 	
-	return token, nil
+	s.emailService.SendPasswordReset(&user, token, passwordResetTTL)
+	return nil
 }
 
-// ResetPassword completes password reset
+// ResetPassword completes password reset and signs the user out everywhere
 func (s *AuthService) ResetPassword(token, newPassword string) error {
 	db := database.GetDB()
 	
@@ -418,7 +434,12 @@ func (s *AuthService) ResetPassword(token, newPassword string) error {
 	user.PasswordResetToken = ""
 	user.PasswordResetExpires = nil
 	
-	return db.Save(&user).Error
+	if err := db.Save(&user).Error; err != nil {
+		return err
+	}
+	
+	_, err := revokeUserSessions(db, user.ID, models.SessionRevokedPasswordReset, nil)
+	return err
 }
 
 // VerifyEmail verifies user's email
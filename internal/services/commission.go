@@ -0,0 +1,576 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CommissionService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewCommissionService(cfg *config.Config, auditSvc *AuditService) *CommissionService {
+	return &CommissionService{
+		config:       cfg,
+		auditService: auditSvc,
+	}
+}
+
+// ConfirmFundsInput records the bank transfer for a fully signed term sheet
+type ConfirmFundsInput struct {
+	BankReference string     `json:"bank_reference" binding:"required"`
+	ReceivedAt    *time.Time `json:"received_at"` // Defaults to now
+}
+
+// ConfirmFundsReceived marks a fully signed term sheet as funded, raises the platform
+// commission invoice against the developer and flips the project to funded once its
+// raise target is met. All changes are applied in a single transaction.
+func (s *CommissionService) ConfirmFundsReceived(adminID, termSheetID uuid.UUID, input *ConfirmFundsInput, ipAddress, userAgent string) (*models.TermSheet, *models.PlatformCommission, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, nil, errors.New("admin not found")
+	}
+
+	receivedAt := time.Now()
+	if input.ReceivedAt != nil {
+		if input.ReceivedAt.After(receivedAt) {
+			return nil, nil, errors.New("received date cannot be in the future")
+		}
+		receivedAt = *input.ReceivedAt
+	}
+
+	var termSheet models.TermSheet
+	var commission *models.PlatformCommission
+	var project models.Project
+	projectFunded := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&termSheet, "id = ?", termSheetID).Error; err != nil {
+			return errors.New("term sheet not found")
+		}
+
+		if termSheet.IsFundsReceived() {
+			return errors.New("funds have already been confirmed for this term sheet")
+		}
+		if termSheet.Status != models.TermSheetStatusFullySigned || !termSheet.IsFullySigned() {
+			return errors.New("term sheet must be fully signed before confirming funds")
+		}
+
+		var offer models.InvestmentOffer
+		if err := tx.First(&offer, "id = ?", termSheet.OfferID).Error; err != nil {
+			return errors.New("offer not found")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&project, "id = ?", offer.ProjectID).Error; err != nil {
+			return errors.New("project not found")
+		}
+
+		termSheet.FundsReceivedAt = &receivedAt
+		termSheet.FundsConfirmedBy = &adminID
+		termSheet.BankReference = input.BankReference
+		termSheet.Status = models.TermSheetStatusFundsReceived
+
+		var err error
+		if commission, err = s.raiseInvoice(tx, &termSheet, &project, receivedAt.Year()); err != nil {
+			return err
+		}
+		if err := tx.Save(&termSheet).Error; err != nil {
+			return err
+		}
+
+		// Track confirmed funds and close the round once the target is reached.
+		// Term sheets agreed before offers had to be in the project's currency
+		// are converted at the rate in effect when the funds arrived.
+		raised, err := convertAmountAt(tx, termSheet.InvestmentAmount, termSheet.Currency, project.Currency,
+			s.config.ReportingCurrency, receivedAt)
+		if err != nil {
+			return err
+		}
+		project.AmountRaised += raised
+		updates := map[string]interface{}{"amount_raised": project.AmountRaised}
+		if project.IsFundingGoalMet() && project.Status == models.ProjectStatusApproved {
+			now := time.Now()
+			project.Status = models.ProjectStatusFunded
+			project.FundedAt = &now
+			updates["status"] = project.Status
+			updates["funded_at"] = now
+			projectFunded = true
+		}
+		return tx.Model(&project).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.auditService.LogTermSheetAction(&admin, models.AuditActionTermSheetFundsReceived, &termSheet, &project,
+		fmt.Sprintf("Funds confirmed (bank reference %s)", input.BankReference), ipAddress, userAgent)
+	s.logCommissionAction(&admin, models.AuditActionCommissionInvoiced, commission,
+		fmt.Sprintf("Commission invoice %s raised for %s", commission.InvoiceNumber, commission.FormattedAmount()),
+		ipAddress, userAgent)
+	if projectFunded {
+		s.auditService.LogProjectAction(admin.ID, admin.Email, admin.Role, models.AuditActionProjectFunded, &project,
+			"Project reached its funding goal", ipAddress)
+	}
+
+	return &termSheet, commission, nil
+}
+
+// ReissueInvoice raises a new commission invoice for a funded term sheet whose
+// previous invoice was cancelled, e.g. to correct billing details
+func (s *CommissionService) ReissueInvoice(adminID, termSheetID uuid.UUID, ipAddress, userAgent string) (*models.PlatformCommission, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+
+	var commission *models.PlatformCommission
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var termSheet models.TermSheet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&termSheet, "id = ?", termSheetID).Error; err != nil {
+			return errors.New("term sheet not found")
+		}
+
+		if !termSheet.IsFundsReceived() {
+			return errors.New("funds must be confirmed before invoicing commission")
+		}
+		if termSheet.CommissionStatus != models.CommissionStatusPending || termSheet.CommissionInvoiceID != nil {
+			return errors.New("term sheet already has an open or paid commission invoice")
+		}
+
+		var offer models.InvestmentOffer
+		if err := tx.First(&offer, "id = ?", termSheet.OfferID).Error; err != nil {
+			return errors.New("offer not found")
+		}
+		var project models.Project
+		if err := tx.First(&project, "id = ?", offer.ProjectID).Error; err != nil {
+			return errors.New("project not found")
+		}
+
+		var err error
+		if commission, err = s.raiseInvoice(tx, &termSheet, &project, time.Now().Year()); err != nil {
+			return err
+		}
+		return tx.Model(&termSheet).Updates(map[string]interface{}{
+			"commission_status":     termSheet.CommissionStatus,
+			"commission_invoice_id": termSheet.CommissionInvoiceID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logCommissionAction(&admin, models.AuditActionCommissionInvoiced, commission,
+		fmt.Sprintf("Commission invoice %s reissued for %s", commission.InvoiceNumber, commission.FormattedAmount()),
+		ipAddress, userAgent)
+
+	return commission, nil
+}
+
+// raiseInvoice creates a draft commission invoice for a funded term sheet and
+// links it to the sheet, which the caller saves. The caller holds the term
+// sheet's row lock.
+func (s *CommissionService) raiseInvoice(tx *gorm.DB, termSheet *models.TermSheet, project *models.Project, year int) (*models.PlatformCommission, error) {
+	var developer models.User
+	if err := tx.First(&developer, "id = ?", project.DeveloperID).Error; err != nil {
+		return nil, errors.New("developer not found")
+	}
+
+	invoiceNumber, err := s.nextInvoiceNumber(tx, year)
+	if err != nil {
+		return nil, err
+	}
+
+	commission := &models.PlatformCommission{
+		ID:            uuid.New(),
+		TermSheetID:   termSheet.ID,
+		InvoiceNumber: invoiceNumber,
+		Amount:        termSheet.CommissionAmount, // Rate fixed from Config.CommissionRate at generation
+		Currency:      termSheet.Currency,
+		DeveloperID:   developer.ID,
+		Status:        models.InvoiceStatusDraft,
+		DueDate:       time.Now().AddDate(0, 0, s.config.CommissionPaymentDays),
+	}
+	commission.InvoiceURL = commissionInvoiceURL(s.config, commission.ID)
+	s.applyBillingDetails(tx, commission, &developer, project)
+
+	if err := tx.Create(commission).Error; err != nil {
+		return nil, err
+	}
+
+	termSheet.CommissionStatus = models.CommissionStatusInvoiced
+	termSheet.CommissionInvoiceID = &commission.ID
+	return commission, nil
+}
+
+// ListCommissionsParams for filtering commission invoices
+type ListCommissionsParams struct {
+	Status      string
+	DeveloperID *uuid.UUID
+	Search      string // Invoice number or billing name
+	Overdue     bool   // Only unpaid invoices past their due date
+	Page        int
+	PageSize    int
+}
+
+// ListCommissions returns commission invoices with filtering and pagination.
+// Out-of-range paging values in params are normalised in place.
+func (s *CommissionService) ListCommissions(params *ListCommissionsParams) ([]models.PlatformCommission, int64, error) {
+	db := database.GetDB()
+
+	query := db.Model(&models.PlatformCommission{})
+
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+
+	if params.DeveloperID != nil {
+		query = query.Where("developer_id = ?", *params.DeveloperID)
+	}
+
+	if params.Search != "" {
+		search := "%" + strings.ToLower(params.Search) + "%"
+		query = query.Where("LOWER(invoice_number) LIKE ? OR LOWER(billing_name) LIKE ?", search, search)
+	}
+
+	if params.Overdue {
+		query = query.Where("status IN ? AND due_date < ?",
+			[]string{models.InvoiceStatusSent, models.InvoiceStatusOverdue}, time.Now())
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	var commissions []models.PlatformCommission
+	err := query.
+		Preload("Developer").
+		Preload("TermSheet").
+		Order("created_at DESC").
+		Offset((params.Page - 1) * params.PageSize).
+		Limit(params.PageSize).
+		Find(&commissions).Error
+
+	return commissions, total, err
+}
+
+// GetCommission returns a single commission invoice
+func (s *CommissionService) GetCommission(commissionID uuid.UUID) (*models.PlatformCommission, error) {
+	db := database.GetDB()
+
+	var commission models.PlatformCommission
+	if err := db.Preload("Developer").
+		Preload("TermSheet").
+		First(&commission, "id = ?", commissionID).Error; err != nil {
+		return nil, errors.New("commission not found")
+	}
+
+	return &commission, nil
+}
+
+// CommissionStats summarises platform commission revenue. Amounts are in the
+// reporting currency.
+type CommissionStats struct {
+	Currency           string  `json:"currency"`
+	TotalEarned        int64   `json:"total_earned"`
+	PendingCollection  int64   `json:"pending_collection"`
+	OverdueAmount      int64   `json:"overdue_amount"`
+	CollectedThisMonth int64   `json:"collected_this_month"`
+	AverageRate        float64 `json:"average_rate"`
+	InvoiceCount       int64   `json:"invoice_count"`
+	OverdueCount       int64   `json:"overdue_count"`
+
+	MissingRates []string `json:"missing_rates"` // Currencies with no FX rate, left out of the totals
+	IsComplete   bool     `json:"is_complete"`
+}
+
+// GetCommissionStats returns commission revenue totals for the admin dashboard
+func (s *CommissionService) GetCommissionStats() (*CommissionStats, error) {
+	db := database.GetDB()
+
+	stats := &CommissionStats{Currency: s.config.ReportingCurrency}
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	converter, err := newReportingConverter(db, stats.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	commissions := db.Model(&models.PlatformCommission{})
+	for _, total := range []struct {
+		into  *int64
+		query *gorm.DB
+	}{
+		{&stats.TotalEarned, commissions.Session(&gorm.Session{}).Where("status = ?", models.InvoiceStatusPaid)},
+		{&stats.PendingCollection, commissions.Session(&gorm.Session{}).Where("status IN ?", []string{models.InvoiceStatusDraft, models.InvoiceStatusSent, models.InvoiceStatusOverdue})},
+		{&stats.OverdueAmount, commissions.Session(&gorm.Session{}).Where("status = ?", models.InvoiceStatusOverdue)},
+		{&stats.CollectedThisMonth, commissions.Session(&gorm.Session{}).Where("status = ? AND paid_at >= ?", models.InvoiceStatusPaid, monthStart)},
+	} {
+		if *total.into, err = converter.sum(total.query, "amount"); err != nil {
+			return nil, err
+		}
+	}
+	stats.MissingRates = converter.missingRates()
+	stats.IsComplete = len(stats.MissingRates) == 0
+	commissions.Session(&gorm.Session{}).Where("status <> ?", models.InvoiceStatusCancelled).Count(&stats.InvoiceCount)
+	commissions.Session(&gorm.Session{}).Where("status = ?", models.InvoiceStatusOverdue).Count(&stats.OverdueCount)
+
+	db.Model(&models.TermSheet{}).
+		Where("status = ?", models.TermSheetStatusFundsReceived).
+		Select("COALESCE(AVG(commission_rate), 0)").
+		Scan(&stats.AverageRate)
+
+	return stats, nil
+}
+
+// SendInvoice marks a draft invoice as sent to the developer
+func (s *CommissionService) SendInvoice(adminID, commissionID uuid.UUID, ipAddress, userAgent string) (*models.PlatformCommission, error) {
+	now := time.Now()
+	return s.transition(adminID, commissionID, []string{models.InvoiceStatusDraft}, models.InvoiceStatusSent,
+		map[string]interface{}{"sent_at": now}, models.AuditActionCommissionSent,
+		"Commission invoice sent", ipAddress, userAgent, nil)
+}
+
+// RecordPaymentInput captures how a commission invoice was settled
+type RecordPaymentInput struct {
+	PaymentMethod    string     `json:"payment_method"` // Defaults to bank_transfer
+	PaymentReference string     `json:"payment_reference"`
+	PaidAt           *time.Time `json:"paid_at"` // Defaults to now
+}
+
+// RecordPayment marks an outstanding invoice as paid and settles the term sheet's commission
+func (s *CommissionService) RecordPayment(adminID, commissionID uuid.UUID, input *RecordPaymentInput, ipAddress, userAgent string) (*models.PlatformCommission, error) {
+	paidAt := time.Now()
+	if input.PaidAt != nil {
+		if input.PaidAt.After(paidAt) {
+			return nil, errors.New("payment date cannot be in the future")
+		}
+		paidAt = *input.PaidAt
+	}
+
+	method := input.PaymentMethod
+	if method == "" {
+		method = "bank_transfer"
+	}
+
+	return s.transition(adminID, commissionID,
+		[]string{models.InvoiceStatusDraft, models.InvoiceStatusSent, models.InvoiceStatusOverdue},
+		models.InvoiceStatusPaid,
+		map[string]interface{}{
+			"paid_at":           paidAt,
+			"payment_method":    method,
+			"payment_reference": input.PaymentReference,
+		},
+		models.AuditActionCommissionPaid, "Commission payment recorded", ipAddress, userAgent,
+		func(tx *gorm.DB, commission *models.PlatformCommission) error {
+			return tx.Model(&models.TermSheet{}).
+				Where("id = ?", commission.TermSheetID).
+				Updates(map[string]interface{}{
+					"commission_status":  models.CommissionStatusPaid,
+					"commission_paid_at": paidAt,
+				}).Error
+		})
+}
+
+// CancelInvoice voids an unpaid invoice; the term sheet's commission returns to
+// pending so a corrected invoice can be raised with ReissueInvoice
+func (s *CommissionService) CancelInvoice(adminID, commissionID uuid.UUID, reason, ipAddress, userAgent string) (*models.PlatformCommission, error) {
+	description := "Commission invoice cancelled"
+	if reason != "" {
+		description += ": " + reason
+	}
+
+	return s.transition(adminID, commissionID,
+		[]string{models.InvoiceStatusDraft, models.InvoiceStatusSent, models.InvoiceStatusOverdue},
+		models.InvoiceStatusCancelled, nil,
+		models.AuditActionCommissionCancelled, description, ipAddress, userAgent,
+		func(tx *gorm.DB, commission *models.PlatformCommission) error {
+			return tx.Model(&models.TermSheet{}).
+				Where("id = ? AND commission_invoice_id = ?", commission.TermSheetID, commission.ID).
+				Updates(map[string]interface{}{
+					"commission_status":     models.CommissionStatusPending,
+					"commission_invoice_id": nil,
+				}).Error
+		})
+}
+
+// MarkOverdueInvoices moves sent invoices past their due date to overdue
+func (s *CommissionService) MarkOverdueInvoices() (int64, error) {
+	db := database.GetDB()
+
+	var commissions []models.PlatformCommission
+	if err := db.Where("status = ? AND due_date < ?", models.InvoiceStatusSent, time.Now()).
+		Find(&commissions).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i := range commissions {
+		commission := &commissions[i]
+		result := db.Model(&models.PlatformCommission{}).
+			Where("id = ? AND status = ?", commission.ID, models.InvoiceStatusSent).
+			Update("status", models.InvoiceStatusOverdue)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+
+		commission.Status = models.InvoiceStatusOverdue
+		s.auditService.LogAction(nil, "", "", models.AuditActionCommissionOverdue, "commission", &commission.ID,
+			commission.InvoiceNumber, "Commission invoice is past due",
+			map[string]interface{}{
+				"invoice_number": commission.InvoiceNumber,
+				"amount":         commission.Amount,
+				"currency":       commission.Currency,
+				"due_date":       commission.DueDate,
+			}, "", "")
+	}
+
+	return count, nil
+}
+
+// transition moves an invoice between statuses, guarding against concurrent changes,
+// and writes the matching audit entry
+func (s *CommissionService) transition(
+	adminID, commissionID uuid.UUID,
+	from []string,
+	to string,
+	fields map[string]interface{},
+	action models.AuditAction,
+	description, ipAddress, userAgent string,
+	after func(tx *gorm.DB, commission *models.PlatformCommission) error,
+) (*models.PlatformCommission, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+
+	var commission models.PlatformCommission
+	if err := db.First(&commission, "id = ?", commissionID).Error; err != nil {
+		return nil, errors.New("commission not found")
+	}
+
+	previous := commission.Status
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PlatformCommission{}).
+			Where("id = ? AND status IN ?", commission.ID, from).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("cannot change a %s invoice to %s", previous, to)
+		}
+		if after != nil {
+			return after(tx, &commission)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.Preload("Developer").Preload("TermSheet").First(&commission, "id = ?", commission.ID)
+
+	s.logCommissionAction(&admin, action, &commission, description, ipAddress, userAgent)
+
+	return &commission, nil
+}
+
+// nextInvoiceNumber allocates the next sequential invoice number for the year.
+// The counter row is locked so concurrent confirmations never share a number.
+func (s *CommissionService) nextInvoiceNumber(tx *gorm.DB, year int) (string, error) {
+	counter := models.InvoiceCounter{Year: year}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&counter, "year = ?", year).Error; err != nil {
+		return "", err
+	}
+
+	counter.LastNumber++
+	if err := tx.Model(&counter).Update("last_number", counter.LastNumber).Error; err != nil {
+		return "", err
+	}
+
+	return models.FormatInvoiceNumber(year, counter.LastNumber), nil
+}
+
+// applyBillingDetails copies the invoicee's details from the developer and the
+// project's registered legal entity, when one exists
+func (s *CommissionService) applyBillingDetails(tx *gorm.DB, commission *models.PlatformCommission, developer *models.User, project *models.Project) {
+	commission.BillingName = developer.FullName()
+	if developer.CompanyName != "" {
+		commission.BillingName = developer.CompanyName
+	}
+	commission.BillingEmail = developer.Email
+	if project.ContactEmail != "" {
+		commission.BillingEmail = project.ContactEmail
+	}
+
+	var readiness models.ProjectReadiness
+	if err := tx.Where("project_id = ?", project.ID).First(&readiness).Error; err == nil && readiness.HasLegalEntity {
+		if readiness.LegalEntityName != "" {
+			commission.BillingName = readiness.LegalEntityName
+		}
+		commission.BillingAddress = readiness.LegalJurisdiction
+	}
+}
+
+func (s *CommissionService) logCommissionAction(user *models.User, action models.AuditAction, commission *models.PlatformCommission, description, ipAddress, userAgent string) error {
+	metadata := map[string]interface{}{
+		"invoice_number": commission.InvoiceNumber,
+		"amount":         commission.Amount,
+		"currency":       commission.Currency,
+		"term_sheet_id":  commission.TermSheetID,
+		"developer_id":   commission.DeveloperID,
+		"status":         commission.Status,
+	}
+
+	return s.auditService.LogAction(
+		&user.ID,
+		user.Email,
+		user.Role,
+		action,
+		"commission",
+		&commission.ID,
+		commission.InvoiceNumber,
+		description,
+		metadata,
+		ipAddress,
+		userAgent,
+	)
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// Revenue sources in the revenue report
+const (
+	RevenueSourceViewCredits = "view_credits"
+	RevenueSourceCommissions = "commissions"
+)
+
+// CurrencyService manages FX rates and reports mixed-currency revenue in the
+// reporting currency
+type CurrencyService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewCurrencyService(cfg *config.Config, auditSvc *AuditService) *CurrencyService {
+	return &CurrencyService{config: cfg, auditService: auditSvc}
+}
+
+// ExchangeRateInput is an admin-entered FX rate into the reporting currency
+type ExchangeRateInput struct {
+	Currency    string     `json:"currency" binding:"required"`
+	Rate        float64    `json:"rate" binding:"required,gt=0"` // Reporting-currency units per unit of Currency
+	EffectiveAt *time.Time `json:"effective_at"`                 // Defaults to now
+	Note        string     `json:"note"`
+}
+
+// ListExchangeRates returns the rate history into the reporting currency, newest
+// first, optionally for one currency
+func (s *CurrencyService) ListExchangeRates(currency string) ([]models.ExchangeRate, error) {
+	db := database.GetDB()
+
+	query := db.Where("reporting_currency = ?", s.config.ReportingCurrency)
+	if currency != "" {
+		query = query.Where("currency = ?", strings.ToLower(currency))
+	}
+
+	var rates []models.ExchangeRate
+	err := query.Order("effective_at DESC, created_at DESC").Find(&rates).Error
+	return rates, err
+}
+
+// SetExchangeRate records a new FX rate. Earlier rates are kept so past reports
+// can be reproduced.
+func (s *CurrencyService) SetExchangeRate(adminID uuid.UUID, input ExchangeRateInput, ipAddress, userAgent string) (*models.ExchangeRate, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+
+	currency := strings.ToLower(strings.TrimSpace(input.Currency))
+	if !models.IsSupportedCurrency(currency) {
+		return nil, fmt.Errorf("unsupported currency %q", input.Currency)
+	}
+	if currency == s.config.ReportingCurrency {
+		return nil, errors.New("the reporting currency does not need a rate")
+	}
+
+	rate := &models.ExchangeRate{
+		Currency:          currency,
+		ReportingCurrency: s.config.ReportingCurrency,
+		Rate:              input.Rate,
+		EffectiveAt:       time.Now(),
+		Note:              input.Note,
+		CreatedByID:       admin.ID,
+	}
+	if input.EffectiveAt != nil {
+		rate.EffectiveAt = *input.EffectiveAt
+	}
+
+	if err := db.Create(rate).Error; err != nil {
+		return nil, err
+	}
+
+	pair := strings.ToUpper(currency + "/" + s.config.ReportingCurrency)
+	s.auditService.LogAction(
+		&admin.ID,
+		admin.Email,
+		admin.Role,
+		models.AuditActionExchangeRateSet,
+		"exchange_rate",
+		&rate.ID,
+		pair,
+		fmt.Sprintf("Set %s rate to %g from %s", pair, rate.Rate, rate.EffectiveAt.Format("2006-01-02")),
+		map[string]interface{}{
+			"currency":           rate.Currency,
+			"reporting_currency": rate.ReportingCurrency,
+			"rate":               rate.Rate,
+			"effective_at":       rate.EffectiveAt,
+		},
+		ipAddress,
+		userAgent,
+	)
+
+	return rate, nil
+}
+
+// GetRevenueReport totals view credit sales and collected commissions between
+// from and to, per currency, and converts each currency's net revenue at the
+// rates in effect at the end of the period
+func (s *CurrencyService) GetRevenueReport(from, to time.Time) (*models.RevenueReport, error) {
+	db := database.GetDB()
+
+	reporting := s.config.ReportingCurrency
+	report := &models.RevenueReport{
+		From:              from,
+		To:                to,
+		ReportingCurrency: reporting,
+		RatesAsOf:         to,
+		Lines:             []models.RevenueLine{},
+		MissingRates:      []string{},
+	}
+
+	var credits []models.RevenueLine
+	err := db.Model(&models.Payment{}).
+		Select(`currency, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS gross,
+			COALESCE(SUM(amount_refunded), 0) AS refunded`).
+		Where("type = ? AND status IN ? AND completed_at >= ? AND completed_at < ?",
+			models.PaymentTypePurchase,
+			[]models.PaymentStatus{models.PaymentStatusCompleted, models.PaymentStatusRefunded, models.PaymentStatusDisputed},
+			from, to).
+		Group("currency").
+		Scan(&credits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var commissions []models.RevenueLine
+	err = db.Model(&models.PlatformCommission{}).
+		Select("currency, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS gross").
+		Where("status = ? AND paid_at >= ? AND paid_at < ?", models.InvoiceStatusPaid, from, to).
+		Group("currency").
+		Scan(&commissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range credits {
+		credits[i].Source = RevenueSourceViewCredits
+	}
+	for i := range commissions {
+		commissions[i].Source = RevenueSourceCommissions
+	}
+
+	rates, err := exchangeRates(db, reporting, to)
+	if err != nil {
+		return nil, err
+	}
+	missing := map[string]bool{}
+	for _, line := range append(credits, commissions...) {
+		line.Currency = strings.ToLower(line.Currency)
+		line.Net = line.Gross - line.Refunded
+		if rate, ok := rates[line.Currency]; ok {
+			converted := models.ConvertAmount(line.Net, line.Currency, reporting, rate)
+			line.Rate = &rate
+			line.NetReporting = &converted
+			report.NetTotal += converted
+		} else {
+			missing[line.Currency] = true
+		}
+		report.Lines = append(report.Lines, line)
+	}
+
+	for currency := range missing {
+		report.MissingRates = append(report.MissingRates, currency)
+	}
+	sort.Strings(report.MissingRates)
+
+	report.NetTotalFormatted = models.FormatCurrency(report.NetTotal, reporting)
+	report.IsComplete = len(report.MissingRates) == 0
+	return report, nil
+}
+
+// exchangeRates returns, per currency, the latest rate into reporting in effect
+// at asOf. The reporting currency converts at 1.
+func exchangeRates(db *gorm.DB, reporting string, asOf time.Time) (map[string]float64, error) {
+	var latest []models.ExchangeRate
+	if err := db.Raw(`SELECT DISTINCT ON (currency) * FROM exchange_rates
+		WHERE reporting_currency = ? AND effective_at <= ?
+		ORDER BY currency, effective_at DESC, created_at DESC`, reporting, asOf).
+		Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	rates := map[string]float64{reporting: 1}
+	for _, rate := range latest {
+		rates[rate.Currency] = rate.Rate
+	}
+	return rates, nil
+}
+
+// convertAmountAt converts an amount between two currencies through their rates
+// into reporting in effect at asOf
+func convertAmountAt(db *gorm.DB, amount int64, from, to, reporting string, asOf time.Time) (int64, error) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	if from == to {
+		return amount, nil
+	}
+
+	rates, err := exchangeRates(db, reporting, asOf)
+	if err != nil {
+		return 0, err
+	}
+	for _, currency := range []string{from, to} {
+		if _, ok := rates[currency]; !ok {
+			return 0, fmt.Errorf("no %s exchange rate on file", strings.ToUpper(currency))
+		}
+	}
+	return models.ConvertAmount(amount, from, to, rates[from]/rates[to]), nil
+}
+
+// reportingConverter sums amounts held in several currencies into the reporting
+// currency at current rates, remembering the currencies it had no rate for
+type reportingConverter struct {
+	db        *gorm.DB
+	reporting string
+	rates     map[string]float64
+	missing   map[string]bool
+}
+
+func newReportingConverter(db *gorm.DB, reporting string) (*reportingConverter, error) {
+	rates, err := exchangeRates(db, reporting, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &reportingConverter{db: db, reporting: reporting, rates: rates, missing: map[string]bool{}}, nil
+}
+
+// sum totals an amount column over query per currency and converts the totals.
+// Currencies with no rate on file are left out and reported by missingRates.
+func (c *reportingConverter) sum(query *gorm.DB, column string) (int64, error) {
+	var totals []struct {
+		Currency string
+		Total    int64
+	}
+	if err := query.Select(fmt.Sprintf("currency, COALESCE(SUM(%s), 0) AS total", column)).
+		Group("currency").
+		Scan(&totals).Error; err != nil {
+		return 0, err
+	}
+
+	var sum int64
+	for _, t := range totals {
+		currency := strings.ToLower(t.Currency)
+		if rate, ok := c.rates[currency]; ok {
+			sum += models.ConvertAmount(t.Total, currency, c.reporting, rate)
+		} else if t.Total != 0 {
+			c.missing[currency] = true
+		}
+	}
+	return sum, nil
+}
+
+// missingRates lists the currencies left out of the sums so far, sorted
+func (c *reportingConverter) missingRates() []string {
+	missing := []string{}
+	for currency := range c.missing {
+		missing = append(missing, currency)
+	}
+	sort.Strings(missing)
+	return missing
+}
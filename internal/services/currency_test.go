@@ -0,0 +1,95 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+func TestDashboardsReportCurrenciesWithoutRates(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	admin := createTestUser(t, models.RoleAdmin)
+	investor := createTestUser(t, models.RoleInvestor)
+
+	usd := createCompletedTestPayment(t, investor.ID, 1)
+	eur := createCompletedTestPayment(t, investor.ID, 1)
+	gbp := createCompletedTestPayment(t, investor.ID, 1)
+	db.Model(eur).Update("currency", "eur")
+	db.Model(gbp).Update("currency", "gbp")
+
+	if _, err := NewCurrencyService(cfg, audit).SetExchangeRate(admin.ID, ExchangeRateInput{Currency: "eur", Rate: 1.5}, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := audit.GetDashboardStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := usd.Amount + eur.Amount*3/2; stats.TotalRevenue != want {
+		t.Fatalf("got revenue %d, want %d", stats.TotalRevenue, want)
+	}
+	if !reflect.DeepEqual(stats.MissingRates, []string{"gbp"}) || stats.RevenueComplete {
+		t.Fatalf("got missing rates %v (complete %v), want [gbp]", stats.MissingRates, stats.RevenueComplete)
+	}
+
+	adminStats, err := NewAdminService(cfg, audit, NewEmailService(cfg, nil)).GetDashboardStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(adminStats.MissingRates, []string{"gbp"}) || adminStats.RevenueComplete {
+		t.Fatalf("got missing rates %v (complete %v), want [gbp]", adminStats.MissingRates, adminStats.RevenueComplete)
+	}
+
+	commissionStats, err := NewCommissionService(cfg, audit).GetCommissionStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commissionStats.MissingRates) != 0 || !commissionStats.IsComplete {
+		t.Fatalf("got missing rates %v with no commissions", commissionStats.MissingRates)
+	}
+}
+
+func TestOffersAndFundsUseProjectCurrency(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	admin := createTestUser(t, models.RoleAdmin)
+	investor := createTestUser(t, models.RoleInvestor)
+	project := createTestProject(t)
+	db.Model(project).Update("currency", "eur")
+
+	offers := NewOfferService(cfg, audit)
+	if _, err := offers.CreateOffer(investor.ID, &CreateOfferInput{ProjectID: project.ID, Amount: project.MinInvestment, Currency: "jpy"}, "", ""); err == nil {
+		t.Fatal("accepted an offer in another currency than the project's")
+	}
+	offer, err := offers.CreateOffer(investor.ID, &CreateOfferInput{ProjectID: project.ID, Amount: project.MinInvestment}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offer.Currency != "eur" {
+		t.Fatalf("got offer currency %q, want the project's", offer.Currency)
+	}
+
+	currencies := NewCurrencyService(cfg, audit)
+	for currency, rate := range map[string]float64{"eur": 1.1, "jpy": 0.0067} {
+		if _, err := currencies.SetExchangeRate(admin.ID, ExchangeRateInput{Currency: currency, Rate: rate}, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ¥1,000,000 is about €6,090.91, not €10,000
+	got, err := convertAmountAt(db, 1000000, "jpy", "eur", cfg.ReportingCurrency, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 609091 {
+		t.Fatalf("got %d euro cents, want 609091", got)
+	}
+	if _, err := convertAmountAt(db, 1000, "gbp", "eur", cfg.ReportingCurrency, time.Now()); err == nil {
+		t.Fatal("converted without a rate on file")
+	}
+}
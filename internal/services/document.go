@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/pdf"
+)
+
+// DocumentService renders downloadable financial documents (invoices, receipts) as PDF
+type DocumentService struct {
+	config *config.Config
+}
+
+func NewDocumentService(cfg *config.Config) *DocumentService {
+	return &DocumentService{config: cfg}
+}
+
+// Page layout
+const (
+	docMarginLeft  = 56.0
+	docMarginRight = pdf.PageWidth - 56.0
+	docLineHeight  = 15.0
+)
+
+// GetCommissionInvoicePDF renders a commission invoice. Admins may download any invoice;
+// developers only their own.
+func (s *DocumentService) GetCommissionInvoicePDF(userID uuid.UUID, role models.UserRole, commissionID uuid.UUID) (*models.PlatformCommission, []byte, error) {
+	db := database.GetDB()
+
+	var commission models.PlatformCommission
+	if err := db.Preload("TermSheet").
+		Preload("TermSheet.Offer").
+		Preload("TermSheet.Offer.Project").
+		First(&commission, "id = ?", commissionID).Error; err != nil {
+		return nil, nil, errors.New("invoice not found")
+	}
+
+	if role != models.RoleAdmin && commission.DeveloperID != userID {
+		return nil, nil, errors.New("invoice not found")
+	}
+
+	if commission.Status == models.InvoiceStatusCancelled && role != models.RoleAdmin {
+		return nil, nil, errors.New("invoice has been cancelled")
+	}
+
+	return &commission, s.RenderCommissionInvoice(&commission), nil
+}
+
+// GetPaymentReceiptPDF renders a receipt for one of the investor's completed credit purchases
+func (s *DocumentService) GetPaymentReceiptPDF(investorID, paymentID uuid.UUID) (*models.Payment, []byte, error) {
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.Preload("Investor").
+		Preload("Investor.InvestorProfile").
+		First(&payment, "id = ? AND investor_id = ?", paymentID, investorID).Error; err != nil {
+		return nil, nil, errors.New("payment not found")
+	}
+
+	if payment.Status != models.PaymentStatusCompleted && payment.Status != models.PaymentStatusRefunded {
+		return nil, nil, errors.New("receipts are only available for completed payments")
+	}
+
+	if payment.IsGrant() {
+		return nil, nil, errors.New("complimentary credits have no receipt")
+	}
+
+	return &payment, s.RenderPaymentReceipt(&payment), nil
+}
+
+// RenderCommissionInvoice renders the platform's commission invoice to the developer
+func (s *DocumentService) RenderCommissionInvoice(commission *models.PlatformCommission) []byte {
+	doc := pdf.New("Invoice " + commission.InvoiceNumber)
+	page := doc.AddPage()
+
+	y := s.drawHeader(page, "INVOICE", commission.InvoiceNumber)
+
+	// Issue details (right column) alongside the bill-to block (left column)
+	details := [][2]string{
+		{"Issue date", commission.CreatedAt.Format("January 2, 2006")},
+		{"Due date", commission.DueDate.Format("January 2, 2006")},
+		{"Status", strings.ToUpper(commission.Status)},
+	}
+	if commission.PaidAt != nil {
+		details = append(details, [2]string{"Paid on", commission.PaidAt.Format("January 2, 2006")})
+	}
+
+	billTo := []string{commission.BillingName}
+	billTo = append(billTo, splitLines(commission.BillingAddress)...)
+	billTo = append(billTo, commission.BillingEmail)
+	if commission.TaxID != "" {
+		billTo = append(billTo, "Tax ID: "+commission.TaxID)
+	}
+
+	y = s.drawParties(page, y, "BILL TO", billTo, details)
+
+	// Line items
+	description := "Platform commission on completed investment"
+	var lines [][2]string
+	if ts := commission.TermSheet; ts != nil {
+		if ts.Offer != nil && ts.Offer.Project != nil {
+			description = fmt.Sprintf("Platform commission - %s", ts.Offer.Project.Title)
+		}
+		lines = append(lines,
+			[2]string{"Investment amount", models.FormatCurrency(ts.InvestmentAmount, ts.Currency)},
+			[2]string{"Commission rate", fmt.Sprintf("%g%%", ts.CommissionRate*100)},
+		)
+		if ts.FundsReceivedAt != nil {
+			lines = append(lines, [2]string{"Funds received", ts.FundsReceivedAt.Format("January 2, 2006")})
+		}
+		if ts.BankReference != "" {
+			lines = append(lines, [2]string{"Bank reference", ts.BankReference})
+		}
+	}
+
+	y = s.drawItems(page, y, description, lines, commission.FormattedAmount())
+
+	if commission.Status == models.InvoiceStatusPaid {
+		payment := "Paid"
+		if commission.PaymentMethod != "" {
+			payment += " by " + strings.ReplaceAll(commission.PaymentMethod, "_", " ")
+		}
+		if commission.PaymentReference != "" {
+			payment += " (ref. " + commission.PaymentReference + ")"
+		}
+		page.Text(docMarginLeft, y, pdf.Bold, 10, payment)
+		y -= docLineHeight * 2
+	}
+
+	page.Text(docMarginLeft, y, pdf.Regular, 9,
+		fmt.Sprintf("Please quote %s with your payment. Payment is due by %s.",
+			commission.InvoiceNumber, commission.DueDate.Format("January 2, 2006")))
+
+	s.drawFooter(page)
+
+	return doc.Bytes()
+}
+
+// RenderPaymentReceipt renders a receipt for a view-credit purchase. Institutional buyers
+// are billed under their company details from InvestorProfile.ForInvoice.
+func (s *DocumentService) RenderPaymentReceipt(payment *models.Payment) []byte {
+	receiptNumber := "RCPT-" + strings.ToUpper(payment.ID.String()[:8])
+
+	doc := pdf.New("Receipt " + receiptNumber)
+	page := doc.AddPage()
+
+	y := s.drawHeader(page, "RECEIPT", receiptNumber)
+
+	var billTo []string
+	if investor := payment.Investor; investor != nil {
+		billTo = append(billTo, investor.FullName())
+		if investor.InvestorProfile != nil && investor.InvestorProfile.IsInstitutional() {
+			billing := investor.InvestorProfile.ForInvoice()
+			billTo = []string{billing["company_name"]}
+			billTo = append(billTo, splitLines(billing["address"])...)
+			billTo = append(billTo, joinNonEmpty(" ", billing["city"], billing["state"], billing["postal_code"]))
+			billTo = append(billTo, billing["country"])
+			if billing["contact_name"] != "" {
+				billTo = append(billTo, "Attn: "+billing["contact_name"])
+			}
+			if billing["contact_email"] != "" {
+				billTo = append(billTo, billing["contact_email"])
+			} else {
+				billTo = append(billTo, investor.Email)
+			}
+			if billing["tax_id"] != "" {
+				billTo = append(billTo, "Tax ID: "+billing["tax_id"])
+			}
+		} else {
+			billTo = append(billTo, investor.Email)
+		}
+	}
+
+	paidOn := payment.CreatedAt
+	if payment.CompletedAt != nil {
+		paidOn = *payment.CompletedAt
+	}
+	details := [][2]string{
+		{"Date paid", paidOn.Format("January 2, 2006")},
+		{"Status", strings.ToUpper(string(payment.Status))},
+	}
+	if payment.StripePaymentID != "" {
+		details = append(details, [2]string{"Payment ref.", payment.StripePaymentID})
+	}
+
+	y = s.drawParties(page, y, "BILLED TO", billTo, details)
+
+	description := payment.Description
+	if description == "" {
+		description = "Project view credits"
+	}
+	lines := [][2]string{
+		{"Credits purchased", fmt.Sprintf("%d project views", payment.ProjectsTotal)},
+	}
+
+	y = s.drawItems(page, y, description, lines, models.FormatCurrency(payment.Amount, payment.Currency))
+
+	if payment.Status == models.PaymentStatusRefunded {
+		page.Text(docMarginLeft, y, pdf.Bold, 10, "This payment has been refunded.")
+		y -= docLineHeight * 2
+	}
+
+	page.Text(docMarginLeft, y, pdf.Regular, 9, "Thank you for your purchase.")
+
+	s.drawFooter(page)
+
+	return doc.Bytes()
+}
+
+// commissionInvoiceURL is the developer-facing download link stored on the invoice
+func commissionInvoiceURL(cfg *config.Config, commissionID uuid.UUID) string {
+	return fmt.Sprintf("%s/api/developer/commissions/%s/pdf", cfg.BaseURL, commissionID)
+}
+
+// paymentReceiptURL is the platform-rendered receipt used when Stripe provides none
+func paymentReceiptURL(cfg *config.Config, paymentID uuid.UUID) string {
+	return fmt.Sprintf("%s/api/investor/payments/%s/receipt", cfg.BaseURL, paymentID)
+}
+
+func (s *DocumentService) drawHeader(page *pdf.Page, title, number string) float64 {
+	y := pdf.PageHeight - 72
+
+	page.Text(docMarginLeft, y, pdf.Bold, 20, s.config.FromName)
+	page.TextRight(docMarginRight, y, pdf.Bold, 20, title)
+	y -= 18
+	page.Text(docMarginLeft, y, pdf.Regular, 9, s.config.FromEmail)
+	page.TextRight(docMarginRight, y, pdf.Regular, 10, number)
+	y -= 14
+	page.Line(docMarginLeft, y, docMarginRight, y, 0.75)
+
+	return y - 28
+}
+
+func (s *DocumentService) drawParties(page *pdf.Page, y float64, label string, billTo []string, details [][2]string) float64 {
+	page.Text(docMarginLeft, y, pdf.Bold, 9, label)
+
+	left := y - docLineHeight
+	for _, line := range billTo {
+		if line == "" {
+			continue
+		}
+		page.Text(docMarginLeft, left, pdf.Regular, 10, line)
+		left -= docLineHeight
+	}
+
+	right := y
+	for _, d := range details {
+		page.Text(pdf.PageWidth/2+40, right, pdf.Regular, 10, d[0])
+		page.TextRight(docMarginRight, right, pdf.Bold, 10, d[1])
+		right -= docLineHeight
+	}
+
+	if right < left {
+		left = right
+	}
+	return left - 24
+}
+
+func (s *DocumentService) drawItems(page *pdf.Page, y float64, description string, lines [][2]string, total string) float64 {
+	page.Rect(docMarginLeft, y-6, docMarginRight-docMarginLeft, 20, 0.92)
+	page.Text(docMarginLeft+6, y, pdf.Bold, 10, "Description")
+	page.TextRight(docMarginRight-6, y, pdf.Bold, 10, "Amount")
+	y -= 24
+
+	page.Text(docMarginLeft+6, y, pdf.Regular, 10, description)
+	page.TextRight(docMarginRight-6, y, pdf.Regular, 10, total)
+	y -= docLineHeight
+
+	for _, line := range lines {
+		page.Text(docMarginLeft+18, y, pdf.Regular, 9, line[0]+": "+line[1])
+		y -= docLineHeight
+	}
+
+	y -= 6
+	page.Line(pdf.PageWidth/2+40, y, docMarginRight, y, 0.5)
+	y -= 18
+	page.Text(pdf.PageWidth/2+40, y, pdf.Bold, 12, "Total")
+	page.TextRight(docMarginRight-6, y, pdf.Bold, 12, total)
+
+	return y - 36
+}
+
+func (s *DocumentService) drawFooter(page *pdf.Page) {
+	page.Line(docMarginLeft, 60, docMarginRight, 60, 0.5)
+	page.Text(docMarginLeft, 46, pdf.Regular, 8,
+		fmt.Sprintf("%s - %s", s.config.FromName, s.config.BaseURL))
+}
+
+// splitLines breaks a multi-line address into trimmed, non-empty lines
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/mail"
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+// emailSendTimeout bounds one background delivery
+const emailSendTimeout = time.Minute
+
+// EmailService sends transactional email. Delivery happens in the background:
+// a slow mail server never holds up a request, and how long a request takes
+// does not reveal whether an email went out. Failures are logged; the action
+// that triggered an email is never rolled back because it could not be sent.
+type EmailService struct {
+	config *config.Config
+	mailer mail.Mailer // nil when mail is disabled
+
+	// Recipient lookup by user ID, overridable to run without a database
+	findUser func(id uuid.UUID) *models.User
+
+	wg sync.WaitGroup
+}
+
+func NewEmailService(cfg *config.Config, mailer mail.Mailer) *EmailService {
+	return &EmailService{config: cfg, mailer: mailer, findUser: loadUser}
+}
+
+// Wait blocks until background deliveries have finished
+func (s *EmailService) Wait() {
+	s.wg.Wait()
+}
+
+// SendVerificationEmail asks a new user to confirm their address
+func (s *EmailService) SendVerificationEmail(user *models.User) {
+	if user.EmailVerifyToken == "" {
+		return
+	}
+	s.send(user, mail.TemplateVerifyEmail, map[string]interface{}{
+		"URL": s.link("/verify-email", url.Values{"token": {user.EmailVerifyToken}}),
+	})
+}
+
+// SendPasswordReset sends a password reset link valid for ttl
+func (s *EmailService) SendPasswordReset(user *models.User, token string, ttl time.Duration) {
+	s.send(user, mail.TemplatePasswordReset, map[string]interface{}{
+		"URL":          s.link("/reset-password", url.Values{"token": {token}}),
+		"ValidMinutes": int(ttl.Minutes()),
+	})
+}
+
+// SignedDocument describes a signed NDA or addendum for its confirmation email
+type SignedDocument struct {
+	Title        string // e.g. "master NDA"
+	SignedName   string
+	SignedAt     time.Time
+	ExpiresAt    *time.Time
+	DocumentHash string
+}
+
+// SendNDASigned confirms to an investor that they signed a document
+func (s *EmailService) SendNDASigned(investorID uuid.UUID, doc SignedDocument) {
+	var expiresAt time.Time
+	if doc.ExpiresAt != nil {
+		expiresAt = *doc.ExpiresAt
+	}
+	s.send(s.user(investorID), mail.TemplateNDASigned, map[string]interface{}{
+		"Document":     doc.Title,
+		"SignedName":   doc.SignedName,
+		"SignedAt":     doc.SignedAt,
+		"HasExpiry":    doc.ExpiresAt != nil,
+		"ExpiresAt":    expiresAt,
+		"DocumentHash": doc.DocumentHash,
+	})
+}
+
+// SendMeetingRequested tells a project's developer that an investor wants to
+// meet. The request must have its Investor and Project loaded.
+func (s *EmailService) SendMeetingRequested(request *models.MeetingRequest) {
+	if request.Investor == nil || request.Project == nil {
+		return
+	}
+	s.send(s.user(request.Project.DeveloperID), mail.TemplateMeetingRequested, map[string]interface{}{
+		"InvestorName":    strings.TrimSpace(request.Investor.FullName()),
+		"InvestorCompany": request.Investor.CompanyName,
+		"ProjectTitle":    request.Project.Title,
+		"MeetingType":     strings.ReplaceAll(request.MeetingType, "_", "-"),
+		"Message":         request.Message,
+		"ProposedTimes":   request.ProposedTimes,
+		"ExpiresAt":       request.ExpiresAt,
+		"URL":             s.link("/meetings/"+request.ID.String(), nil),
+	})
+}
+
+// SendMeetingAccepted tells an investor their meeting request was accepted. The
+// request must have its Project loaded.
+func (s *EmailService) SendMeetingAccepted(request *models.MeetingRequest) {
+	if request.Project == nil {
+		return
+	}
+	var scheduledAt time.Time
+	if request.ScheduledAt != nil {
+		scheduledAt = *request.ScheduledAt
+	}
+	s.send(s.user(request.InvestorID), mail.TemplateMeetingAccepted, map[string]interface{}{
+		"ProjectTitle":    request.Project.Title,
+		"HasSchedule":     request.ScheduledAt != nil,
+		"ScheduledAt":     scheduledAt,
+		"MeetingLink":     request.MeetingLink,
+		"ResponseMessage": request.ResponseMessage,
+		"URL":             s.link("/meetings/"+request.ID.String(), nil),
+	})
+}
+
+// SendProjectApproved tells a developer their project is live
+func (s *EmailService) SendProjectApproved(project *models.Project) {
+	s.send(s.user(project.DeveloperID), mail.TemplateProjectApproved, map[string]interface{}{
+		"ProjectTitle": project.Title,
+		"URL":          s.link("/projects/"+project.ID.String(), nil),
+	})
+}
+
+// SendProjectRejected tells a developer their project was not approved, and why
+func (s *EmailService) SendProjectRejected(project *models.Project) {
+	s.send(s.user(project.DeveloperID), mail.TemplateProjectRejected, map[string]interface{}{
+		"ProjectTitle": project.Title,
+		"Reason":       project.RejectionReason,
+		"URL":          s.link("/projects/"+project.ID.String(), nil),
+	})
+}
+
+// SendCreditsExpiring reminds an investor of unused credits about to lapse. It
+// is a CreditExpiryHook.
+func (s *EmailService) SendCreditsExpiring(investor *models.User, payment *models.Payment) {
+	if payment.ExpiresAt == nil {
+		return
+	}
+	s.send(investor, mail.TemplateCreditsExpiring, map[string]interface{}{
+		"Credits":   payment.ProjectsRemaining,
+		"ExpiresAt": *payment.ExpiresAt,
+		"URL":       s.link("/projects", nil),
+	})
+}
+
+// user loads a recipient, or returns nil when they no longer exist
+func (s *EmailService) user(id uuid.UUID) *models.User {
+	return s.findUser(id)
+}
+
+// loadUser reads a recipient from the database
+func loadUser(id uuid.UUID) *models.User {
+	var user models.User
+	if err := database.GetDB().First(&user, "id = ?", id).Error; err != nil {
+		return nil
+	}
+	return &user
+}
+
+// link builds an absolute link into the app
+func (s *EmailService) link(path string, query url.Values) string {
+	link := strings.TrimSuffix(s.config.BaseURL, "/") + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
+
+func (s *EmailService) send(user *models.User, template string, data map[string]interface{}) {
+	if user == nil || user.Email == "" {
+		return
+	}
+
+	data["AppName"] = s.config.FromName
+	data["BaseURL"] = s.config.BaseURL
+	data["Name"] = user.FirstName
+	if user.FirstName == "" {
+		data["Name"] = "there"
+	}
+
+	msg, err := mail.Render(template, data)
+	if err != nil {
+		log.Error().Err(err).Str("template", template).Msg("Failed to render email")
+		return
+	}
+	msg.To = user.Email
+	msg.ToName = strings.Join(strings.Fields(user.FullName()), " ")
+
+	if s.mailer == nil {
+		log.Debug().Str("template", template).Str("user_id", user.ID.String()).Msg("Mail is disabled; email not sent")
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Error().Err(err).
+				Str("template", template).
+				Str("mailer", s.mailer.Name()).
+				Str("user_id", user.ID.String()).
+				Msg("Failed to send email")
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/mail"
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+// hostileText is user input that must not reach an HTML body as markup
+const hostileText = `<script>alert("x")</script> & <b>bold</b>`
+
+func TestEmailTemplatesRenderAndEscape(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+	svc := NewEmailService(testConfig(), mailer)
+
+	developer := &models.User{ID: uuid.New(), Email: "developer@example.com", FirstName: "Dev", LastName: hostileText, Role: models.RoleDeveloper}
+	investor := &models.User{ID: uuid.New(), Email: "investor@example.com", FirstName: hostileText, CompanyName: hostileText, Role: models.RoleInvestor, EmailVerifyToken: "verify+token/1"}
+	users := map[uuid.UUID]*models.User{developer.ID: developer, investor.ID: investor}
+	svc.findUser = func(id uuid.UUID) *models.User { return users[id] }
+
+	now := time.Now()
+	expires := now.AddDate(0, 1, 0)
+	project := &models.Project{ID: uuid.New(), DeveloperID: developer.ID, Title: hostileText, RejectionReason: hostileText}
+	meeting := &models.MeetingRequest{
+		ID:              uuid.New(),
+		InvestorID:      investor.ID,
+		ProjectID:       project.ID,
+		Investor:        investor,
+		Project:         project,
+		Message:         hostileText,
+		ProposedTimes:   hostileText,
+		MeetingType:     "in_person",
+		ResponseMessage: hostileText,
+		MeetingLink:     "https://meet.example.com/abc?x=1&y=2",
+		ScheduledAt:     &now,
+		ExpiresAt:       expires,
+	}
+
+	svc.SendVerificationEmail(investor)
+	svc.SendPasswordReset(investor, "reset+token/1", passwordResetTTL)
+	svc.SendNDASigned(investor.ID, SignedDocument{Title: "master NDA", SignedName: hostileText, SignedAt: now, ExpiresAt: &expires, DocumentHash: "abc123"})
+	svc.SendMeetingRequested(meeting)
+	svc.SendMeetingAccepted(meeting)
+	svc.SendProjectApproved(project)
+	svc.SendProjectRejected(project)
+	svc.SendCreditsExpiring(investor, &models.Payment{ProjectsRemaining: 3, ExpiresAt: &expires})
+	svc.Wait()
+
+	sent := mailer.Messages()
+	if len(sent) != 8 {
+		t.Fatalf("sent %d emails, want one per template", len(sent))
+	}
+	for _, msg := range sent {
+		if msg.Subject == "" || strings.TrimSpace(msg.Text) == "" || msg.HTML == "" {
+			t.Fatalf("incomplete email to %s: %q", msg.To, msg.Subject)
+		}
+		if strings.Contains(msg.HTML, "<script>") || strings.Contains(msg.HTML, "<b>bold</b>") {
+			t.Fatalf("unescaped user input in %q:\n%s", msg.Subject, msg.HTML)
+		}
+	}
+
+	// find returns the one email whose subject contains s
+	find := func(s string) mail.Message {
+		t.Helper()
+		for _, msg := range sent {
+			if strings.Contains(msg.Subject, s) {
+				return msg
+			}
+		}
+		t.Fatalf("no email with subject containing %q", s)
+		return mail.Message{}
+	}
+
+	escaped := "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &lt;b&gt;bold&lt;/b&gt;"
+	for _, msg := range []mail.Message{
+		find("would like to meet"), // Project title, investor name and message
+		find("was not approved"),   // Project title and rejection reason
+		find("accepted"),           // Response message
+	} {
+		if !strings.Contains(msg.HTML, escaped) {
+			t.Fatalf("user input not escaped in %q:\n%s", msg.Subject, msg.HTML)
+		}
+		if !strings.Contains(msg.Text, hostileText) {
+			t.Fatalf("user input altered in the text body of %q:\n%s", msg.Subject, msg.Text)
+		}
+	}
+	if rejected := find("was not approved"); strings.Count(rejected.HTML, escaped) < 2 {
+		t.Fatalf("rejection reason missing from the HTML body:\n%s", rejected.HTML)
+	}
+
+	links := []struct {
+		subject, link string
+	}{
+		{"Confirm your", "https://app.test/verify-email?token=verify%2Btoken%2F1"},
+		{"Reset your", "https://app.test/reset-password?token=reset%2Btoken%2F1"},
+	}
+	for _, l := range links {
+		msg := find(l.subject)
+		if msg.To != investor.Email {
+			t.Fatalf("%q sent to %s", msg.Subject, msg.To)
+		}
+		if !strings.Contains(msg.Text, l.link) || !strings.Contains(msg.HTML, `href="`+l.link+`"`) {
+			t.Fatalf("%q does not link to %s:\n%s\n%s", msg.Subject, l.link, msg.Text, msg.HTML)
+		}
+	}
+}
+
+func TestEmailToMissingRecipientIsSkipped(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+	svc := NewEmailService(testConfig(), mailer)
+	svc.findUser = func(uuid.UUID) *models.User { return nil }
+
+	svc.SendProjectApproved(&models.Project{ID: uuid.New(), DeveloperID: uuid.New(), Title: "Gone"})
+	svc.Wait()
+
+	if sent := mailer.Messages(); len(sent) != 0 {
+		t.Fatalf("sent %d emails to a deleted user", len(sent))
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdentityNotLinked = errors.New("an account with this email already exists; sign in and link this provider from your profile")
+	ErrIdentityNotFound  = errors.New("linked account not found")
+)
+
+// findIdentityUser returns the user a provider account is linked to, or nil if
+// it is not linked. Accounts created before identities existed are matched on
+// their legacy OAuth ID and given an identity on first use.
+func findIdentityUser(db *gorm.DB, info *OAuthUserInfo) (*models.User, error) {
+	var identity models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", string(info.Provider), info.ID).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := db.First(&user, "id = ?", identity.UserID).Error; err != nil {
+			return nil, err
+		}
+		db.Model(&identity).Update("last_used_at", time.Now())
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.Where("oauth_id = ? AND auth_provider = ?", info.ID, info.Provider).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	identity = models.UserIdentity{
+		UserID:     user.ID,
+		Provider:   string(info.Provider),
+		Subject:    info.ID,
+		Email:      info.Email,
+		LastUsedAt: &now,
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LinkIdentity attaches a provider account to a signed-in user. A provider
+// account can only belong to one user.
+func (s *AuthService) LinkIdentity(userID uuid.UUID, info *OAuthUserInfo, ipAddress, userAgent string) (*models.UserIdentity, error) {
+	db := database.GetDB()
+
+	owner, err := findIdentityUser(db, info)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		if owner.ID != userID {
+			return nil, errors.New("this account is already linked to another user")
+		}
+		var identity models.UserIdentity
+		err := db.Where("provider = ? AND subject = ?", string(info.Provider), info.ID).First(&identity).Error
+		return &identity, err
+	}
+
+	identity := &models.UserIdentity{
+		UserID:   userID,
+		Provider: string(info.Provider),
+		Subject:  info.ID,
+		Email:    info.Email,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(identity)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("this account is already linked to another user")
+	}
+
+	s.logIdentityChange(db, userID, models.AuditActionUserIdentityLinked, identity, "Linked", ipAddress, userAgent)
+	return identity, nil
+}
+
+// ListIdentities returns the provider accounts linked to a user
+func (s *AuthService) ListIdentities(userID uuid.UUID) ([]models.UserIdentity, error) {
+	db := database.GetDB()
+
+	var identities []models.UserIdentity
+	err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkIdentity removes a linked provider account, as long as the user keeps
+// another way to sign in: a password, a passkey or another linked account
+func (s *AuthService) UnlinkIdentity(userID, identityID uuid.UUID, ipAddress, userAgent string) error {
+	db := database.GetDB()
+
+	var identity models.UserIdentity
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return errors.New("user not found")
+		}
+
+		if err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return ErrIdentityNotFound
+		}
+
+		var others, passkeys int64
+		tx.Model(&models.UserIdentity{}).Where("user_id = ? AND id <> ?", userID, identityID).Count(&others)
+		tx.Model(&models.Passkey{}).Where("user_id = ?", userID).Count(&passkeys)
+		if others == 0 && passkeys == 0 && user.PasswordHash == "" {
+			return errors.New("this is your only way to sign in; add a passkey or link another account first")
+		}
+
+		if err := tx.Delete(&identity).Error; err != nil {
+			return err
+		}
+
+		// Stop the legacy OAuth ID from re-linking the account on next sign-in
+		if err := tx.Model(&models.User{}).
+			Where("id = ? AND auth_provider = ? AND oauth_id = ?", userID, identity.Provider, identity.Subject).
+			Update("oauth_id", "").Error; err != nil {
+			return err
+		}
+
+		// Login, password changes and resets only serve email accounts, so an
+		// account that signed up with a provider and later set a password
+		// becomes one; otherwise the password would not let it back in
+		if user.PasswordHash != "" && user.AuthProvider != models.AuthProviderEmail {
+			return tx.Model(&user).Update("auth_provider", models.AuthProviderEmail).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logIdentityChange(db, userID, models.AuditActionUserIdentityUnlinked, &identity, "Unlinked", ipAddress, userAgent)
+	return nil
+}
+
+// logIdentityChange records a user linking or unlinking a provider account
+func (s *AuthService) logIdentityChange(db *gorm.DB, userID uuid.UUID, action models.AuditAction, identity *models.UserIdentity, verb, ipAddress, userAgent string) {
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return
+	}
+
+	s.auditService.LogAction(
+		&user.ID,
+		user.Email,
+		user.Role,
+		action,
+		"user",
+		&user.ID,
+		user.Email,
+		fmt.Sprintf("%s %s account %s", verb, identity.Provider, identity.Email),
+		map[string]interface{}{
+			"identity_id": identity.ID,
+			"provider":    identity.Provider,
+			"subject":     identity.Subject,
+		},
+		ipAddress,
+		userAgent,
+	)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+func TestUnlinkIdentityKeepsAWayToSignIn(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	svc := NewAuthService(cfg, NewAuditService(cfg), nil)
+
+	// Signed up with Google, then set a password
+	user := createTestUser(t, models.RoleInvestor)
+	user.AuthProvider = models.AuthProviderGoogle
+	if err := database.GetDB().Save(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	identity, err := svc.LinkIdentity(user.ID, &OAuthUserInfo{ID: "google-1", Email: user.Email, Provider: models.AuthProviderGoogle}, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.UnlinkIdentity(user.ID, identity.ID, "127.0.0.1", "test"); err == nil {
+		t.Fatal("unlinked the only way to sign in")
+	}
+
+	if err := user.SetPassword("a-long-password"); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(user).Update("password_hash", user.PasswordHash)
+	if err := svc.UnlinkIdentity(user.ID, identity.ID, "127.0.0.1", "test"); err != nil {
+		t.Fatalf("got %v unlinking with a password set", err)
+	}
+
+	// The password now signs the user in
+	resp, err := svc.Login(&LoginRequest{Email: user.Email, Password: "a-long-password"}, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("got %v signing in with the password after unlinking", err)
+	}
+	if resp.Token == "" {
+		t.Fatalf("no session started: %+v", resp)
+	}
+
+	var actions []models.AuditAction
+	db.Model(&models.AuditLog{}).Where("entity_id = ?", user.ID).Order("created_at ASC").Pluck("action", &actions)
+	if len(actions) != 2 || actions[0] != models.AuditActionUserIdentityLinked || actions[1] != models.AuditActionUserIdentityUnlinked {
+		t.Fatalf("got audit actions %v, want a link and an unlink", actions)
+	}
+}
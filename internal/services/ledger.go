@@ -0,0 +1,144 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// LedgerService reports on the credit ledger. Entries themselves are posted by
+// the services that move credits, inside their own transactions.
+type LedgerService struct {
+	config *config.Config
+}
+
+func NewLedgerService(cfg *config.Config) *LedgerService {
+	return &LedgerService{config: cfg}
+}
+
+// postLedgerEntry books a credit movement as part of tx. Zero-credit entries are skipped.
+func postLedgerEntry(tx *gorm.DB, entry *models.CreditLedgerEntry) error {
+	if entry.Credits == 0 {
+		return nil
+	}
+	return tx.Create(entry).Error
+}
+
+// accountLedgerEntries scopes ledger entries to an account: movements on the
+// organisation's pooled lots, whichever member made them, and the investor's own
+// movements outside the pool
+func accountLedgerEntries(account creditAccount) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Table("credit_ledger_entries e").
+			Joins("LEFT JOIN payments p ON p.id = e.payment_id")
+		if account.OrganizationID != nil {
+			return db.Where("(p.organization_id = ? OR (e.investor_id = ? AND (p.id IS NULL OR p.organization_id IS NULL)))",
+				*account.OrganizationID, account.InvestorID)
+		}
+		return db.Where("e.investor_id = ? AND (p.id IS NULL OR p.organization_id IS NULL)", account.InvestorID)
+	}
+}
+
+// liveLedgerEntries scopes ledger entries to an account's lots that can still be
+// spent from: entries on expired payments no longer count towards the balance,
+// even before the expiry sweep has posted their expiry entry
+func liveLedgerEntries(account creditAccount) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(accountLedgerEntries(account)).
+			Where("(p.id IS NULL OR p.expires_at IS NULL OR p.expires_at > ?)", time.Now())
+	}
+}
+
+// creditSummary derives an investor's spendable credits from the ledger,
+// including their organisation's pool
+func creditSummary(db *gorm.DB, investorID uuid.UUID) models.CreditSummary {
+	var summary models.CreditSummary
+	db.Scopes(liveLedgerEntries(creditAccountFor(db, investorID))).
+		Select(`COALESCE(SUM(e.credits), 0) AS balance,
+			COALESCE(SUM(CASE WHEN e.credits > 0 THEN e.credits ELSE 0 END), 0) AS credited,
+			COALESCE(SUM(CASE WHEN e.entry_type = ? THEN -e.credits ELSE 0 END), 0) AS used`,
+			models.LedgerEntryUnlock).
+		Scan(&summary)
+	return summary
+}
+
+// GetCreditBalance returns an investor's spendable credits
+func (s *LedgerService) GetCreditBalance(investorID uuid.UUID) models.CreditSummary {
+	return creditSummary(database.GetDB(), investorID)
+}
+
+// GetLedger returns an investor's credit movements, including those on their
+// organisation's pool, newest first
+func (s *LedgerService) GetLedger(investorID uuid.UUID, page, pageSize int) ([]models.CreditLedgerEntry, int64, error) {
+	db := database.GetDB()
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	query := db.Model(&models.CreditLedgerEntry{}).
+		Where("id IN (?)", db.Scopes(accountLedgerEntries(creditAccountFor(db, investorID))).Select("e.id"))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.CreditLedgerEntry
+	err := query.Preload("Project").
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+
+	return entries, total, err
+}
+
+// Reconcile compares the ledger with the payments table. A completed payment's
+// ledger entries should add up to its ProjectsRemaining; any other payment's
+// entries should add up to zero.
+func (s *LedgerService) Reconcile() (*models.LedgerReconciliation, error) {
+	db := database.GetDB()
+
+	report := &models.LedgerReconciliation{GeneratedAt: time.Now()}
+
+	db.Model(&models.CreditLedgerEntry{}).Select("COALESCE(SUM(credits), 0)").Scan(&report.LedgerBalance)
+	db.Model(&models.Payment{}).
+		Where("status = ?", models.PaymentStatusCompleted).
+		Select("COALESCE(SUM(projects_remaining), 0)").
+		Scan(&report.PaymentsRemaining)
+	db.Model(&models.Payment{}).Where("status <> ?", models.PaymentStatusPending).Count(&report.PaymentsChecked)
+	db.Model(&models.CreditLedgerEntry{}).Where("payment_id IS NULL").Count(&report.UnlinkedEntries)
+	db.Table("credit_ledger_entries e").
+		Where("e.entry_type = ?", models.LedgerEntryUnlock).
+		Where("NOT EXISTS (SELECT 1 FROM project_views v WHERE v.investor_id = e.investor_id AND v.project_id = e.project_id)").
+		Count(&report.UnlocksWithoutView)
+
+	err := db.Table("payments p").
+		Select(`p.id AS payment_id, p.investor_id, u.email AS investor_email, p.status, p.projects_remaining,
+			CASE WHEN p.status = ? THEN p.projects_remaining ELSE 0 END AS expected_balance,
+			COALESCE(SUM(e.credits), 0) AS ledger_balance,
+			COALESCE(SUM(e.credits), 0) - CASE WHEN p.status = ? THEN p.projects_remaining ELSE 0 END AS drift`,
+			models.PaymentStatusCompleted, models.PaymentStatusCompleted).
+		Joins("LEFT JOIN credit_ledger_entries e ON e.payment_id = p.id").
+		Joins("LEFT JOIN users u ON u.id = p.investor_id").
+		Where("p.deleted_at IS NULL AND p.status <> ?", models.PaymentStatusPending).
+		Group("p.id, p.investor_id, u.email, p.status, p.projects_remaining").
+		Having("COALESCE(SUM(e.credits), 0) <> CASE WHEN p.status = ? THEN p.projects_remaining ELSE 0 END",
+			models.PaymentStatusCompleted).
+		Order("p.created_at ASC").
+		Scan(&report.Drifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report.IsBalanced = len(report.Drifts) == 0 && report.UnlinkedEntries == 0 && report.UnlocksWithoutView == 0
+	return report, nil
+}
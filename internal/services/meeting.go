@@ -11,14 +11,16 @@ import (
 )
 
 type MeetingService struct {
-	config     *config.Config
-	ndaService *NDAService
+	config       *config.Config
+	ndaService   *NDAService
+	emailService *EmailService
 }
 
-func NewMeetingService(cfg *config.Config, ndaSvc *NDAService) *MeetingService {
+func NewMeetingService(cfg *config.Config, ndaSvc *NDAService, emailSvc *EmailService) *MeetingService {
 	return &MeetingService{
-		config:     cfg,
-		ndaService: ndaSvc,
+		config:       cfg,
+		ndaService:   ndaSvc,
+		emailService: emailSvc,
 	}
 }
 
@@ -100,6 +102,8 @@ func (s *MeetingService) CreateMeetingRequest(investorID uuid.UUID, input *Creat
 	// Load relations
 	db.Preload("Investor").Preload("Project").First(request, "id = ?", request.ID)
 
+	s.emailService.SendMeetingRequested(request)
+
 	return request, nil
 }
 
@@ -153,6 +157,10 @@ func (s *MeetingService) RespondToMeetingRequest(
 		return nil, err
 	}
 
+	if accept {
+		s.emailService.SendMeetingAccepted(&request)
+	}
+
 	return &request, nil
 }
 
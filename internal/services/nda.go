@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"text/template"
 	"time"
 
@@ -10,14 +11,29 @@ import (
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
 )
 
 type NDAService struct {
-	config *config.Config
+	config       *config.Config
+	auditService *AuditService
+	emailService *EmailService
 }
 
-func NewNDAService(cfg *config.Config) *NDAService {
-	return &NDAService{config: cfg}
+func NewNDAService(cfg *config.Config, auditSvc *AuditService, emailSvc *EmailService) *NDAService {
+	return &NDAService{config: cfg, auditService: auditSvc, emailService: emailSvc}
+}
+
+// latestMasterNDA returns the master NDA covering an investor that runs longest:
+// their own, or the one their organisation's signatory signed for all members
+func latestMasterNDA(db *gorm.DB, investorID uuid.UUID) (*models.NDA, error) {
+	var nda models.NDA
+	if err := creditAccountFor(db, investorID).ndas(db).
+		Order("expires_at DESC").
+		First(&nda).Error; err != nil {
+		return nil, err
+	}
+	return &nda, nil
 }
 
 // SignNDARequest represents the request to sign an NDA
@@ -30,11 +46,7 @@ type SignNDARequest struct {
 func (s *NDAService) GetMasterNDAStatus(investorID uuid.UUID) *models.NDAStatusResponse {
 	db := database.GetDB()
 
-	var nda models.NDA
-	err := db.Where("investor_id = ?", investorID).
-		Order("signed_at DESC").
-		First(&nda).Error
-
+	nda, err := latestMasterNDA(db, investorID)
 	if err != nil {
 		return &models.NDAStatusResponse{
 			HasMasterNDA:   false,
@@ -47,6 +59,7 @@ func (s *NDAService) GetMasterNDAStatus(investorID uuid.UUID) *models.NDAStatusR
 		MasterNDAValid:   nda.IsValid(),
 		MasterNDAExpires: &nda.ExpiresAt,
 		SignedAt:         &nda.SignedAt,
+		OrganizationID:   nda.OrganizationID,
 	}
 }
 
@@ -84,11 +97,8 @@ func (s *NDAService) GetMasterNDAContent(investorID uuid.UUID) (string, error) {
 func (s *NDAService) SignMasterNDA(investorID uuid.UUID, req *SignNDARequest, ipAddress, userAgent string) (*models.NDA, error) {
 	db := database.GetDB()
 
-	// Check if already has valid NDA
-	var existing models.NDA
-	err := db.Where("investor_id = ?", investorID).
-		Order("signed_at DESC").
-		First(&existing).Error
+	// Check if already has valid NDA, personally or through their organisation
+	existing, err := latestMasterNDA(db, investorID)
 	if err == nil && existing.IsValid() {
 		return nil, errors.New("you already have a valid NDA")
 	}
@@ -116,6 +126,90 @@ func (s *NDAService) SignMasterNDA(investorID uuid.UUID, req *SignNDARequest, ip
 		return nil, err
 	}
 
+	s.emailService.SendNDASigned(investorID, SignedDocument{
+		Title:        "master NDA",
+		SignedName:   nda.SignedName,
+		SignedAt:     nda.SignedAt,
+		ExpiresAt:    &nda.ExpiresAt,
+		DocumentHash: nda.DocumentHash,
+	})
+
+	return nda, nil
+}
+
+// SignOrganizationNDA signs the master NDA on behalf of the signer's organisation.
+// Only the organisation's authorised signatory can sign, and the NDA covers every
+// member for as long as they belong to the organisation.
+func (s *NDAService) SignOrganizationNDA(investorID uuid.UUID, req *SignNDARequest, ipAddress, userAgent string) (*models.NDA, error) {
+	db := database.GetDB()
+
+	member, err := organizationMembership(db, investorID)
+	if err != nil {
+		return nil, errors.New("you do not belong to an organisation")
+	}
+
+	var org models.Organization
+	if err := db.First(&org, "id = ?", member.OrganizationID).Error; err != nil {
+		return nil, errors.New("organisation not found")
+	}
+	if org.SignatoryID == nil || *org.SignatoryID != investorID {
+		return nil, errors.New("only the organisation's authorised signatory can sign for it")
+	}
+
+	var existing models.NDA
+	if err := db.Where("organization_id = ?", org.ID).
+		Order("expires_at DESC").
+		First(&existing).Error; err == nil && existing.IsValid() {
+		return nil, errors.New("the organisation already has a valid NDA")
+	}
+
+	content, err := s.GetMasterNDAContent(investorID)
+	if err != nil {
+		return nil, err
+	}
+
+	nda := &models.NDA{
+		InvestorID:     investorID,
+		OrganizationID: &org.ID,
+		Version:        "1.0",
+		SignedName:     req.SignedName,
+		SignatureData:  req.SignatureData,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		DocumentHash:   models.HashDocument(content),
+		SignedAt:       time.Now(),
+		ExpiresAt:      time.Now().AddDate(s.config.NDAValidityYears, 0, 0),
+	}
+
+	if err := db.Create(nda).Error; err != nil {
+		return nil, err
+	}
+
+	var signer models.User
+	if err := db.First(&signer, "id = ?", investorID).Error; err == nil {
+		s.auditService.LogAction(
+			&signer.ID,
+			signer.Email,
+			signer.Role,
+			models.AuditActionNDAOrganizationSigned,
+			"organization",
+			&org.ID,
+			org.Name,
+			fmt.Sprintf("%s signed the master NDA for %s as %s", signer.Email, org.Name, req.SignedName),
+			map[string]interface{}{"nda_id": nda.ID, "expires_at": nda.ExpiresAt},
+			ipAddress,
+			userAgent,
+		)
+	}
+
+	s.emailService.SendNDASigned(investorID, SignedDocument{
+		Title:        "master NDA on behalf of " + org.Name,
+		SignedName:   nda.SignedName,
+		SignedAt:     nda.SignedAt,
+		ExpiresAt:    &nda.ExpiresAt,
+		DocumentHash: nda.DocumentHash,
+	})
+
 	return nda, nil
 }
 
@@ -124,10 +218,8 @@ func (s *NDAService) GetProjectNDAStatus(investorID, projectID uuid.UUID) *model
 	db := database.GetDB()
 
 	// Check master NDA
-	var masterNDA models.NDA
-	hasMasterNDA := db.Where("investor_id = ?", investorID).
-		Order("signed_at DESC").
-		First(&masterNDA).Error == nil && masterNDA.IsValid()
+	masterNDA, err := latestMasterNDA(db, investorID)
+	hasMasterNDA := err == nil && masterNDA.IsValid()
 
 	// Check project addendum
 	var addendum models.ProjectNDASignature
@@ -162,10 +254,8 @@ func (s *NDAService) GetProjectAddendumContent(investorID, projectID uuid.UUID)
 	db := database.GetDB()
 
 	// Get master NDA
-	var masterNDA models.NDA
-	if err := db.Where("investor_id = ?", investorID).
-		Order("signed_at DESC").
-		First(&masterNDA).Error; err != nil {
+	masterNDA, err := latestMasterNDA(db, investorID)
+	if err != nil {
 		return "", errors.New("master NDA not found")
 	}
 
@@ -223,10 +313,8 @@ func (s *NDAService) SignProjectAddendum(investorID, projectID uuid.UUID, req *S
 	db := database.GetDB()
 
 	// Verify master NDA exists and is valid
-	var masterNDA models.NDA
-	if err := db.Where("investor_id = ?", investorID).
-		Order("signed_at DESC").
-		First(&masterNDA).Error; err != nil {
+	masterNDA, err := latestMasterNDA(db, investorID)
+	if err != nil {
 		return nil, errors.New("master NDA required before signing project addendum")
 	}
 
@@ -272,6 +360,18 @@ func (s *NDAService) SignProjectAddendum(investorID, projectID uuid.UUID, req *S
 		return nil, err
 	}
 
+	title := "project NDA addendum"
+	var project models.Project
+	if err := db.Select("title").First(&project, "id = ?", projectID).Error; err == nil {
+		title += " for " + project.Title
+	}
+	s.emailService.SendNDASigned(investorID, SignedDocument{
+		Title:        title,
+		SignedName:   signature.SignedName,
+		SignedAt:     signature.SignedAt,
+		DocumentHash: signature.DocumentHash,
+	})
+
 	return signature, nil
 }
 
@@ -300,12 +400,13 @@ func (s *NDAService) UpdateProjectNDAConfig(projectID uuid.UUID, customTerms, ip
 	return db.Save(&config).Error
 }
 
-// GetInvestorNDAs returns all NDAs signed by an investor
+// GetInvestorNDAs returns all master NDAs covering an investor, including those
+// signed for their organisation
 func (s *NDAService) GetInvestorNDAs(investorID uuid.UUID) ([]models.NDA, error) {
 	db := database.GetDB()
 
 	var ndas []models.NDA
-	err := db.Where("investor_id = ?", investorID).
+	err := creditAccountFor(db, investorID).ndas(db).
 		Order("signed_at DESC").
 		Find(&ndas).Error
 
@@ -2,27 +2,59 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/oidc"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+// oauthStateTTL is how long the user has to complete a sign-in at the provider
+const oauthStateTTL = 10 * time.Minute
+
+// ErrInvalidOAuthState is returned for callbacks that were not started by this
+// browser, were already used or took too long
+var ErrInvalidOAuthState = errors.New("sign-in request is invalid or has expired; please try again")
+
 type OAuthService struct {
 	config         *config.Config
 	googleConfig   *oauth2.Config
 	linkedinConfig *oauth2.Config
+
+	// Profile endpoints, overridable to point at a fake provider
+	googleUserInfoURL   string
+	linkedinUserInfoURL string
+
+	// Verifies Sign in with Apple ID tokens against Apple's published keys
+	appleVerifier *oidc.Verifier
+
+	// OIDC providers by name, and the client used for discovery, keys and tokens
+	oidcProviders map[string]*oidcProvider
+	httpClient    *http.Client
 }
 
 func NewOAuthService(cfg *config.Config) *OAuthService {
-	svc := &OAuthService{config: cfg}
+	svc := &OAuthService{
+		config:              cfg,
+		googleUserInfoURL:   "https://www.googleapis.com/oauth2/v2/userinfo",
+		linkedinUserInfoURL: "https://api.linkedin.com/v2/userinfo",
+		oidcProviders:       make(map[string]*oidcProvider, len(cfg.OIDCProviders)),
+		httpClient:          &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, p := range cfg.OIDCProviders {
+		svc.oidcProviders[p.Name] = &oidcProvider{config: p}
+	}
 
 	// Configure Google OAuth
 	if cfg.GoogleClientID != "" {
@@ -38,6 +70,15 @@ func NewOAuthService(cfg *config.Config) *OAuthService {
 		}
 	}
 
+	// Configure Sign in with Apple
+	if cfg.AppleClientID != "" {
+		svc.appleVerifier = &oidc.Verifier{
+			Issuer:   appleIssuer,
+			ClientID: cfg.AppleClientID,
+			Keys:     oidc.NewKeySet(svc.httpClient, appleIssuer+"/auth/keys"),
+		}
+	}
+
 	// Configure LinkedIn OAuth
 	if cfg.LinkedInClientID != "" {
 		svc.linkedinConfig = &oauth2.Config{
@@ -72,25 +113,25 @@ type OAuthUserInfo struct {
 
 // Google OAuth
 
-func (s *OAuthService) GetGoogleAuthURL(state string) string {
+func (s *OAuthService) GetGoogleAuthURL(state, verifier string) string {
 	if s.googleConfig == nil {
 		return ""
 	}
-	return s.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	return s.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier))
 }
 
-func (s *OAuthService) HandleGoogleCallback(ctx context.Context, code string) (*OAuthUserInfo, error) {
+func (s *OAuthService) HandleGoogleCallback(ctx context.Context, code, verifier string) (*OAuthUserInfo, error) {
 	if s.googleConfig == nil {
 		return nil, errors.New("Google OAuth not configured")
 	}
 
-	token, err := s.googleConfig.Exchange(ctx, code)
+	token, err := s.googleConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
 
 	client := s.googleConfig.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	resp, err := client.Get(s.googleUserInfoURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
@@ -128,19 +169,19 @@ func (s *OAuthService) HandleGoogleCallback(ctx context.Context, code string) (*
 
 // LinkedIn OAuth
 
-func (s *OAuthService) GetLinkedInAuthURL(state string) string {
+func (s *OAuthService) GetLinkedInAuthURL(state, verifier string) string {
 	if s.linkedinConfig == nil {
 		return ""
 	}
-	return s.linkedinConfig.AuthCodeURL(state)
+	return s.linkedinConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
 }
 
-func (s *OAuthService) HandleLinkedInCallback(ctx context.Context, code string) (*OAuthUserInfo, error) {
+func (s *OAuthService) HandleLinkedInCallback(ctx context.Context, code, verifier string) (*OAuthUserInfo, error) {
 	if s.linkedinConfig == nil {
 		return nil, errors.New("LinkedIn OAuth not configured")
 	}
 
-	token, err := s.linkedinConfig.Exchange(ctx, code)
+	token, err := s.linkedinConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -148,7 +189,7 @@ func (s *OAuthService) HandleLinkedInCallback(ctx context.Context, code string)
 	client := s.linkedinConfig.Client(ctx, token)
 	
 	// Get user profile using OpenID Connect
-	resp, err := client.Get("https://api.linkedin.com/v2/userinfo")
+	resp, err := client.Get(s.linkedinUserInfoURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
@@ -185,48 +226,47 @@ func (s *OAuthService) HandleLinkedInCallback(ctx context.Context, code string)
 
 // Apple OAuth
 
-type AppleClaims struct {
-	jwt.RegisteredClaims
-	Email         string `json:"email"`
-	EmailVerified string `json:"email_verified"`
-}
+// appleIssuer issues Sign in with Apple ID tokens and publishes their keys
+const appleIssuer = "https://appleid.apple.com"
 
-func (s *OAuthService) GetAppleAuthURL(state string) string {
+func (s *OAuthService) GetAppleAuthURL(state, nonce string) string {
 	if s.config.AppleClientID == "" {
 		return ""
 	}
 	
 	// Apple uses form_post response mode
 	return fmt.Sprintf(
-		"https://appleid.apple.com/auth/authorize?client_id=%s&redirect_uri=%s&response_type=code id_token&scope=name email&response_mode=form_post&state=%s",
+		"https://appleid.apple.com/auth/authorize?client_id=%s&redirect_uri=%s&response_type=code id_token&scope=name email&response_mode=form_post&state=%s&nonce=%s",
 		s.config.AppleClientID,
 		s.config.AppleRedirectURL,
 		state,
+		nonce,
 	)
 }
 
-func (s *OAuthService) HandleAppleCallback(ctx context.Context, code string, idToken string, userData string) (*OAuthUserInfo, error) {
-	if s.config.AppleClientID == "" {
+// HandleAppleCallback verifies the ID token Apple posted with the callback:
+// its signature against Apple's keys, issuer, audience, expiry and the nonce
+// sent with the authorization request. The token is posted by the browser, so
+// nothing in it can be trusted until then.
+func (s *OAuthService) HandleAppleCallback(ctx context.Context, code, idToken, userData, nonce string) (*OAuthUserInfo, error) {
+	if s.appleVerifier == nil {
 		return nil, errors.New("Apple OAuth not configured")
 	}
 
-	// Parse the ID token (already provided by Apple in form_post)
-	token, _, err := new(jwt.Parser).ParseUnverified(idToken, &AppleClaims{})
+	claims, err := s.appleVerifier.Verify(ctx, idToken, nonce)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse ID token: %w", err)
-	}
-
-	claims, ok := token.Claims.(*AppleClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
+		return nil, err
 	}
 
 	info := &OAuthUserInfo{
-		ID:            claims.Subject,
-		Email:         claims.Email,
-		EmailVerified: claims.EmailVerified == "true",
+		ID:            claimString(claims, "sub"),
+		Email:         claimString(claims, "email"),
+		EmailVerified: claimBool(claims, "email_verified"),
 		Provider:      models.AuthProviderApple,
 	}
+	if info.ID == "" {
+		return nil, errors.New("ID token has no subject")
+	}
 
 	// Apple only sends name on first authorization
 	if userData != "" {
@@ -259,7 +299,165 @@ func (s *OAuthService) IsAppleEnabled() bool {
 	return s.config.AppleClientID != ""
 }
 
-// GenerateState creates a secure random state for OAuth
+// IsEnabled reports whether a provider is configured
+func (s *OAuthService) IsEnabled(provider models.AuthProvider) bool {
+	switch provider {
+	case models.AuthProviderGoogle:
+		return s.IsGoogleEnabled()
+	case models.AuthProviderLinkedIn:
+		return s.IsLinkedInEnabled()
+	case models.AuthProviderApple:
+		return s.IsAppleEnabled()
+	}
+	if name, ok := provider.OIDCName(); ok {
+		_, configured := s.oidcProviders[name]
+		return configured
+	}
+	return false
+}
+
+// AuthURL returns the URL that sends the browser to the provider of a sign-in
+// started with BeginLogin
+func (s *OAuthService) AuthURL(ctx context.Context, oauthState *models.OAuthState, state string) (string, error) {
+	switch oauthState.Provider {
+	case models.AuthProviderGoogle:
+		return s.GetGoogleAuthURL(state, oauthState.CodeVerifier), nil
+	case models.AuthProviderLinkedIn:
+		return s.GetLinkedInAuthURL(state, oauthState.CodeVerifier), nil
+	case models.AuthProviderApple:
+		return s.GetAppleAuthURL(state, oauthState.Nonce), nil
+	}
+	return s.GetOIDCAuthURL(ctx, oauthState.Provider, state, oauthState.CodeVerifier, oauthState.Nonce)
+}
+
+// ProviderInfo describes a sign-in option for the login page
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"` // "oauth" or "oidc"
+	URL         string `json:"url"`  // Endpoint returning the authorization URL
+	Enabled     bool   `json:"enabled"`
+}
+
+// Providers lists the configured sign-in providers
+func (s *OAuthService) Providers() []ProviderInfo {
+	providers := []ProviderInfo{}
+
+	builtIn := []struct {
+		provider    models.AuthProvider
+		displayName string
+	}{
+		{models.AuthProviderGoogle, "Google"},
+		{models.AuthProviderLinkedIn, "LinkedIn"},
+		{models.AuthProviderApple, "Apple"},
+	}
+	for _, b := range builtIn {
+		if s.IsEnabled(b.provider) {
+			providers = append(providers, ProviderInfo{
+				Name:        string(b.provider),
+				DisplayName: b.displayName,
+				Type:        "oauth",
+				URL:         "/api/auth/" + string(b.provider),
+				Enabled:     true,
+			})
+		}
+	}
+
+	// In configuration order, so the login page is stable
+	for _, p := range s.config.OIDCProviders {
+		providers = append(providers, ProviderInfo{
+			Name:        string(models.OIDCProvider(p.Name)),
+			DisplayName: p.DisplayName,
+			Type:        "oidc",
+			URL:         "/api/auth/oidc/" + p.Name,
+			Enabled:     true,
+		})
+	}
+
+	return providers
+}
+
+// GenerateState returns a random value for OAuth state and browser binding
 func GenerateState() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return generateToken()
+}
+
+// OAuth state
+
+// BeginLogin records a new sign-in with a provider, or a link of a provider
+// account to linkUserID, and returns the state to send it. binding identifies
+// the browser (the caller keeps it in a cookie) and must be presented again at
+// the callback. Google, LinkedIn and OIDC providers also get a PKCE verifier,
+// so an intercepted code cannot be redeemed elsewhere, and OIDC providers and
+// Apple a nonce that binds the ID token to this sign-in.
+func (s *OAuthService) BeginLogin(provider models.AuthProvider, role models.UserRole, binding string, linkUserID *uuid.UUID) (string, *models.OAuthState, error) {
+	db := database.GetDB()
+
+	if role != models.RoleDeveloper {
+		role = models.RoleInvestor
+	}
+
+	state := GenerateState()
+	record := &models.OAuthState{
+		StateHash:   hashToken(state),
+		BindingHash: hashToken(binding),
+		Provider:    provider,
+		Role:        role,
+		LinkUserID:  linkUserID,
+		ExpiresAt:   time.Now().Add(oauthStateTTL),
+	}
+	_, isOIDC := provider.OIDCName()
+	if provider == models.AuthProviderGoogle || provider == models.AuthProviderLinkedIn || isOIDC {
+		record.CodeVerifier = oauth2.GenerateVerifier()
+	}
+	if isOIDC || provider == models.AuthProviderApple {
+		record.Nonce = GenerateState()
+	}
+
+	if err := db.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+	return state, record, nil
+}
+
+// ConsumeState checks a callback's state against the sign-in this browser
+// started and uses it up, so it cannot be replayed
+func (s *OAuthService) ConsumeState(provider models.AuthProvider, state, binding string) (*models.OAuthState, error) {
+	db := database.GetDB()
+
+	if state == "" || binding == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	var record models.OAuthState
+	if err := db.Where("state_hash = ? AND provider = ?", hashToken(state), provider).First(&record).Error; err != nil {
+		return nil, ErrInvalidOAuthState
+	}
+
+	// Delete first: whatever the outcome, this state is finished
+	result := db.Where("id = ?", record.ID).Delete(&models.OAuthState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidOAuthState
+	}
+	if subtle.ConstantTimeCompare([]byte(record.BindingHash), []byte(hashToken(binding))) != 1 {
+		return nil, ErrInvalidOAuthState
+	}
+
+	return &record, nil
+}
+
+// StateTTL is how long a sign-in may take, for sizing the binding cookie
+func (s *OAuthService) StateTTL() time.Duration {
+	return oauthStateTTL
+}
+
+// PurgeStates deletes sign-ins that were abandoned at the provider
+func (s *OAuthService) PurgeStates() (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{})
+	return result.RowsAffected, result.Error
 }
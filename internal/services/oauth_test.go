@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/oidc"
+	"golang.org/x/oauth2"
+)
+
+// fakeIdP is an OAuth provider that only issues tokens to clients presenting
+// the PKCE verifier for the challenge the authorization was started with
+type fakeIdP struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	challenges map[string]string // Authorization code to code_challenge
+	verifiers  []string          // code_verifier of each token request
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	idp := &fakeIdP{challenges: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer idp-access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":             "google-user-1",
+			"email":          "oauth@example.com",
+			"verified_email": true,
+			"given_name":     "OAuth",
+			"family_name":    "User",
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays the user approving the sign-in at the provider and returns
+// the code and state the provider would redirect back with
+func (idp *fakeIdP) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL has no S256 code challenge: %s", authURL)
+	}
+
+	code = GenerateState()
+	idp.mu.Lock()
+	idp.challenges[code] = q.Get("code_challenge")
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+// receivedVerifiers returns the code_verifier of each token request so far
+func (idp *fakeIdP) receivedVerifiers() []string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return append([]string(nil), idp.verifiers...)
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	code, verifier := r.PostForm.Get("code"), r.PostForm.Get("code_verifier")
+
+	idp.mu.Lock()
+	challenge, ok := idp.challenges[code]
+	delete(idp.challenges, code)
+	idp.verifiers = append(idp.verifiers, verifier)
+	idp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok || verifier == "" || oauth2.S256ChallengeFromVerifier(verifier) != challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "idp-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+// newFakeIdPOAuthService returns a service whose Google sign-in goes to idp
+func newFakeIdPOAuthService(idp *fakeIdP) *OAuthService {
+	cfg := testConfig()
+	cfg.GoogleClientID = "client-id"
+	cfg.GoogleClientSecret = "client-secret"
+	cfg.GoogleRedirectURL = "https://app.test/api/auth/google/callback"
+
+	svc := NewOAuthService(cfg)
+	svc.googleConfig.Endpoint = oauth2.Endpoint{
+		AuthURL:  idp.server.URL + "/authorize",
+		TokenURL: idp.server.URL + "/token",
+	}
+	svc.googleUserInfoURL = idp.server.URL + "/userinfo"
+	return svc
+}
+
+func TestGoogleCallbackSendsPKCEVerifier(t *testing.T) {
+	idp := newFakeIdP(t)
+	svc := newFakeIdPOAuthService(idp)
+	verifier := oauth2.GenerateVerifier()
+
+	code, _ := idp.authorize(t, svc.GetGoogleAuthURL("state", verifier))
+	info, err := svc.HandleGoogleCallback(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Email != "oauth@example.com" || info.Provider != models.AuthProviderGoogle {
+		t.Fatalf("unexpected user info %+v", info)
+	}
+	if got := idp.receivedVerifiers(); len(got) != 1 || got[0] != verifier {
+		t.Fatalf("provider received verifiers %q, want %q", got, verifier)
+	}
+
+	// An intercepted code is useless without the verifier
+	code, _ = idp.authorize(t, svc.GetGoogleAuthURL("state", verifier))
+	if _, err := svc.HandleGoogleCallback(context.Background(), code, oauth2.GenerateVerifier()); err == nil {
+		t.Fatal("code redeemed with the wrong verifier")
+	}
+}
+
+func TestOAuthCallbackRequiresMatchingStateAndBinding(t *testing.T) {
+	requireDB(t)
+	idp := newFakeIdP(t)
+	svc := newFakeIdPOAuthService(idp)
+
+	// begin starts a sign-in in a browser holding binding and returns the
+	// state the provider sends back
+	begin := func(binding string) string {
+		state, record, err := svc.BeginLogin(models.AuthProviderGoogle, models.RoleInvestor, binding, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authURL, err := svc.AuthURL(context.Background(), record, state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, returned := idp.authorize(t, authURL)
+		return returned
+	}
+
+	tests := []struct {
+		name    string
+		consume func(state string) error
+	}{
+		{"state mismatch", func(state string) error {
+			_, err := svc.ConsumeState(models.AuthProviderGoogle, GenerateState(), "browser")
+			return err
+		}},
+		{"other provider", func(state string) error {
+			_, err := svc.ConsumeState(models.AuthProviderLinkedIn, state, "browser")
+			return err
+		}},
+		{"missing binding", func(state string) error {
+			_, err := svc.ConsumeState(models.AuthProviderGoogle, state, "")
+			return err
+		}},
+		{"wrong binding", func(state string) error {
+			_, err := svc.ConsumeState(models.AuthProviderGoogle, state, "other-browser")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.consume(begin("browser")); !errors.Is(err, ErrInvalidOAuthState) {
+				t.Fatalf("got %v, want ErrInvalidOAuthState", err)
+			}
+		})
+	}
+
+	// A state presented from the wrong browser is spent, so the attacker's
+	// attempt cannot be retried with the right cookie either
+	state := begin("browser")
+	if _, err := svc.ConsumeState(models.AuthProviderGoogle, state, "other-browser"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("got %v, want ErrInvalidOAuthState", err)
+	}
+	if _, err := svc.ConsumeState(models.AuthProviderGoogle, state, "browser"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("got %v after a failed attempt, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestOAuthStateIsSingleUse(t *testing.T) {
+	requireDB(t)
+	idp := newFakeIdP(t)
+	svc := newFakeIdPOAuthService(idp)
+
+	state, record, err := svc.BeginLogin(models.AuthProviderGoogle, models.RoleInvestor, "browser", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := svc.AuthURL(context.Background(), record, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, returned := idp.authorize(t, authURL)
+	if returned != state {
+		t.Fatalf("provider returned state %q, want %q", returned, state)
+	}
+
+	consumed, err := svc.ConsumeState(models.AuthProviderGoogle, returned, "browser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.HandleGoogleCallback(context.Background(), code, consumed.CodeVerifier); err != nil {
+		t.Fatal(err)
+	}
+	if got := idp.receivedVerifiers(); len(got) != 1 || got[0] != record.CodeVerifier {
+		t.Fatalf("provider received verifiers %q, want the stored one", got)
+	}
+
+	if _, err := svc.ConsumeState(models.AuthProviderGoogle, returned, "browser"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("got %v reusing a state, want ErrInvalidOAuthState", err)
+	}
+}
+
+// fakeAppleKeys serves a JWKS for key under kid, standing in for Apple's
+// https://appleid.apple.com/auth/keys
+func fakeAppleKeys(t *testing.T, kid string, key *rsa.PrivateKey) *oidc.KeySet {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+	return oidc.NewKeySet(server.Client(), server.URL+"/auth/keys")
+}
+
+func TestAppleCallbackVerifiesIDToken(t *testing.T) {
+	appleKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attackerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	cfg.AppleClientID = "com.example.app"
+	svc := NewOAuthService(cfg)
+	svc.appleVerifier.Keys = fakeAppleKeys(t, "apple-1", appleKey)
+
+	const nonce = "apple-nonce"
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		now := time.Now()
+		c := jwt.MapClaims{
+			"iss":            appleIssuer,
+			"aud":            cfg.AppleClientID,
+			"sub":            "victim-apple-id",
+			"email":          "victim@example.com",
+			"email_verified": "true",
+			"nonce":          nonce,
+			"iat":            now.Unix(),
+			"exp":            now.Add(10 * time.Minute).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	sign := func(key *rsa.PrivateKey, c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+		token.Header["kid"] = "apple-1"
+		raw, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	info, err := svc.HandleAppleCallback(context.Background(), "code", sign(appleKey, claims(nil)), `{"name":{"firstName":"Apple","lastName":"User"}}`, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "victim-apple-id" || !info.EmailVerified || info.FirstName != "Apple" {
+		t.Fatalf("unexpected user info %+v", info)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"forged signature", sign(attackerKey, claims(nil)), nonce},
+		{"unsigned", unsigned, nonce},
+		{"other app", sign(appleKey, claims(func(c jwt.MapClaims) { c["aud"] = "com.example.other" })), nonce},
+		{"other issuer", sign(appleKey, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.test" })), nonce},
+		{"expired", sign(appleKey, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), nonce},
+		{"other sign-in", sign(appleKey, claims(nil)), "another-nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.HandleAppleCallback(context.Background(), "code", tt.token, "", tt.nonce); err == nil {
+				t.Fatal("accepted the ID token")
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+)
+
+type OfferService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewOfferService(cfg *config.Config, auditSvc *AuditService) *OfferService {
+	return &OfferService{
+		config:       cfg,
+		auditService: auditSvc,
+	}
+}
+
+// CreateOfferInput for submitting an investment offer
+type CreateOfferInput struct {
+	ProjectID        uuid.UUID  `json:"project_id" binding:"required"`
+	MeetingRequestID *uuid.UUID `json:"meeting_request_id"`
+	Amount           int64      `json:"amount" binding:"required,gt=0"` // Amount in minor units of Currency
+	Currency         string     `json:"currency"`                       // Defaults to the project's currency
+	EquityRequested  float64    `json:"equity_requested"`
+	ValuationCap     int64      `json:"valuation_cap"`
+	Message          string     `json:"message"`
+	HasMFN           bool       `json:"has_mfn"`
+	ProRataRights    bool       `json:"pro_rata_rights"`
+	DiscountRate     float64    `json:"discount_rate"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+}
+
+// CreateOffer submits a new investment offer from an investor on a project
+// If the offer references a meeting request, that meeting must be completed
+func (s *OfferService) CreateOffer(investorID uuid.UUID, input *CreateOfferInput, ipAddress, userAgent string) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	// Verify investor exists
+	var investor models.User
+	if err := db.First(&investor, "id = ? AND role = ?", investorID, models.RoleInvestor).Error; err != nil {
+		return nil, errors.New("investor not found")
+	}
+
+	// Verify project exists and is approved
+	var project models.Project
+	if err := db.First(&project, "id = ? AND status = ?", input.ProjectID, models.ProjectStatusApproved).Error; err != nil {
+		return nil, errors.New("project not found or not accepting offers")
+	}
+
+	if input.Amount < project.MinInvestment {
+		return nil, errors.New("offer amount is below the project's minimum investment")
+	}
+
+	if input.DiscountRate < 0 || input.DiscountRate >= 1 {
+		return nil, errors.New("discount rate must be between 0 and 1")
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry date must be in the future")
+	}
+
+	// Offers that follow a meeting must reference a completed meeting with this project
+	if input.MeetingRequestID != nil {
+		var meeting models.MeetingRequest
+		if err := db.First(&meeting, "id = ? AND investor_id = ? AND project_id = ?",
+			*input.MeetingRequestID, investorID, input.ProjectID).Error; err != nil {
+			return nil, errors.New("meeting request not found")
+		}
+		if meeting.Status != models.MeetingStatusCompleted {
+			return nil, errors.New("meeting must be completed before submitting an offer")
+		}
+	}
+
+	// Only one pending offer per investor per project
+	var existing models.InvestmentOffer
+	if err := db.Where("investor_id = ? AND project_id = ? AND status = ?",
+		investorID, input.ProjectID, models.OfferStatusPending).
+		First(&existing).Error; err == nil {
+		return nil, errors.New("you already have a pending offer for this project")
+	}
+
+	// Offers count towards the project's raise, so they are made in its currency
+	currency := strings.ToLower(input.Currency)
+	if currency == "" {
+		currency = project.Currency
+	}
+	if currency != project.Currency {
+		return nil, fmt.Errorf("offers on this project must be made in %s", strings.ToUpper(project.Currency))
+	}
+
+	offer := &models.InvestmentOffer{
+		InvestorID:       investorID,
+		ProjectID:        input.ProjectID,
+		MeetingRequestID: input.MeetingRequestID,
+		Amount:           input.Amount,
+		Currency:         currency,
+		EquityRequested:  input.EquityRequested,
+		ValuationCap:     input.ValuationCap,
+		Message:          input.Message,
+		HasMFN:           input.HasMFN,
+		ProRataRights:    input.ProRataRights,
+		DiscountRate:     input.DiscountRate,
+		Status:           models.OfferStatusPending,
+		CurrentRevision:  1,
+		LastRevisedBy:    models.RoleInvestor,
+		ExpiresAt:        input.ExpiresAt,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(offer).Error; err != nil {
+			return err
+		}
+		// The original terms are recorded as the first revision
+		revision := &models.OfferRevision{
+			OfferID:        offer.ID,
+			Version:        1,
+			ProposedByID:   investorID,
+			ProposedByRole: models.RoleInvestor,
+			Amount:         offer.Amount,
+			ValuationCap:   offer.ValuationCap,
+			DiscountRate:   offer.DiscountRate,
+			HasMFN:         offer.HasMFN,
+			ProRataRights:  offer.ProRataRights,
+			Message:        offer.Message,
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Project{}).
+			Where("id = ?", project.ID).
+			Update("offer_count", gorm.Expr("offer_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.LogOfferAction(&investor, models.AuditActionOfferCreated, offer, &project,
+		"Investor submitted an offer", ipAddress, userAgent)
+
+	// Load relations
+	db.Preload("Investor").Preload("Project").First(offer, "id = ?", offer.ID)
+
+	return offer, nil
+}
+
+// WithdrawOffer allows an investor to withdraw their pending offer
+func (s *OfferService) WithdrawOffer(investorID, offerID uuid.UUID, ipAddress, userAgent string) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	var offer models.InvestmentOffer
+	if err := db.Preload("Investor").Preload("Project").
+		First(&offer, "id = ? AND investor_id = ?", offerID, investorID).Error; err != nil {
+		return nil, errors.New("offer not found")
+	}
+
+	if !offer.IsPending() {
+		return nil, errors.New("can only withdraw pending offers")
+	}
+
+	// An accept, reject or counter-offer made meanwhile wins
+	now := time.Now()
+	result := db.Model(&models.InvestmentOffer{}).
+		Where("id = ? AND status = ? AND current_revision = ?", offer.ID, models.OfferStatusPending, offer.CurrentRevision).
+		Updates(map[string]interface{}{
+			"status":       models.OfferStatusWithdrawn,
+			"responded_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("offer has changed; review the latest terms before withdrawing")
+	}
+
+	offer.Status = models.OfferStatusWithdrawn
+	offer.RespondedAt = &now
+
+	s.auditService.LogOfferAction(offer.Investor, models.AuditActionOfferWithdrawn, &offer, offer.Project,
+		"Investor withdrew offer", ipAddress, userAgent)
+
+	return &offer, nil
+}
+
+// CounterOfferInput proposes a revised set of terms on a pending offer
+type CounterOfferInput struct {
+	Revision      int     `json:"revision" binding:"required,min=1"` // Revision being countered
+	Amount        int64   `json:"amount" binding:"required,gt=0"`    // Amount in minor units of Currency
+	ValuationCap  int64   `json:"valuation_cap"`
+	DiscountRate  float64 `json:"discount_rate"`
+	HasMFN        bool    `json:"has_mfn"`
+	ProRataRights bool    `json:"pro_rata_rights"`
+	Message       string  `json:"message"`
+}
+
+// CounterOffer records a new revision of terms proposed by either party.
+// Parties take turns: whoever proposed the current revision must wait for the other side.
+func (s *OfferService) CounterOffer(userID, offerID uuid.UUID, input *CounterOfferInput, ipAddress, userAgent string) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	offer, role, err := s.loadOfferForParty(userID, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCanRespond(offer); err != nil {
+		return nil, err
+	}
+
+	if offer.AwaitingResponseFrom() != role {
+		return nil, errors.New("waiting for the other party to respond to your latest terms")
+	}
+
+	if input.Revision != offer.CurrentRevision {
+		return nil, errors.New("offer terms have changed; only the latest revision can be countered")
+	}
+
+	if offer.Project != nil && input.Amount < offer.Project.MinInvestment {
+		return nil, errors.New("offer amount is below the project's minimum investment")
+	}
+
+	if input.DiscountRate < 0 || input.DiscountRate >= 1 {
+		return nil, errors.New("discount rate must be between 0 and 1")
+	}
+
+	revision := &models.OfferRevision{
+		OfferID:        offer.ID,
+		Version:        offer.CurrentRevision + 1,
+		ProposedByID:   userID,
+		ProposedByRole: role,
+		Amount:         input.Amount,
+		ValuationCap:   input.ValuationCap,
+		DiscountRate:   input.DiscountRate,
+		HasMFN:         input.HasMFN,
+		ProRataRights:  input.ProRataRights,
+		Message:        input.Message,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent counter or response on the same revision
+		result := tx.Model(&models.InvestmentOffer{}).
+			Where("id = ? AND status = ? AND current_revision = ?", offer.ID, models.OfferStatusPending, offer.CurrentRevision).
+			Updates(map[string]interface{}{
+				"current_revision": revision.Version,
+				"last_revised_by":  role,
+				"amount":           revision.Amount,
+				"valuation_cap":    revision.ValuationCap,
+				"discount_rate":    revision.DiscountRate,
+				"has_mfn":          revision.HasMFN,
+				"pro_rata_rights":  revision.ProRataRights,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("offer terms have changed; only the latest revision can be countered")
+		}
+		return tx.Create(revision).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offer.CurrentRevision = revision.Version
+	offer.LastRevisedBy = role
+	offer.Amount = revision.Amount
+	offer.ValuationCap = revision.ValuationCap
+	offer.DiscountRate = revision.DiscountRate
+	offer.HasMFN = revision.HasMFN
+	offer.ProRataRights = revision.ProRataRights
+
+	s.auditService.LogOfferAction(&user, models.AuditActionOfferCountered, offer, offer.Project,
+		fmt.Sprintf("Counter-offer proposed (revision %d)", revision.Version), ipAddress, userAgent)
+
+	return offer, nil
+}
+
+// GetOfferRevisions returns the full negotiation history of an offer, oldest first
+func (s *OfferService) GetOfferRevisions(userID, offerID uuid.UUID) ([]models.OfferRevision, error) {
+	db := database.GetDB()
+
+	if _, _, err := s.loadOfferForParty(userID, offerID); err != nil {
+		return nil, err
+	}
+
+	var revisions []models.OfferRevision
+	err := db.Where("offer_id = ?", offerID).
+		Order("version ASC").
+		Find(&revisions).Error
+
+	return revisions, err
+}
+
+// AcceptOffer allows the party awaiting a response to accept the latest revision.
+// Founders accept investor terms; investors accept a founder's counter-offer.
+// A revision of 0 means the original offer, for clients that predate counter-offers.
+func (s *OfferService) AcceptOffer(userID, offerID uuid.UUID, revision int, responseMessage, ipAddress, userAgent string) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	offer, role, err := s.loadOfferForParty(userID, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCanRespond(offer); err != nil {
+		return nil, err
+	}
+
+	if offer.AwaitingResponseFrom() != role {
+		return nil, errors.New("cannot accept your own proposed terms")
+	}
+
+	if revision == 0 {
+		revision = 1
+	}
+	if revision != offer.CurrentRevision {
+		return nil, errors.New("offer terms have changed; only the latest revision can be accepted")
+	}
+
+	var accepted models.OfferRevision
+	if err := db.First(&accepted, "offer_id = ? AND version = ?", offer.ID, offer.CurrentRevision).Error; err != nil {
+		return nil, errors.New("offer revision not found")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.InvestmentOffer{}).
+		Where("id = ? AND status = ? AND current_revision = ?", offer.ID, models.OfferStatusPending, offer.CurrentRevision).
+		Updates(map[string]interface{}{
+			"status":               models.OfferStatusAccepted,
+			"accepted_revision_id": accepted.ID,
+			"responded_at":         now,
+			"response_message":     responseMessage,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("offer terms have changed; only the latest revision can be accepted")
+	}
+
+	offer.Status = models.OfferStatusAccepted
+	offer.AcceptedRevisionID = &accepted.ID
+	offer.RespondedAt = &now
+	offer.ResponseMessage = responseMessage
+
+	description := "Developer accepted offer"
+	if role == models.RoleInvestor {
+		description = "Investor accepted counter-offer"
+	}
+	s.auditService.LogOfferAction(&user, models.AuditActionOfferAccepted, offer, offer.Project,
+		fmt.Sprintf("%s (revision %d)", description, accepted.Version), ipAddress, userAgent)
+
+	return offer, nil
+}
+
+// RejectOffer allows the project's developer to reject a pending offer
+func (s *OfferService) RejectOffer(developerID, offerID uuid.UUID, responseMessage, ipAddress, userAgent string) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	var developer models.User
+	if err := db.First(&developer, "id = ?", developerID).Error; err != nil {
+		return nil, errors.New("developer not found")
+	}
+
+	offer, role, err := s.loadOfferForParty(developerID, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleDeveloper {
+		return nil, errors.New("not authorized to respond to this offer")
+	}
+
+	if err := s.checkCanRespond(offer); err != nil {
+		return nil, err
+	}
+
+	// Only reject the terms the developer saw; a counter-offer made meanwhile wins
+	now := time.Now()
+	result := db.Model(&models.InvestmentOffer{}).
+		Where("id = ? AND status = ? AND current_revision = ?", offer.ID, models.OfferStatusPending, offer.CurrentRevision).
+		Updates(map[string]interface{}{
+			"status":           models.OfferStatusRejected,
+			"responded_at":     now,
+			"response_message": responseMessage,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("offer has changed; review the latest terms before rejecting")
+	}
+
+	offer.Status = models.OfferStatusRejected
+	offer.RespondedAt = &now
+	offer.ResponseMessage = responseMessage
+
+	s.auditService.LogOfferAction(&developer, models.AuditActionOfferRejected, offer, offer.Project,
+		"Developer rejected offer", ipAddress, userAgent)
+
+	return offer, nil
+}
+
+// loadOfferForParty loads an offer and resolves whether the user is its investor or the project's developer
+func (s *OfferService) loadOfferForParty(userID, offerID uuid.UUID) (*models.InvestmentOffer, models.UserRole, error) {
+	db := database.GetDB()
+
+	var offer models.InvestmentOffer
+	if err := db.Preload("Project").Preload("Investor").First(&offer, "id = ?", offerID).Error; err != nil {
+		return nil, "", errors.New("offer not found")
+	}
+
+	switch {
+	case offer.InvestorID == userID:
+		return &offer, models.RoleInvestor, nil
+	case offer.Project != nil && offer.Project.DeveloperID == userID:
+		return &offer, models.RoleDeveloper, nil
+	}
+
+	return nil, "", errors.New("not authorized to respond to this offer")
+}
+
+// checkCanRespond verifies the offer is still open, flagging offers that lapsed while pending
+func (s *OfferService) checkCanRespond(offer *models.InvestmentOffer) error {
+	if offer.CanRespond() {
+		return nil
+	}
+	if offer.IsPending() {
+		database.GetDB().Model(offer).Update("status", models.OfferStatusExpired)
+		return errors.New("offer has expired")
+	}
+	return errors.New("offer cannot be responded to (already processed)")
+}
+
+// GetInvestorOffers returns all offers submitted by an investor
+func (s *OfferService) GetInvestorOffers(investorID uuid.UUID, status string) ([]models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	query := db.Where("investor_id = ?", investorID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.InvestmentOffer
+	err := query.
+		Preload("Project").
+		Preload("TermSheet").
+		Order("created_at DESC").
+		Find(&offers).Error
+
+	return offers, err
+}
+
+// GetDeveloperOffers returns all offers received on a developer's projects
+func (s *OfferService) GetDeveloperOffers(developerID uuid.UUID, status string) ([]models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	// Get all project IDs for this developer
+	var projectIDs []uuid.UUID
+	db.Model(&models.Project{}).
+		Where("developer_id = ?", developerID).
+		Pluck("id", &projectIDs)
+
+	if len(projectIDs) == 0 {
+		return []models.InvestmentOffer{}, nil
+	}
+
+	query := db.Where("project_id IN ?", projectIDs)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.InvestmentOffer
+	err := query.
+		Preload("Investor").
+		Preload("Investor.InvestorProfile").
+		Preload("Project").
+		Preload("TermSheet").
+		Order("created_at DESC").
+		Find(&offers).Error
+
+	return offers, err
+}
+
+// GetOffer returns a single offer
+func (s *OfferService) GetOffer(offerID uuid.UUID) (*models.InvestmentOffer, error) {
+	db := database.GetDB()
+
+	var offer models.InvestmentOffer
+	if err := db.Preload("Investor").
+		Preload("Project").
+		Preload("TermSheet").
+		Preload("MeetingRequest").
+		First(&offer, "id = ?", offerID).Error; err != nil {
+		return nil, errors.New("offer not found")
+	}
+
+	return &offer, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is a configured OpenID Connect provider. Discovery runs on first
+// use, so an IdP that is down at boot does not stop the server; a failed
+// discovery is retried on the next sign-in.
+type oidcProvider struct {
+	config config.OIDCProviderConfig
+
+	mu        sync.Mutex
+	discovery *oidc.Discovery
+	oauth     *oauth2.Config
+	verifier  *oidc.Verifier
+}
+
+func (s *OAuthService) loadOIDCProvider(ctx context.Context, provider models.AuthProvider) (*oidcProvider, error) {
+	name, ok := provider.OIDCName()
+	if !ok {
+		return nil, errors.New("not an OIDC provider")
+	}
+	p, ok := s.oidcProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("OIDC provider %q is not configured", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery == nil {
+		discovery, err := oidc.Discover(ctx, s.httpClient, p.config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		p.discovery = discovery
+		p.oauth = &oauth2.Config{
+			ClientID:     p.config.ClientID,
+			ClientSecret: p.config.ClientSecret,
+			RedirectURL:  p.config.RedirectURL,
+			Scopes:       p.config.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		}
+		p.verifier = &oidc.Verifier{
+			Issuer:   discovery.Issuer,
+			ClientID: p.config.ClientID,
+			Keys:     oidc.NewKeySet(s.httpClient, discovery.JWKSURI),
+		}
+	}
+	return p, nil
+}
+
+// GetOIDCAuthURL returns the authorization URL for an OIDC provider
+func (s *OAuthService) GetOIDCAuthURL(ctx context.Context, provider models.AuthProvider, state, verifier, nonce string) (string, error) {
+	p, err := s.loadOIDCProvider(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// HandleOIDCCallback exchanges the code, verifies the ID token and maps its
+// claims (topped up from the userinfo endpoint) onto the user
+func (s *OAuthService) HandleOIDCCallback(ctx context.Context, provider models.AuthProvider, code, verifier, nonce string) (*OAuthUserInfo, error) {
+	p, err := s.loadOIDCProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.httpClient)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("provider did not return an ID token")
+	}
+
+	claims, err := p.verifier.Verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := p.config.Claims
+	if p.discovery.UserInfoEndpoint != "" && (claimString(claims, mapping.Email) == "" || claimString(claims, mapping.FirstName) == "") {
+		if extra, err := oidc.UserInfo(ctx, s.httpClient, p.discovery.UserInfoEndpoint, token.AccessToken); err == nil {
+			// Userinfo must describe the same user the ID token does
+			if sub, _ := extra["sub"].(string); sub == claimString(claims, "sub") {
+				for k, v := range extra {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	info := &OAuthUserInfo{
+		ID:            claimString(claims, mapping.Subject),
+		Email:         claimString(claims, mapping.Email),
+		FirstName:     claimString(claims, mapping.FirstName),
+		LastName:      claimString(claims, mapping.LastName),
+		ProfileImage:  claimString(claims, mapping.Picture),
+		EmailVerified: claimBool(claims, mapping.EmailVerified),
+		Provider:      provider,
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("ID token has no %q claim", mapping.Subject)
+	}
+	if info.Email == "" {
+		return nil, errors.New("provider did not share an email address")
+	}
+	return info, nil
+}
+
+// claimString reads a claim as a string; some IdPs send numeric IDs
+func claimString(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	}
+	return ""
+}
+
+// claimBool reads a boolean claim, accepting "true" as some IdPs send strings
+func claimBool(claims map[string]interface{}, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrganizationService manages investor organisations and their members
+type OrganizationService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewOrganizationService(cfg *config.Config, auditSvc *AuditService) *OrganizationService {
+	return &OrganizationService{config: cfg, auditService: auditSvc}
+}
+
+// creditAccount is whose credits, unlocks and NDA an investor can use: their own
+// and, for organisation members, the organisation's
+type creditAccount struct {
+	InvestorID     uuid.UUID
+	OrganizationID *uuid.UUID
+}
+
+// creditAccountFor resolves an investor's account from their membership
+func creditAccountFor(db *gorm.DB, investorID uuid.UUID) creditAccount {
+	account := creditAccount{InvestorID: investorID}
+	if member, err := organizationMembership(db, investorID); err == nil {
+		account.OrganizationID = &member.OrganizationID
+	}
+	return account
+}
+
+// organizationMembership returns the user's membership, if any
+func organizationMembership(db *gorm.DB, userID uuid.UUID) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := db.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// payments scopes a payment query to the account's lots: the organisation's pool
+// and the investor's own purchases made outside it
+func (a creditAccount) payments(db *gorm.DB) *gorm.DB {
+	if a.OrganizationID != nil {
+		return db.Where("(organization_id = ? OR (investor_id = ? AND organization_id IS NULL))",
+			*a.OrganizationID, a.InvestorID)
+	}
+	return db.Where("investor_id = ? AND organization_id IS NULL", a.InvestorID)
+}
+
+// views scopes a project view query to the projects the account has unlocked
+func (a creditAccount) views(db *gorm.DB) *gorm.DB {
+	if a.OrganizationID != nil {
+		return db.Where("(investor_id = ? OR organization_id = ?)", a.InvestorID, *a.OrganizationID)
+	}
+	return db.Where("investor_id = ?", a.InvestorID)
+}
+
+// ndas scopes an NDA query to the master NDAs that cover the account. An NDA a
+// signatory signed for an organisation only covers them while they are a member.
+func (a creditAccount) ndas(db *gorm.DB) *gorm.DB {
+	if a.OrganizationID != nil {
+		return db.Where("((investor_id = ? AND organization_id IS NULL) OR organization_id = ?)",
+			a.InvestorID, *a.OrganizationID)
+	}
+	return db.Where("investor_id = ? AND organization_id IS NULL", a.InvestorID)
+}
+
+// CreateOrganizationInput names a new organisation
+type CreateOrganizationInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddMemberInput adds an existing investor to an organisation
+type AddMemberInput struct {
+	Email string                  `json:"email" binding:"required,email"`
+	Role  models.OrganizationRole `json:"role" binding:"required,oneof=owner partner analyst"`
+}
+
+// CreateOrganization sets up an organisation for an institutional investor, who
+// becomes its owner and authorised signatory
+func (s *OrganizationService) CreateOrganization(userID uuid.UUID, input CreateOrganizationInput, ipAddress, userAgent string) (*models.Organization, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Preload("InvestorProfile").First(&user, "id = ? AND role = ?", userID, models.RoleInvestor).Error; err != nil {
+		return nil, errors.New("investor not found")
+	}
+	if user.InvestorProfile == nil || !user.InvestorProfile.IsInstitutional() {
+		return nil, errors.New("only institutional investors can create an organisation")
+	}
+	if _, err := organizationMembership(db, userID); err == nil {
+		return nil, errors.New("you already belong to an organisation")
+	}
+
+	org := &models.Organization{
+		Name:        strings.TrimSpace(input.Name),
+		SignatoryID: &user.ID,
+		CreatedByID: user.ID,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		// The unique user index stops a concurrent second organisation
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logOrganizationAction(&user, models.AuditActionOrganizationCreated, org,
+		fmt.Sprintf("Created organisation %s", org.Name), nil, ipAddress, userAgent)
+
+	return s.loadOrganization(org.ID)
+}
+
+// GetOrganization returns the caller's organisation with its members, and the
+// caller's membership
+func (s *OrganizationService) GetOrganization(userID uuid.UUID) (*models.Organization, *models.OrganizationMember, error) {
+	member, err := organizationMembership(database.GetDB(), userID)
+	if err != nil {
+		return nil, nil, errors.New("you do not belong to an organisation")
+	}
+
+	org, err := s.loadOrganization(member.OrganizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, member, nil
+}
+
+// ListOrganizations returns every organisation with its members (admin)
+func (s *OrganizationService) ListOrganizations() ([]models.Organization, error) {
+	db := database.GetDB()
+
+	var orgs []models.Organization
+	err := db.Preload("Members.User").
+		Preload("Signatory").
+		Order("created_at DESC").
+		Find(&orgs).Error
+	return orgs, err
+}
+
+// AddMember adds an investor who is not yet in an organisation
+func (s *OrganizationService) AddMember(actorID uuid.UUID, input AddMemberInput, ipAddress, userAgent string) (*models.OrganizationMember, error) {
+	db := database.GetDB()
+
+	actor, org, err := s.loadManager(actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.Where("LOWER(email) = ? AND role = ? AND is_active = ?",
+		strings.ToLower(strings.TrimSpace(input.Email)), models.RoleInvestor, true).
+		First(&user).Error; err != nil {
+		return nil, errors.New("no active investor with that email")
+	}
+	if _, err := organizationMembership(db, user.ID); err == nil {
+		return nil, errors.New("investor already belongs to an organisation")
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+		Role:           input.Role,
+		AddedByID:      &actor.ID,
+	}
+	if err := db.Create(member).Error; err != nil {
+		return nil, errors.New("investor already belongs to an organisation")
+	}
+	member.User = &user
+
+	s.logOrganizationAction(actor, models.AuditActionOrgMemberAdded, org,
+		fmt.Sprintf("Added %s to %s as %s", user.Email, org.Name, input.Role),
+		map[string]interface{}{"member_id": user.ID, "role": input.Role}, ipAddress, userAgent)
+
+	return member, nil
+}
+
+// UpdateMemberRole changes a member's role. An organisation always keeps an owner,
+// and a signatory demoted to analyst loses signing authority.
+func (s *OrganizationService) UpdateMemberRole(actorID, memberUserID uuid.UUID, role models.OrganizationRole, ipAddress, userAgent string) (*models.OrganizationMember, error) {
+	if !models.IsValidOrganizationRole(role) {
+		return nil, errors.New("invalid role")
+	}
+
+	actor, org, err := s.loadManager(actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var member models.OrganizationMember
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.lockMember(tx, org, memberUserID, &member); err != nil {
+			return err
+		}
+
+		if member.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			if err := s.requireAnotherOwner(tx, org.ID, member.UserID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&member).Update("role", role).Error; err != nil {
+			return err
+		}
+		if !member.CanSignForOrganization() && org.SignatoryID != nil && *org.SignatoryID == member.UserID {
+			return tx.Model(org).Update("signatory_id", nil).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logOrganizationAction(actor, models.AuditActionOrgMemberRoleChanged, org,
+		fmt.Sprintf("Changed role of member %s to %s", member.UserID, role),
+		map[string]interface{}{"member_id": member.UserID, "role": role}, ipAddress, userAgent)
+
+	return &member, nil
+}
+
+// RemoveMember takes a member out of the organisation. Owners can remove anyone;
+// any member can remove themselves. Credits bought for the pool and unlocks made
+// while a member stay with the organisation. The last member leaving closes it.
+func (s *OrganizationService) RemoveMember(actorID, memberUserID uuid.UUID, ipAddress, userAgent string) error {
+	db := database.GetDB()
+
+	var actor models.User
+	if err := db.First(&actor, "id = ?", actorID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	actorMembership, err := organizationMembership(db, actorID)
+	if err != nil {
+		return errors.New("you do not belong to an organisation")
+	}
+	if actorID != memberUserID && !actorMembership.CanManageMembers() {
+		return errors.New("only owners can remove members")
+	}
+
+	var org models.Organization
+	if err := db.First(&org, "id = ?", actorMembership.OrganizationID).Error; err != nil {
+		return errors.New("organisation not found")
+	}
+
+	closed := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var member models.OrganizationMember
+		if err := s.lockMember(tx, &org, memberUserID, &member); err != nil {
+			return err
+		}
+
+		var others int64
+		tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id <> ?", org.ID, member.UserID).
+			Count(&others)
+
+		if member.Role == models.OrgRoleOwner && others > 0 {
+			if err := s.requireAnotherOwner(tx, org.ID, member.UserID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		if org.SignatoryID != nil && *org.SignatoryID == member.UserID {
+			if err := tx.Model(&org).Update("signatory_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if others == 0 {
+			closed = true
+			return tx.Delete(&org).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Removed member %s from %s", memberUserID, org.Name)
+	if actorID == memberUserID {
+		description = fmt.Sprintf("%s left %s", actor.Email, org.Name)
+	}
+	if closed {
+		description += "; organisation closed"
+	}
+	s.logOrganizationAction(&actor, models.AuditActionOrgMemberRemoved, &org, description,
+		map[string]interface{}{"member_id": memberUserID}, ipAddress, userAgent)
+
+	return nil
+}
+
+// SetSignatory authorises an owner or partner to sign the master NDA for the
+// organisation
+func (s *OrganizationService) SetSignatory(actorID, memberUserID uuid.UUID, ipAddress, userAgent string) (*models.Organization, error) {
+	actor, org, err := s.loadManager(actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var member models.OrganizationMember
+		if err := s.lockMember(tx, org, memberUserID, &member); err != nil {
+			return err
+		}
+		if !member.CanSignForOrganization() {
+			return errors.New("the signatory must be an owner or partner")
+		}
+		return tx.Model(org).Update("signatory_id", member.UserID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logOrganizationAction(actor, models.AuditActionOrgSignatoryChanged, org,
+		fmt.Sprintf("Made member %s the authorised signatory of %s", memberUserID, org.Name),
+		map[string]interface{}{"signatory_id": memberUserID}, ipAddress, userAgent)
+
+	return s.loadOrganization(org.ID)
+}
+
+func (s *OrganizationService) loadOrganization(orgID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := database.GetDB().
+		Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("joined_at ASC") }).
+		Preload("Members.User").
+		Preload("Signatory").
+		First(&org, "id = ?", orgID).Error; err != nil {
+		return nil, errors.New("organisation not found")
+	}
+	return &org, nil
+}
+
+// loadManager returns an owner and their organisation
+func (s *OrganizationService) loadManager(userID uuid.UUID) (*models.User, *models.Organization, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, nil, errors.New("user not found")
+	}
+	member, err := organizationMembership(db, userID)
+	if err != nil {
+		return nil, nil, errors.New("you do not belong to an organisation")
+	}
+	if !member.CanManageMembers() {
+		return nil, nil, errors.New("only owners can manage the organisation")
+	}
+
+	var org models.Organization
+	if err := db.First(&org, "id = ?", member.OrganizationID).Error; err != nil {
+		return nil, nil, errors.New("organisation not found")
+	}
+	return &user, &org, nil
+}
+
+// lockMember locks the organisation row, serialising membership changes, and
+// loads one of its members
+func (s *OrganizationService) lockMember(tx *gorm.DB, org *models.Organization, userID uuid.UUID, member *models.OrganizationMember) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(org, "id = ?", org.ID).Error; err != nil {
+		return errors.New("organisation not found")
+	}
+	if err := tx.Where("organization_id = ? AND user_id = ?", org.ID, userID).First(member).Error; err != nil {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+func (s *OrganizationService) requireAnotherOwner(tx *gorm.DB, orgID, userID uuid.UUID) error {
+	var owners int64
+	tx.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.OrgRoleOwner, userID).
+		Count(&owners)
+	if owners == 0 {
+		return errors.New("an organisation needs at least one owner")
+	}
+	return nil
+}
+
+func (s *OrganizationService) logOrganizationAction(actor *models.User, action models.AuditAction, org *models.Organization, description string, metadata map[string]interface{}, ipAddress, userAgent string) {
+	s.auditService.LogAction(
+		&actor.ID,
+		actor.Email,
+		actor.Role,
+		action,
+		"organization",
+		&org.ID,
+		org.Name,
+		description,
+		metadata,
+		ipAddress,
+		userAgent,
+	)
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/webauthn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultPasskeyName = "Passkey"
+
+var (
+	ErrInvalidPasskeyChallenge = errors.New("passkey request is invalid or has expired; please try again")
+	ErrPasskeyNotRecognised    = errors.New("passkey not recognised")
+	ErrPasskeyNotFound         = errors.New("passkey not found")
+)
+
+// Passkey requests follow the JSON form of a browser PublicKeyCredential
+// (credential.toJSON()), with binary fields base64url encoded.
+
+// PasskeyRegistrationRequest is the result of navigator.credentials.create()
+type PasskeyRegistrationRequest struct {
+	Name     string `json:"name" binding:"max=100"`
+	ID       string `json:"id" binding:"required"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// PasskeyLoginRequest is the result of navigator.credentials.get()
+type PasskeyLoginRequest struct {
+	ID       string `json:"id" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// relyingParty describes this deployment to authenticators
+func (s *AuthService) relyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:      s.config.WebAuthnRPID,
+		Name:    s.config.WebAuthnRPName,
+		Origins: s.config.WebAuthnOrigins,
+	}
+}
+
+// BeginPasskeyRegistration returns the options for adding a passkey to the
+// user's account
+func (s *AuthService) BeginPasskeyRegistration(userID uuid.UUID) (*webauthn.CreationOptions, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	var existing []models.Passkey
+	if err := db.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, p := range existing {
+		if id, err := decodeBase64URL(p.CredentialID); err == nil {
+			exclude = append(exclude, webauthn.NewCredentialDescriptor(id, splitTransports(p.Transports)))
+		}
+	}
+
+	challenge, err := issuePasskeyChallenge(db, models.PasskeyChallengeRegister, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user handle is the account ID, so a discoverable passkey identifies
+	// its owner at login
+	opts := s.relyingParty().CreationOptions(challenge, user.ID[:], user.Email, user.FullName(), exclude)
+	return &opts, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores
+// the new passkey
+func (s *AuthService) FinishPasskeyRegistration(userID uuid.UUID, req *PasskeyRegistrationRequest) (*models.Passkey, error) {
+	db := database.GetDB()
+
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("invalid client data")
+	}
+	attestationObject, err := decodeBase64URL(req.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("invalid attestation object")
+	}
+
+	challenge, err := consumePasskeyChallenge(db, clientDataJSON, models.PasskeyChallengeRegister)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == nil || *challenge.UserID != userID {
+		return nil, ErrInvalidPasskeyChallenge
+	}
+
+	cred, err := s.relyingParty().VerifyRegistration(challenge.Challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := webauthn.Encoding.EncodeToString(cred.ID)
+	if credentialID != strings.TrimRight(req.ID, "=") {
+		return nil, errors.New("credential ID does not match the attestation")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	passkey := &models.Passkey{
+		UserID:         userID,
+		CredentialID:   credentialID,
+		PublicKey:      cred.PublicKey,
+		Algorithm:      cred.Algorithm,
+		SignCount:      int64(cred.SignCount),
+		AAGUID:         formatAAGUID(cred.AAGUID),
+		Transports:     strings.Join(req.Response.Transports, ","),
+		Name:           name,
+		BackupEligible: cred.BackupEligible,
+		BackedUp:       cred.BackupState,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(passkey)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("this passkey is already registered")
+	}
+
+	return passkey, nil
+}
+
+// BeginPasskeyLogin returns the options for signing in with a passkey. With an
+// email, only that account's passkeys are offered; without one the browser
+// lists every passkey it holds for this site.
+func (s *AuthService) BeginPasskeyLogin(email string) (*webauthn.RequestOptions, error) {
+	db := database.GetDB()
+
+	allow := []webauthn.CredentialDescriptor{}
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		// Unknown emails get an empty list rather than an error, so the endpoint
+		// does not reveal which accounts exist
+		var passkeys []models.Passkey
+		db.Joins("JOIN users ON users.id = passkeys.user_id").
+			Where("LOWER(users.email) = ?", email).
+			Find(&passkeys)
+		for _, p := range passkeys {
+			if id, err := decodeBase64URL(p.CredentialID); err == nil {
+				allow = append(allow, webauthn.NewCredentialDescriptor(id, splitTransports(p.Transports)))
+			}
+		}
+	}
+
+	challenge, err := issuePasskeyChallenge(db, models.PasskeyChallengeLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := s.relyingParty().RequestOptions(challenge, allow)
+	return &opts, nil
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and signs the user in. A
+// passkey that verified the user (biometric or PIN) counts as two factors;
+// otherwise the usual TOTP challenge applies. Admins still need TOTP enrolled,
+// as refreshing a session requires it.
+func (s *AuthService) FinishPasskeyLogin(req *PasskeyLoginRequest, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("invalid client data")
+	}
+	authData, err := decodeBase64URL(req.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.New("invalid authenticator data")
+	}
+	signature, err := decodeBase64URL(req.Response.Signature)
+	if err != nil {
+		return nil, errors.New("invalid signature")
+	}
+
+	challenge, err := consumePasskeyChallenge(db, clientDataJSON, models.PasskeyChallengeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	var passkey models.Passkey
+	if err := db.Where("credential_id = ?", strings.TrimRight(req.ID, "=")).First(&passkey).Error; err != nil {
+		return nil, ErrPasskeyNotRecognised
+	}
+	if req.Response.UserHandle != "" {
+		handle, err := decodeBase64URL(req.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, passkey.UserID[:]) {
+			return nil, ErrPasskeyNotRecognised
+		}
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", passkey.UserID).Error; err != nil {
+		return nil, ErrPasskeyNotRecognised
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is disabled")
+	}
+
+	assertion, err := s.relyingParty().VerifyAssertion(challenge.Challenge, passkey.PublicKey, uint32(passkey.SignCount), clientDataJSON, authData, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// Conditional on the counter we verified against, so two logins racing with
+	// the same assertion cannot both succeed
+	now := time.Now()
+	result := db.Model(&models.Passkey{}).
+		Where("id = ? AND sign_count = ?", passkey.ID, passkey.SignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   int64(assertion.SignCount),
+			"backed_up":    assertion.BackupState,
+			"last_used_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, webauthn.ErrSignCountRegression
+	}
+
+	user.LastLoginAt = &now
+	db.Model(&user).Update("last_login_at", now)
+
+	if assertion.UserVerified && (!user.IsAdmin() || user.TOTPEnabled) {
+		return s.startSession(&user, ipAddress, userAgent)
+	}
+	return s.beginSession(&user, ipAddress, userAgent)
+}
+
+// ListPasskeys returns the user's passkeys, most recently added first
+func (s *AuthService) ListPasskeys(userID uuid.UUID) ([]models.Passkey, error) {
+	db := database.GetDB()
+
+	var passkeys []models.Passkey
+	err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&passkeys).Error
+	return passkeys, err
+}
+
+// RenamePasskey changes the label the user sees for a passkey
+func (s *AuthService) RenamePasskey(userID, passkeyID uuid.UUID, name string) (*models.Passkey, error) {
+	db := database.GetDB()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	var passkey models.Passkey
+	if err := db.Where("id = ? AND user_id = ?", passkeyID, userID).First(&passkey).Error; err != nil {
+		return nil, ErrPasskeyNotFound
+	}
+	if err := db.Model(&passkey).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// DeletePasskey removes a passkey so it can no longer sign in
+func (s *AuthService) DeletePasskey(userID, passkeyID uuid.UUID) error {
+	db := database.GetDB()
+
+	result := db.Where("id = ? AND user_id = ?", passkeyID, userID).Delete(&models.Passkey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// PurgePasskeyChallenges deletes challenges that were never used
+func (s *AuthService) PurgePasskeyChallenges() (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.PasskeyChallenge{})
+	return result.RowsAffected, result.Error
+}
+
+// issuePasskeyChallenge stores a new challenge for a ceremony
+func issuePasskeyChallenge(db *gorm.DB, purpose string, userID *uuid.UUID) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	record := &models.PasskeyChallenge{
+		Challenge: challenge,
+		Purpose:   purpose,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(webauthn.Timeout),
+	}
+	if err := db.Create(record).Error; err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumePasskeyChallenge finds the challenge the client signed and deletes it,
+// so each challenge can be answered once
+func consumePasskeyChallenge(db *gorm.DB, clientDataJSON []byte, purpose string) (*models.PasskeyChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge models.PasskeyChallenge
+	if err := db.Where("challenge = ? AND purpose = ?", clientData.Challenge, purpose).First(&challenge).Error; err != nil {
+		return nil, ErrInvalidPasskeyChallenge
+	}
+	result := db.Where("id = ?", challenge.ID).Delete(&models.PasskeyChallenge{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidPasskeyChallenge
+	}
+	return &challenge, nil
+}
+
+// decodeBase64URL accepts base64url with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return webauthn.Encoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func splitTransports(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// formatAAGUID renders an authenticator model ID, or nothing when the
+// authenticator withheld it
+func formatAAGUID(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil || id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/webauthn"
+	"github.com/ukuvago/angelvault/internal/webauthn/webauthntest"
+)
+
+// registerTestPasskey adds a passkey held by auth to the user's account
+func registerTestPasskey(t *testing.T, svc *AuthService, auth *webauthntest.SoftAuthenticator, user *models.User) []byte {
+	t.Helper()
+
+	opts, err := svc.BeginPasskeyRegistration(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := auth.Register(*opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req PasskeyRegistrationRequest
+	req.ID = webauthn.Encoding.EncodeToString(resp.CredentialID)
+	req.Response.ClientDataJSON = webauthn.Encoding.EncodeToString(resp.ClientDataJSON)
+	req.Response.AttestationObject = webauthn.Encoding.EncodeToString(resp.AttestationObject)
+	if _, err := svc.FinishPasskeyRegistration(user.ID, &req); err != nil {
+		t.Fatal(err)
+	}
+	return resp.CredentialID
+}
+
+func passkeyLoginRequest(resp *webauthntest.AssertionResponse) *PasskeyLoginRequest {
+	var req PasskeyLoginRequest
+	req.ID = webauthn.Encoding.EncodeToString(resp.CredentialID)
+	req.Response.ClientDataJSON = webauthn.Encoding.EncodeToString(resp.ClientDataJSON)
+	req.Response.AuthenticatorData = webauthn.Encoding.EncodeToString(resp.AuthenticatorData)
+	req.Response.Signature = webauthn.Encoding.EncodeToString(resp.Signature)
+	req.Response.UserHandle = webauthn.Encoding.EncodeToString(resp.UserHandle)
+	return &req
+}
+
+func TestPasskeyLoginChallengeIsSingleUse(t *testing.T) {
+	requireDB(t)
+	cfg := testConfig()
+	svc := NewAuthService(cfg, NewAuditService(cfg), nil)
+	auth := webauthntest.NewSoftAuthenticator(cfg.WebAuthnRPID, cfg.WebAuthnOrigins[0])
+	user := createTestUser(t, models.RoleInvestor)
+	credentialID := registerTestPasskey(t, svc, auth, user)
+
+	opts, err := svc.BeginPasskeyLogin(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := auth.Assert(credentialID, *opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := svc.FinishPasskeyLogin(passkeyLoginRequest(resp), "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Token == "" {
+		t.Fatalf("no session started: %+v", login)
+	}
+
+	if _, err := svc.FinishPasskeyLogin(passkeyLoginRequest(resp), "127.0.0.1", "test"); !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("got %v replaying a used challenge, want ErrInvalidPasskeyChallenge", err)
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountRegression(t *testing.T) {
+	requireDB(t)
+	cfg := testConfig()
+	svc := NewAuthService(cfg, NewAuditService(cfg), nil)
+	auth := webauthntest.NewSoftAuthenticator(cfg.WebAuthnRPID, cfg.WebAuthnOrigins[0])
+	user := createTestUser(t, models.RoleInvestor)
+	credentialID := registerTestPasskey(t, svc, auth, user)
+
+	// Both challenges are answered before either is submitted, and the later
+	// counter arrives first, as when a cloned authenticator falls behind
+	first, err := svc.BeginPasskeyLogin(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.BeginPasskeyLogin(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier, err := auth.Assert(credentialID, *first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := auth.Assert(credentialID, *second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.FinishPasskeyLogin(passkeyLoginRequest(later), "127.0.0.1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.FinishPasskeyLogin(passkeyLoginRequest(earlier), "127.0.0.1", "test"); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("got %v, want ErrSignCountRegression", err)
+	}
+}
@@ -2,82 +2,135 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/stripe/stripe-go/v76"
-	"github.com/stripe/stripe-go/v76/paymentintent"
-	"github.com/stripe/stripe-go/v76/webhook"
+	"github.com/rs/zerolog/log"
 	"github.com/ukuvago/angelvault/internal/config"
 	"github.com/ukuvago/angelvault/internal/database"
 	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentService struct {
-	config *config.Config
+	config         *config.Config
+	auditService   *AuditService
+	pricingService *PricingService
+	provider       payments.Provider // nil in demo mode
+	expiryHooks    []CreditExpiryHook
 }
 
-func NewPaymentService(cfg *config.Config) *PaymentService {
-	if cfg.StripeSecretKey != "" {
-		stripe.Key = cfg.StripeSecretKey
-	}
-	return &PaymentService{config: cfg}
+func NewPaymentService(cfg *config.Config, auditSvc *AuditService, pricingSvc *PricingService, provider payments.Provider) *PaymentService {
+	return &PaymentService{config: cfg, auditService: auditSvc, pricingService: pricingSvc, provider: provider}
+}
+
+// CheckoutInput selects the credit package to buy, the currency to pay in and an
+// optional promo code
+type CheckoutInput struct {
+	PackageID *uuid.UUID `json:"package_id"`
+	Currency  string     `json:"currency"` // Defaults to the package's base currency
+	PromoCode string     `json:"promo_code"`
 }
 
-// CreatePaymentIntent creates a Stripe payment intent for view credits
-func (s *PaymentService) CreatePaymentIntent(investorID uuid.UUID) (*models.Payment, string, error) {
+// CreatePaymentIntent creates a payment intent for a credit package. A promo
+// code that covers the whole price completes the purchase immediately.
+func (s *PaymentService) CreatePaymentIntent(investorID uuid.UUID, input CheckoutInput) (*models.Payment, string, error) {
 	db := database.GetDB()
 
 	// NOTE: Investors CAN purchase additional credits even if they have remaining views
 	// This allows them to "top up" their credits
 
+	// Organisation members buy into the shared pool; analysts spend from it only
+	var organizationID *uuid.UUID
+	if member, err := organizationMembership(db, investorID); err == nil {
+		if !member.CanPurchaseCredits() {
+			return nil, "", errors.New("analysts cannot purchase credits for the organisation")
+		}
+		organizationID = &member.OrganizationID
+	}
+
+	quote, err := s.pricingService.Quote(investorID, input.PackageID, input.Currency, input.PromoCode)
+	if err != nil {
+		return nil, "", err
+	}
+
 	// Create payment record
 	payment := &models.Payment{
 		InvestorID:        investorID,
-		Amount:            s.config.ViewFeeAmount,
-		Currency:          s.config.ViewFeeCurrency,
+		OrganizationID:    organizationID,
+		Amount:            quote.Total,
+		Currency:          quote.Currency,
 		Status:            models.PaymentStatusPending,
-		ProjectsTotal:     s.config.MaxProjectViews,
-		ProjectsRemaining: s.config.MaxProjectViews,
-		Description:       "Project viewing credits - access to view up to 4 projects",
+		PackageID:         &quote.Package.ID,
+		DiscountAmount:    quote.Discount,
+		ProjectsTotal:     quote.Package.Credits,
+		ProjectsRemaining: quote.Package.Credits,
+		Description:       fmt.Sprintf("%s - access to view up to %d projects", quote.Package.Name, quote.Package.Credits),
+	}
+	if promo := quote.Promo(); promo != nil {
+		payment.PromoCodeID = &promo.ID
 	}
 
-	if err := db.Create(payment).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		if err := s.pricingService.RedeemPromo(tx, quote, payment); err != nil {
+			return err
+		}
+		if quote.Total == 0 {
+			return s.completePayment(tx, payment, "", "")
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, "", err
 	}
 
-	// Create Stripe payment intent if configured
+	// Nothing to charge
+	if payment.Status == models.PaymentStatusCompleted {
+		return payment, "", nil
+	}
+
+	// Create the processor's payment intent if configured
 	var clientSecret string
-	if s.config.StripeSecretKey != "" {
-		params := &stripe.PaymentIntentParams{
-			Amount:   stripe.Int64(payment.Amount),
-			Currency: stripe.String(payment.Currency),
+	if s.provider != nil {
+		intent, err := s.provider.CreateIntent(payments.IntentParams{
+			Amount:   payment.Amount,
+			Currency: payment.Currency,
 			Metadata: map[string]string{
 				"payment_id":  payment.ID.String(),
 				"investor_id": investorID.String(),
+				"package_id":  quote.Package.ID.String(),
+				"promo_code":  quote.PromoCode,
 				"type":        "view_credits",
 			},
-			AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
-				Enabled: stripe.Bool(true),
-			},
-		}
-
-		pi, err := paymentintent.New(params)
+			IdempotencyKey: "payment-" + payment.ID.String(),
+		})
 		if err != nil {
-			// Rollback payment creation
-			db.Delete(payment)
+			// Rollback payment creation and give the promo code back
+			db.Transaction(func(tx *gorm.DB) error {
+				if err := s.pricingService.ReleasePromo(tx, payment.ID); err != nil {
+					return err
+				}
+				return tx.Delete(payment).Error
+			})
 			return nil, "", err
 		}
 
-		payment.StripePaymentID = pi.ID
-		payment.StripeClientSecret = pi.ClientSecret
-		clientSecret = pi.ClientSecret
+		payment.StripePaymentID = intent.ID
+		payment.StripeClientSecret = intent.ClientSecret
+		clientSecret = intent.ClientSecret
 
 		if err := db.Save(payment).Error; err != nil {
 			return nil, "", err
 		}
 	} else {
-		// Demo mode - no Stripe configured
+		// Demo mode - no payment provider configured
 		clientSecret = "demo_mode"
 	}
 
@@ -97,38 +150,46 @@ func (s *PaymentService) ConfirmPayment(paymentID uuid.UUID, stripePaymentID str
 		return nil, errors.New("payment already processed")
 	}
 
-	// Verify with Stripe if configured
-	if s.config.StripeSecretKey != "" && stripePaymentID != "" {
-		pi, err := paymentintent.Get(stripePaymentID, nil)
+	// Verify with the payment provider if configured
+	var receiptURL string
+	if s.provider != nil {
+		if stripePaymentID == "" {
+			stripePaymentID = payment.StripePaymentID
+		}
+		if stripePaymentID == "" || (payment.StripePaymentID != "" && payment.StripePaymentID != stripePaymentID) {
+			return nil, errors.New("payment intent does not match this payment")
+		}
+
+		intent, err := s.provider.GetIntent(stripePaymentID)
 		if err != nil {
 			return nil, err
 		}
 
-		if pi.Status != stripe.PaymentIntentStatusSucceeded {
+		if intent.Status != payments.IntentStatusSucceeded {
 			return nil, errors.New("payment not successful")
 		}
 
-		// Get receipt URL from charge
-		if pi.LatestCharge != nil {
-			payment.ReceiptURL = string(pi.LatestCharge.ReceiptURL)
-		}
+		receiptURL = intent.ReceiptURL
 	}
 
-	now := time.Now()
-	payment.Status = models.PaymentStatusCompleted
-	payment.CompletedAt = &now
-
-	if err := db.Save(&payment).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return s.completePayment(tx, &payment, stripePaymentID, receiptURL)
+	}); err != nil {
 		return nil, err
 	}
 
 	return &payment, nil
 }
 
-// DemoConfirmPayment confirms payment in demo mode (no Stripe)
+// DemoConfirmPayment confirms payment in demo mode (no payment provider)
 func (s *PaymentService) DemoConfirmPayment(paymentID uuid.UUID) (*models.Payment, error) {
 	db := database.GetDB()
 
+	// Credits are booked to the ledger as purchased, so never without the provider's say-so when one is configured
+	if s.IsProviderConfigured() {
+		return nil, errors.New("demo confirmation is disabled when a payment provider is configured")
+	}
+
 	var payment models.Payment
 	if err := db.First(&payment, "id = ?", paymentID).Error; err != nil {
 		return nil, errors.New("payment not found")
@@ -138,63 +199,714 @@ func (s *PaymentService) DemoConfirmPayment(paymentID uuid.UUID) (*models.Paymen
 		return nil, errors.New("payment already processed")
 	}
 
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return s.completePayment(tx, &payment, "", "")
+	}); err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// completePayment marks a pending payment completed as part of tx and books its
+// credits to the ledger. It fails if the payment was completed concurrently.
+func (s *PaymentService) completePayment(tx *gorm.DB, payment *models.Payment, stripePaymentID, receiptURL string) error {
 	now := time.Now()
+	if receiptURL == "" {
+		receiptURL = payment.ReceiptURL
+	}
+	if receiptURL == "" {
+		receiptURL = paymentReceiptURL(s.config, payment.ID)
+	}
+	if stripePaymentID == "" {
+		stripePaymentID = payment.StripePaymentID
+	}
+
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, models.PaymentStatusPending).
+		Updates(map[string]interface{}{
+			"status":            models.PaymentStatusCompleted,
+			"completed_at":      now,
+			"expires_at":        s.creditExpiry(now),
+			"receipt_url":       receiptURL,
+			"stripe_payment_id": stripePaymentID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("payment already processed")
+	}
+
 	payment.Status = models.PaymentStatusCompleted
 	payment.CompletedAt = &now
+	payment.ExpiresAt = s.creditExpiry(now)
+	payment.ReceiptURL = receiptURL
+	payment.StripePaymentID = stripePaymentID
+
+	if err := s.pricingService.ConfirmPromo(tx, payment); err != nil {
+		return err
+	}
+
+	return postLedgerEntry(tx, &models.CreditLedgerEntry{
+		InvestorID:  payment.InvestorID,
+		PaymentID:   &payment.ID,
+		EntryType:   models.LedgerEntryPurchase,
+		Credits:     payment.ProjectsTotal,
+		Description: payment.Description,
+	})
+}
+
+// RefundPaymentInput selects a full refund or a pro-rata refund of unused credits
+type RefundPaymentInput struct {
+	Type   string `json:"type" binding:"required,oneof=full pro_rata"`
+	Reason string `json:"reason"`
+}
+
+// RefundPayment refunds a completed credit purchase on an admin's behalf. A full
+// refund returns everything not yet refunded; a pro-rata refund returns the value
+// of the unused credits. Either way the remaining credits are withdrawn.
+//
+// The refund is reserved on the payment before the provider is called, outside
+// any transaction, and finalised afterwards. A refund left reserved by a crash is
+// settled by the provider's charge.refunded webhook, or resumed by retrying.
+func (s *PaymentService) RefundPayment(adminID, paymentID uuid.UUID, input RefundPaymentInput, ipAddress, userAgent string) (*models.Payment, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+
+	var payment models.Payment
+	var amount int64
+	withdrawn, resumed := 0, false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the payment so no credit can be spent while the refund is reserved
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&payment, "id = ?", paymentID).Error; err != nil {
+			return errors.New("payment not found")
+		}
+
+		if payment.Status != models.PaymentStatusCompleted {
+			return fmt.Errorf("cannot refund a %s payment", payment.Status)
+		}
+
+		// Resume a refund whose provider call never finished
+		if payment.RefundPending > 0 {
+			amount, resumed = payment.RefundPending, true
+			return nil
+		}
+
+		switch input.Type {
+		case models.RefundTypeFull:
+			amount = payment.RefundableAmount()
+		case models.RefundTypeProRata:
+			if payment.ProjectsRemaining == 0 {
+				return errors.New("payment has no unused credits to refund")
+			}
+			amount = payment.ProRataRefundAmount()
+		default:
+			return errors.New("invalid refund type")
+		}
 
-	if err := db.Save(&payment).Error; err != nil {
+		if amount <= 0 {
+			return errors.New("nothing left to refund on this payment")
+		}
+
+		withdrawn = payment.ProjectsRemaining
+		if err := tx.Model(&payment).Updates(map[string]interface{}{
+			"refund_pending":     amount,
+			"projects_remaining": 0,
+		}).Error; err != nil {
+			return err
+		}
+
+		return postLedgerEntry(tx, &models.CreditLedgerEntry{
+			InvestorID:  payment.InvestorID,
+			PaymentID:   &payment.ID,
+			EntryType:   models.LedgerEntryRefund,
+			Credits:     -withdrawn,
+			Description: "Unused credits withdrawn by refund",
+			CreatedByID: &admin.ID,
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	refundID, err := s.createRefund(&payment, amount, input.Reason)
+	if err != nil {
+		// A resumed refund stays reserved: an earlier attempt may have gone through
+		if !resumed {
+			if releaseErr := s.releaseRefund(&payment, amount, withdrawn, admin.ID); releaseErr != nil {
+				log.Error().Err(releaseErr).Str("payment_id", payment.ID.String()).Msg("Failed to release refund reservation")
+			}
+		}
+		return nil, err
+	}
+
+	refunded := payment.AmountRefunded + amount
+	updates := map[string]interface{}{
+		// The provider's webhook may already have recorded this refund
+		"amount_refunded":  gorm.Expr("GREATEST(amount_refunded, ?)", refunded),
+		"refund_pending":   0,
+		"stripe_refund_id": refundID,
+		"refund_reason":    input.Reason,
+		"refunded_at":      time.Now(),
+	}
+	if refunded >= payment.Amount {
+		updates["status"] = models.PaymentStatusRefunded
+	}
+	if err := db.Model(&models.Payment{}).Where("id = ?", payment.ID).Updates(updates).Error; err != nil {
+		// The refund went through; the charge.refunded webhook records it
+		log.Error().Err(err).Str("payment_id", payment.ID.String()).Str("refund_id", refundID).
+			Msg("Failed to record refund")
+	}
+
+	db.First(&payment, "id = ?", payment.ID)
+
+	description := fmt.Sprintf("Refunded %s (%s)", models.FormatCurrency(amount, payment.Currency),
+		strings.ReplaceAll(input.Type, "_", "-"))
+	if input.Reason != "" {
+		description += ": " + input.Reason
+	}
+	s.auditService.LogAction(
+		&admin.ID,
+		admin.Email,
+		admin.Role,
+		models.AuditActionPaymentRefunded,
+		"payment",
+		&payment.ID,
+		"",
+		description,
+		map[string]interface{}{
+			"investor_id":     payment.InvestorID,
+			"refund_type":     input.Type,
+			"amount":          amount,
+			"currency":        payment.Currency,
+			"amount_refunded": payment.AmountRefunded,
+			"stripe_refund":   payment.StripeRefundID,
+		},
+		ipAddress,
+		userAgent,
+	)
+
 	return &payment, nil
 }
 
-// HandleStripeWebhook processes Stripe webhook events
-func (s *PaymentService) HandleStripeWebhook(payload []byte, signature string) error {
-	if s.config.StripeWebhookSecret == "" {
-		return errors.New("webhook secret not configured")
+// releaseRefund cancels a refund reservation after the provider declined it and
+// gives back the credits it withdrew
+func (s *PaymentService) releaseRefund(payment *models.Payment, amount int64, withdrawn int, adminID uuid.UUID) error {
+	db := database.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND refund_pending = ?", payment.ID, amount).
+			Updates(map[string]interface{}{
+				"refund_pending":     0,
+				"projects_remaining": gorm.Expr("projects_remaining + ?", withdrawn),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		return postLedgerEntry(tx, &models.CreditLedgerEntry{
+			InvestorID:  payment.InvestorID,
+			PaymentID:   &payment.ID,
+			EntryType:   models.LedgerEntryRefund,
+			Credits:     withdrawn,
+			Description: "Credits restored after a failed refund",
+			CreatedByID: &adminID,
+		})
+	})
+}
+
+// createRefund issues the refund through the payment provider, or records a demo
+// refund when the payment never went through one
+func (s *PaymentService) createRefund(payment *models.Payment, amount int64, reason string) (string, error) {
+	if !s.IsProviderConfigured() || payment.StripePaymentID == "" {
+		return "demo_refund", nil
 	}
 
-	event, err := webhook.ConstructEvent(payload, signature, s.config.StripeWebhookSecret)
+	r, err := s.provider.Refund(payments.RefundParams{
+		IntentID: payment.StripePaymentID,
+		Amount:   amount,
+		Reason:   reason,
+		Metadata: map[string]string{
+			"payment_id": payment.ID.String(),
+			"reason":     reason,
+		},
+		// Guards against a double refund if the admin retries after a timeout
+		IdempotencyKey: fmt.Sprintf("refund-%s-%d", payment.ID, payment.AmountRefunded+amount),
+	})
 	if err != nil {
-		return err
+		return "", fmt.Errorf("%s refund failed: %w", s.provider.Name(), err)
+	}
+	return r.ID, nil
+}
+
+// CreditAdjustmentInput is an admin grant or revocation of view credits
+type CreditAdjustmentInput struct {
+	Credits int    `json:"credits" binding:"required,min=1"`
+	Reason  string `json:"reason" binding:"required"`
+}
+
+// GrantCredits comps view credits to an investor. The grant is a zero-amount
+// payment so it appears in the investor's history and expires like bought credits.
+func (s *PaymentService) GrantCredits(adminID, investorID uuid.UUID, input CreditAdjustmentInput, ipAddress, userAgent string) (*models.Payment, error) {
+	db := database.GetDB()
+
+	admin, investor, err := s.loadAdminAndInvestor(adminID, investorID)
+	if err != nil {
+		return nil, err
 	}
 
-	switch event.Type {
-	case "payment_intent.succeeded":
-		var pi stripe.PaymentIntent
-		if err := event.Data.Raw.UnmarshalJSON(([]byte)(pi.ID)); err != nil {
+	now := time.Now()
+	payment := &models.Payment{
+		InvestorID:        investor.ID,
+		Type:              models.PaymentTypeGrant,
+		GrantedByID:       &admin.ID,
+		Amount:            0,
+		Currency:          s.config.ViewFeeCurrency,
+		Status:            models.PaymentStatusCompleted,
+		ProjectsTotal:     input.Credits,
+		ProjectsRemaining: input.Credits,
+		Description:       "Complimentary credits: " + input.Reason,
+		CompletedAt:       &now,
+		ExpiresAt:         s.creditExpiry(now),
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payment).Error; err != nil {
 			return err
 		}
-		// Payment confirmed via webhook
-		paymentIDStr := pi.Metadata["payment_id"]
-		if paymentIDStr != "" {
-			paymentID, _ := uuid.Parse(paymentIDStr)
-			s.ConfirmPayment(paymentID, pi.ID)
-		}
+		return postLedgerEntry(tx, &models.CreditLedgerEntry{
+			InvestorID:  investor.ID,
+			PaymentID:   &payment.ID,
+			EntryType:   models.LedgerEntryGrant,
+			Credits:     input.Credits,
+			Description: input.Reason,
+			CreatedByID: &admin.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.LogAction(&admin.ID, admin.Email, admin.Role, models.AuditActionCreditsGranted,
+		"payment", &payment.ID, investor.Email,
+		fmt.Sprintf("Granted %d credits to %s: %s", input.Credits, investor.Email, input.Reason),
+		map[string]interface{}{
+			"investor_id": investor.ID,
+			"credits":     input.Credits,
+			"reason":      input.Reason,
+		}, ipAddress, userAgent)
 
-	case "payment_intent.payment_failed":
-		// Handle failed payment
-		var pi stripe.PaymentIntent
-		if err := event.Data.Raw.UnmarshalJSON(([]byte)(pi.ID)); err != nil {
+	return payment, nil
+}
+
+// RevokeCredits removes unused credits from an investor, newest lots first so
+// paid credits are the last to go
+func (s *PaymentService) RevokeCredits(adminID, investorID uuid.UUID, input CreditAdjustmentInput, ipAddress, userAgent string) (int, error) {
+	db := database.GetDB()
+
+	admin, investor, err := s.loadAdminAndInvestor(adminID, investorID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var payments []models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(activeCredits(creditAccount{InvestorID: investor.ID})).
+			Order("type = 'grant' DESC, created_at DESC").
+			Find(&payments).Error; err != nil {
 			return err
 		}
-		paymentIDStr := pi.Metadata["payment_id"]
-		if paymentIDStr != "" {
-			paymentID, _ := uuid.Parse(paymentIDStr)
-			s.FailPayment(paymentID)
+
+		available := 0
+		for _, p := range payments {
+			available += p.ProjectsRemaining
 		}
+		if available < input.Credits {
+			return fmt.Errorf("investor only has %d unused credits", available)
+		}
+
+		remaining := input.Credits
+		for i := range payments {
+			if remaining == 0 {
+				break
+			}
+			payment := &payments[i]
+			take := payment.ProjectsRemaining
+			if take > remaining {
+				take = remaining
+			}
+
+			if err := tx.Model(payment).
+				UpdateColumn("projects_remaining", gorm.Expr("projects_remaining - ?", take)).Error; err != nil {
+				return err
+			}
+			if err := postLedgerEntry(tx, &models.CreditLedgerEntry{
+				InvestorID:  investor.ID,
+				PaymentID:   &payment.ID,
+				EntryType:   models.LedgerEntryRevoke,
+				Credits:     -take,
+				Description: input.Reason,
+				CreatedByID: &admin.ID,
+			}); err != nil {
+				return err
+			}
+			remaining -= take
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
+	s.auditService.LogAction(&admin.ID, admin.Email, admin.Role, models.AuditActionCreditsRevoked,
+		"user", &investor.ID, investor.Email,
+		fmt.Sprintf("Revoked %d credits from %s: %s", input.Credits, investor.Email, input.Reason),
+		map[string]interface{}{
+			"credits": input.Credits,
+			"reason":  input.Reason,
+		}, ipAddress, userAgent)
+
+	return creditSummary(db, investor.ID).Balance, nil
+}
+
+// GrantComplimentaryUnlock opens a project to an investor without spending any
+// of their credits
+func (s *PaymentService) GrantComplimentaryUnlock(adminID, investorID, projectID uuid.UUID, reason, ipAddress, userAgent string) (*models.ProjectView, error) {
+	db := database.GetDB()
+
+	admin, investor, err := s.loadAdminAndInvestor(adminID, investorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := db.First(&project, "id = ? AND status = ?", projectID, models.ProjectStatusApproved).Error; err != nil {
+		return nil, errors.New("project not found or not available")
+	}
+
+	view := &models.ProjectView{
+		InvestorID:      investor.ID,
+		ProjectID:       project.ID,
+		ViewedAt:        time.Now(),
+		IsComplimentary: true,
+		GrantedByID:     &admin.ID,
+		GrantReason:     reason,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(view)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("investor has already unlocked this project")
+	}
+
+	description := fmt.Sprintf("Complimentary access to %s for %s", project.Title, investor.Email)
+	if reason != "" {
+		description += ": " + reason
+	}
+	s.auditService.LogAction(&admin.ID, admin.Email, admin.Role, models.AuditActionProjectComplimentary,
+		"project", &project.ID, project.Title, description,
+		map[string]interface{}{
+			"investor_id": investor.ID,
+			"reason":      reason,
+		}, ipAddress, userAgent)
+
+	return view, nil
+}
+
+func (s *PaymentService) loadAdminAndInvestor(adminID, investorID uuid.UUID) (*models.User, *models.User, error) {
+	db := database.GetDB()
+
+	var admin models.User
+	if err := db.First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, nil, errors.New("admin not found")
+	}
+
+	var investor models.User
+	if err := db.First(&investor, "id = ? AND role = ?", investorID, models.RoleInvestor).Error; err != nil {
+		return nil, nil, errors.New("investor not found")
+	}
+
+	return &admin, &investor, nil
+}
+
+// ErrInvalidWebhookSignature is returned when a webhook payload cannot be verified.
+// Processors should not retry these; any other webhook error is worth retrying.
+var ErrInvalidWebhookSignature = payments.ErrInvalidSignature
+
+// WebhookSignatureHeader names the header carrying the provider's webhook signature
+func (s *PaymentService) WebhookSignatureHeader() string {
+	if s.provider == nil {
+		return ""
+	}
+	return s.provider.SignatureHeader()
+}
+
+// HandleWebhook verifies and processes a payment provider webhook delivery
+func (s *PaymentService) HandleWebhook(payload []byte, signature string) error {
+	if s.provider == nil {
+		return errors.New("payment provider not configured")
+	}
+
+	event, err := s.provider.ParseWebhook(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	return s.ProcessEvent(event)
+}
+
+// ProcessEvent applies a verified provider event. Each event ID is recorded in
+// the same transaction as its effects, so a redelivered event is a no-op.
+func (s *PaymentService) ProcessEvent(event *payments.Event) error {
+	db := database.GetDB()
+
+	var audit func()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		record := &models.StripeWebhookEvent{
+			ID:          event.ID,
+			Type:        event.RawType,
+			ProcessedAt: time.Now(),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Already processed
+			return nil
+		}
+
+		var err error
+		switch event.Type {
+		case payments.EventPaymentSucceeded:
+			audit, err = s.applyPaymentSucceeded(tx, event.Intent)
+		case payments.EventPaymentFailed:
+			audit, err = s.applyPaymentFailed(tx, event.Intent)
+		case payments.EventChargeRefunded:
+			audit, err = s.applyChargeRefunded(tx, event)
+		case payments.EventDisputeCreated:
+			audit, err = s.applyDisputeCreated(tx, event)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if audit != nil {
+		audit()
+	}
 	return nil
 }
 
-// FailPayment marks a payment as failed
+// applyPaymentSucceeded completes the pending payment behind a payment intent
+func (s *PaymentService) applyPaymentSucceeded(tx *gorm.DB, intent *payments.Intent) (func(), error) {
+	if intent == nil || intent.Metadata["type"] != "view_credits" {
+		return nil, nil
+	}
+
+	payment, err := s.findPaymentForIntent(tx, intent.ID, intent.Metadata["payment_id"])
+	if err != nil {
+		// The provider can deliver the event before CreatePaymentIntent has saved
+		// the intent ID; failing makes it retry once the record exists
+		return nil, err
+	}
+
+	if payment.Status != models.PaymentStatusPending {
+		return nil, nil
+	}
+
+	if intent.Amount != payment.Amount || !strings.EqualFold(intent.Currency, payment.Currency) {
+		return nil, fmt.Errorf("payment intent %s amount %d %s does not match payment %s",
+			intent.ID, intent.Amount, intent.Currency, payment.ID)
+	}
+
+	if err := s.completePayment(tx, payment, intent.ID, intent.ReceiptURL); err != nil {
+		return nil, err
+	}
+
+	return s.paymentAudit(payment, models.AuditActionPaymentCompleted,
+		fmt.Sprintf("Payment completed via %s webhook", s.provider.Name())), nil
+}
+
+// applyPaymentFailed marks the pending payment behind a payment intent as failed
+func (s *PaymentService) applyPaymentFailed(tx *gorm.DB, intent *payments.Intent) (func(), error) {
+	if intent == nil || intent.Metadata["type"] != "view_credits" {
+		return nil, nil
+	}
+
+	payment, err := s.findPaymentForIntent(tx, intent.ID, intent.Metadata["payment_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != models.PaymentStatusPending {
+		return nil, nil
+	}
+
+	if err := tx.Model(payment).Update("status", models.PaymentStatusFailed).Error; err != nil {
+		return nil, err
+	}
+	if err := s.pricingService.ReleasePromo(tx, payment.ID); err != nil {
+		return nil, err
+	}
+
+	description := "Payment failed"
+	if intent.FailureMessage != "" {
+		description += ": " + intent.FailureMessage
+	}
+	return s.paymentAudit(payment, models.AuditActionPaymentFailed, description), nil
+}
+
+// applyChargeRefunded records a refund made at the provider. A full refund
+// withdraws any unused credits; a partial refund only updates the refunded amount.
+func (s *PaymentService) applyChargeRefunded(tx *gorm.DB, event *payments.Event) (func(), error) {
+	if event.IntentID == "" {
+		return nil, nil
+	}
+
+	payment, err := s.findPaymentForIntent(tx, event.IntentID, "")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not a charge for view credits
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if event.AmountRefunded <= payment.AmountRefunded {
+		return nil, nil
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"amount_refunded": event.AmountRefunded,
+		"refunded_at":     now,
+	}
+	// Settles an admin refund whose result was never recorded
+	if payment.RefundPending > 0 && event.AmountRefunded >= payment.AmountRefunded+payment.RefundPending {
+		updates["refund_pending"] = 0
+	}
+	withdrawn := 0
+	if event.FullyRefunded {
+		updates["status"] = models.PaymentStatusRefunded
+		updates["projects_remaining"] = 0
+		if payment.Status == models.PaymentStatusCompleted {
+			withdrawn = payment.ProjectsRemaining
+		}
+	}
+
+	if err := tx.Model(payment).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if err := postLedgerEntry(tx, &models.CreditLedgerEntry{
+		InvestorID:  payment.InvestorID,
+		PaymentID:   &payment.ID,
+		EntryType:   models.LedgerEntryRefund,
+		Credits:     -withdrawn,
+		Description: "Unused credits withdrawn by provider refund",
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.paymentAudit(payment, models.AuditActionPaymentRefunded,
+		fmt.Sprintf("Refunded %s via %s", models.FormatCurrency(event.AmountRefunded, payment.Currency), s.provider.Name())), nil
+}
+
+// applyDisputeCreated freezes the credits of a disputed payment
+func (s *PaymentService) applyDisputeCreated(tx *gorm.DB, event *payments.Event) (func(), error) {
+	if event.IntentID == "" {
+		return nil, nil
+	}
+
+	payment, err := s.findPaymentForIntent(tx, event.IntentID, "")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != models.PaymentStatusCompleted {
+		return nil, nil
+	}
+
+	now := time.Now()
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"status":      models.PaymentStatusDisputed,
+		"disputed_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := postLedgerEntry(tx, &models.CreditLedgerEntry{
+		InvestorID:  payment.InvestorID,
+		PaymentID:   &payment.ID,
+		EntryType:   models.LedgerEntryDispute,
+		Credits:     -payment.ProjectsRemaining,
+		Description: "Unused credits frozen by chargeback",
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.paymentAudit(payment, models.AuditActionPaymentDisputed,
+		fmt.Sprintf("Charge disputed (%s); credits frozen", event.DisputeReason)), nil
+}
+
+// findPaymentForIntent locks the payment for a provider payment intent, preferring
+// the payment ID carried in the intent's metadata
+func (s *PaymentService) findPaymentForIntent(tx *gorm.DB, intentID, paymentIDStr string) (*models.Payment, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Investor")
+
+	var payment models.Payment
+	var err error
+	if paymentID, parseErr := uuid.Parse(paymentIDStr); parseErr == nil {
+		err = query.First(&payment, "id = ?", paymentID).Error
+	} else {
+		err = query.First(&payment, "stripe_payment_id = ?", intentID).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.StripePaymentID != "" && payment.StripePaymentID != intentID {
+		return nil, fmt.Errorf("payment %s belongs to a different payment intent", payment.ID)
+	}
+
+	return &payment, nil
+}
+
+// paymentAudit defers an audit entry until the webhook transaction has committed
+func (s *PaymentService) paymentAudit(payment *models.Payment, action models.AuditAction, description string) func() {
+	return func() {
+		if payment.Investor == nil {
+			return
+		}
+		s.auditService.LogPaymentAction(payment.InvestorID, payment.Investor.Email, action, payment, description)
+	}
+}
+
+// FailPayment marks a pending payment as failed and releases any promo code it held
 func (s *PaymentService) FailPayment(paymentID uuid.UUID) error {
 	db := database.GetDB()
-	return db.Model(&models.Payment{}).
-		Where("id = ?", paymentID).
-		Update("status", models.PaymentStatusFailed).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND status = ?", paymentID, models.PaymentStatusPending).
+			Update("status", models.PaymentStatusFailed)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return s.pricingService.ReleasePromo(tx, paymentID)
+	})
 }
 
 // GetActivePayment gets an investor's active payment with remaining views
@@ -202,8 +914,7 @@ func (s *PaymentService) GetActivePayment(investorID uuid.UUID) (*models.Payment
 	db := database.GetDB()
 
 	var payment models.Payment
-	err := db.Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-		investorID, models.PaymentStatusCompleted).
+	err := db.Scopes(activeCredits(creditAccountFor(db, investorID))).
 		Order("created_at DESC").
 		First(&payment).Error
 
@@ -218,14 +929,9 @@ func (s *PaymentService) GetActivePayment(investorID uuid.UUID) (*models.Payment
 func (s *PaymentService) GetPaymentStatus(investorID uuid.UUID) *models.PaymentStatusResponse {
 	db := database.GetDB()
 	
-	// Get all completed payments with remaining credits
-	var payments []models.Payment
-	db.Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-		investorID, models.PaymentStatusCompleted).
-		Order("created_at ASC"). // Use oldest credits first
-		Find(&payments)
-
-	if len(payments) == 0 {
+	// Balances come from the credit ledger
+	summary := creditSummary(db, investorID)
+	if summary.Balance <= 0 {
 		return &models.PaymentStatusResponse{
 			HasActivePayment:  false,
 			ProjectsRemaining: 0,
@@ -233,99 +939,110 @@ func (s *PaymentService) GetPaymentStatus(investorID uuid.UUID) *models.PaymentS
 		}
 	}
 
-	// Calculate total remaining credits across all payments
-	totalRemaining := 0
-	totalPurchased := 0
-	for _, p := range payments {
-		totalRemaining += p.ProjectsRemaining
-		totalPurchased += p.ProjectsTotal
-	}
-
 	// Return the oldest payment with credits for the response
-	resp := payments[0].ToResponse()
+	var payment models.Payment
+	db.Scopes(activeCredits(creditAccountFor(db, investorID))).
+		Order("created_at ASC"). // Use oldest credits first
+		First(&payment)
+
+	resp := payment.ToResponse()
 	return &models.PaymentStatusResponse{
 		HasActivePayment:  true,
 		Payment:           &resp,
-		ProjectsRemaining: totalRemaining,
-		ProjectsTotal:     totalPurchased,
+		ProjectsRemaining: summary.Balance,
+		ProjectsTotal:     summary.Credited,
 		Message:           "",
 	}
 }
 
-// UseViewCredit decrements the view credit and records the view
+// ErrNoViewCredits is returned when an investor has no spendable credits left
+var ErrNoViewCredits = errors.New("no active credits available - please purchase more credits to view projects")
+
+// UseViewCredit records the investor's view of a project, spending one credit the
+// first time. The view and the decrement commit together, and the payment row is
+// locked so concurrent unlocks can neither double-spend nor duplicate a view.
 func (s *PaymentService) UseViewCredit(investorID, projectID uuid.UUID) error {
 	db := database.GetDB()
 
-	// Check if already viewed
+	// Check if already viewed, by the investor or anyone in their organisation
 	var existingView models.ProjectView
-	if err := db.Where("investor_id = ? AND project_id = ?", investorID, projectID).
+	if err := creditAccountFor(db, investorID).views(db).Where("project_id = ?", projectID).
 		First(&existingView).Error; err == nil {
 		// Already viewed, no credit needed
 		return nil
 	}
 
-	// Get oldest active payment with credits (FIFO - use oldest credits first)
+	return db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.consumeViewCredit(tx, investorID, projectID)
+		return err
+	})
+}
+
+// consumeViewCredit spends one credit on a project view inside tx, from the
+// investor's own credits or their organisation's pool, and counts the view on the
+// project. It reports false, without spending or counting, if the investor or
+// their organisation had already viewed the project.
+func (s *PaymentService) consumeViewCredit(tx *gorm.DB, investorID, projectID uuid.UUID) (bool, error) {
+	account := creditAccountFor(tx, investorID)
+
+	// Lock the oldest payment with credits (FIFO - use oldest credits first). A
+	// concurrent unlock blocks here until this transaction finishes.
 	var payment models.Payment
-	err := db.Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-		investorID, models.PaymentStatusCompleted).
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Scopes(activeCredits(account)).
 		Order("created_at ASC").
-		First(&payment).Error
-	
-	if err != nil {
-		return errors.New("no active credits available - please purchase more credits to view projects")
-	}
-
-	if !payment.CanViewMore() {
-		return errors.New("no remaining project views on this payment")
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrNoViewCredits
+		}
+		return false, err
 	}
 
-	// Create view record
+	// The unique (investor, project) and (organisation, project) indexes turn a
+	// racing duplicate into a no-op
 	view := &models.ProjectView{
-		InvestorID: investorID,
-		ProjectID:  projectID,
-		PaymentID:  payment.ID,
-		ViewedAt:   time.Now(),
+		InvestorID:     investorID,
+		OrganizationID: account.OrganizationID,
+		ProjectID:      projectID,
+		PaymentID:      &payment.ID,
+		ViewedAt:       time.Now(),
 	}
-
-	if err := db.Create(view).Error; err != nil {
-		return err
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(view)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
 	}
 
-	// Decrement credit
-	payment.ProjectsRemaining--
-	if err := db.Save(&payment).Error; err != nil {
-		return err
+	if err := tx.Model(&payment).
+		UpdateColumn("projects_remaining", gorm.Expr("projects_remaining - 1")).Error; err != nil {
+		return false, err
 	}
 
-	// Check if investor has hit their limit (all credits used)
-	var totalRemaining int64
-	db.Model(&models.Payment{}).
-		Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-			investorID, models.PaymentStatusCompleted).
-		Select("COALESCE(SUM(projects_remaining), 0)").
-		Scan(&totalRemaining)
+	if err := tx.Model(&models.Project{}).Where("id = ?", projectID).
+		UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error; err != nil {
+		return false, err
+	}
 
-	// Log if they've used all credits
-	if totalRemaining == 0 {
-		// This could trigger a notification or audit log
-		// The audit service would be called here in production
+	if err := postLedgerEntry(tx, &models.CreditLedgerEntry{
+		InvestorID: investorID,
+		PaymentID:  &payment.ID,
+		ProjectID:  &projectID,
+		EntryType:  models.LedgerEntryUnlock,
+		Credits:    -1,
+	}); err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
 // GetTotalRemainingCredits returns total credits across all active payments
 func (s *PaymentService) GetTotalRemainingCredits(investorID uuid.UUID) int {
 	db := database.GetDB()
 	
-	var total int64
-	db.Model(&models.Payment{}).
-		Where("investor_id = ? AND status = ? AND projects_remaining > 0",
-			investorID, models.PaymentStatusCompleted).
-		Select("COALESCE(SUM(projects_remaining), 0)").
-		Scan(&total)
-	
-	return int(total)
+	return creditSummary(db, investorID).Balance
 }
 
 // CanViewMoreProjects checks if investor has any credits remaining
@@ -333,12 +1050,13 @@ func (s *PaymentService) CanViewMoreProjects(investorID uuid.UUID) bool {
 	return s.GetTotalRemainingCredits(investorID) > 0
 }
 
-// HasViewedProject checks if an investor has already viewed a project
+// HasViewedProject checks if an investor, or anyone in their organisation, has
+// already viewed a project
 func (s *PaymentService) HasViewedProject(investorID, projectID uuid.UUID) bool {
 	db := database.GetDB()
 
 	var view models.ProjectView
-	err := db.Where("investor_id = ? AND project_id = ?", investorID, projectID).First(&view).Error
+	err := creditAccountFor(db, investorID).views(db).Where("project_id = ?", projectID).First(&view).Error
 	return err == nil
 }
 
@@ -354,12 +1072,12 @@ func (s *PaymentService) GetPaymentHistory(investorID uuid.UUID) ([]models.Payme
 	return payments, err
 }
 
-// GetViewedProjects retrieves projects an investor has viewed
+// GetViewedProjects retrieves projects an investor or their organisation has viewed
 func (s *PaymentService) GetViewedProjects(investorID uuid.UUID) ([]models.ProjectView, error) {
 	db := database.GetDB()
 
 	var views []models.ProjectView
-	err := db.Where("investor_id = ?", investorID).
+	err := creditAccountFor(db, investorID).views(db).
 		Preload("Project").
 		Preload("Project.Category").
 		Order("viewed_at DESC").
@@ -368,12 +1086,149 @@ func (s *PaymentService) GetViewedProjects(investorID uuid.UUID) ([]models.Proje
 	return views, err
 }
 
-// IsStripeConfigured returns whether Stripe is set up
-func (s *PaymentService) IsStripeConfigured() bool {
-	return s.config.StripeSecretKey != ""
+// IsProviderConfigured returns whether a payment provider is set up; without one
+// the service runs in demo mode
+func (s *PaymentService) IsProviderConfigured() bool {
+	return s.provider != nil
+}
+
+// ProviderName returns the configured payment provider, or "demo"
+func (s *PaymentService) ProviderName() string {
+	if s.provider == nil {
+		return "demo"
+	}
+	return s.provider.Name()
 }
 
 // GetStripePublishableKey returns the publishable key for frontend
 func (s *PaymentService) GetStripePublishableKey() string {
 	return s.config.StripePublishableKey
 }
+
+// activeCredits scopes a payment query to an account's spendable credits:
+// completed, not exhausted and not past their expiry date
+func activeCredits(account creditAccount) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return account.payments(db).
+			Where("status = ? AND projects_remaining > 0 AND (expires_at IS NULL OR expires_at > ?)",
+				models.PaymentStatusCompleted, time.Now())
+	}
+}
+
+// creditExpiry returns when credits completed at the given time lapse, or nil if
+// credits never expire
+func (s *PaymentService) creditExpiry(completedAt time.Time) *time.Time {
+	if s.config.CreditLifetimeMonths <= 0 {
+		return nil
+	}
+	expiresAt := completedAt.AddDate(0, s.config.CreditLifetimeMonths, 0)
+	return &expiresAt
+}
+
+// CreditExpiryHook is notified when an investor's unused credits are about to expire
+type CreditExpiryHook func(investor *models.User, payment *models.Payment)
+
+// OnCreditsExpiring registers a hook for pre-expiry reminders
+func (s *PaymentService) OnCreditsExpiring(hook CreditExpiryHook) {
+	s.expiryHooks = append(s.expiryHooks, hook)
+}
+
+// SendCreditExpiryReminders notifies the registered hooks, once per payment, about
+// unused credits expiring within the reminder window
+func (s *PaymentService) SendCreditExpiryReminders() (int64, error) {
+	if len(s.expiryHooks) == 0 || s.config.CreditExpiryReminderDays <= 0 {
+		return 0, nil
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+	windowEnd := now.AddDate(0, 0, s.config.CreditExpiryReminderDays)
+
+	var payments []models.Payment
+	if err := db.Preload("Investor").
+		Where("status = ? AND projects_remaining > 0 AND expires_at > ? AND expires_at <= ? AND reminder_sent_at IS NULL",
+			models.PaymentStatusCompleted, now, windowEnd).
+		Find(&payments).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i := range payments {
+		payment := &payments[i]
+
+		// Claim the reminder first so concurrent sweeps never send it twice
+		result := db.Model(&models.Payment{}).
+			Where("id = ? AND reminder_sent_at IS NULL", payment.ID).
+			Update("reminder_sent_at", now)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if result.RowsAffected == 0 || payment.Investor == nil {
+			continue
+		}
+		count++
+
+		for _, hook := range s.expiryHooks {
+			hook(payment.Investor, payment)
+		}
+	}
+
+	return count, nil
+}
+
+// ExpireCredits withdraws unused credits from payments past their expiry date.
+// Payments completed before expiry was introduced are given a full lifetime by
+// the startup migration, never by the sweep, so none lapse without notice.
+func (s *PaymentService) ExpireCredits() (int64, error) {
+	db := database.GetDB()
+	now := time.Now()
+
+	var payments []models.Payment
+	if err := db.Where("status = ? AND projects_remaining > 0 AND expires_at <= ?",
+		models.PaymentStatusCompleted, now).
+		Find(&payments).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i := range payments {
+		payment := &payments[i]
+
+		expired := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.Payment{}).
+				Where("id = ? AND status = ? AND projects_remaining = ?",
+					payment.ID, models.PaymentStatusCompleted, payment.ProjectsRemaining).
+				Update("projects_remaining", 0)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			expired = true
+
+			return postLedgerEntry(tx, &models.CreditLedgerEntry{
+				InvestorID:  payment.InvestorID,
+				PaymentID:   &payment.ID,
+				EntryType:   models.LedgerEntryExpiry,
+				Credits:     -payment.ProjectsRemaining,
+				Description: "Unused credits expired",
+			})
+		})
+		if err != nil {
+			return count, err
+		}
+		if !expired {
+			continue
+		}
+		count++
+
+		s.auditService.LogAction(nil, "", "", models.AuditActionCreditsExpired, "payment", &payment.ID, "",
+			fmt.Sprintf("%d unused credits expired", payment.ProjectsRemaining),
+			map[string]interface{}{
+				"investor_id":     payment.InvestorID,
+				"credits_expired": payment.ProjectsRemaining,
+				"expires_at":      payment.ExpiresAt,
+			}, "", "")
+	}
+
+	return count, nil
+}
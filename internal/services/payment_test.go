@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/payments"
+)
+
+func createTestPackage(t *testing.T, credits int, amount int64) *models.CreditPackage {
+	t.Helper()
+
+	pkg := &models.CreditPackage{Name: "Standard", Credits: credits, Amount: amount, Currency: "usd", IsActive: true}
+	if err := database.GetDB().Create(pkg).Error; err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestPurchaseAndUnlockThroughFakeProvider(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	provider := payments.NewFakeProvider("")
+	paymentSvc := NewPaymentService(cfg, audit, NewPricingService(cfg, audit), provider)
+	projectSvc := NewProjectService(cfg, paymentSvc, NewNDAService(cfg, audit, NewEmailService(cfg, nil)))
+
+	investor := createTestUser(t, models.RoleInvestor)
+	pkg := createTestPackage(t, 2, 50000)
+	project := createTestProject(t)
+	signTestNDA(t, investor.ID, project.ID)
+
+	payment, clientSecret, err := paymentSvc.CreatePaymentIntent(investor.ID, CheckoutInput{PackageID: &pkg.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.StripePaymentID == "" || clientSecret == "" {
+		t.Fatalf("no intent created: %+v", payment)
+	}
+
+	// Unpaid credits cannot be spent
+	if err := projectSvc.UnlockProject(investor.ID, project.ID); err != ErrNoViewCredits {
+		t.Fatalf("got %v before payment, want ErrNoViewCredits", err)
+	}
+
+	if err := provider.Succeed(payment.StripePaymentID); err != nil {
+		t.Fatal(err)
+	}
+	event, err := provider.IntentEvent(payment.StripePaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := paymentSvc.ProcessEvent(event); err != nil {
+		t.Fatal(err)
+	}
+
+	got := reloadPayment(t, payment.ID)
+	if got.Status != models.PaymentStatusCompleted || got.ReceiptURL == "" {
+		t.Fatalf("payment not completed: %s", got.Status)
+	}
+	if credits := paymentSvc.GetTotalRemainingCredits(investor.ID); credits != 2 {
+		t.Fatalf("got %d credits after purchase, want 2", credits)
+	}
+
+	if err := projectSvc.UnlockProject(investor.ID, project.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Opening an unlocked project again is free
+	if err := projectSvc.UnlockProject(investor.ID, project.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if credits := paymentSvc.GetTotalRemainingCredits(investor.ID); credits != 1 {
+		t.Fatalf("got %d credits after unlocking, want 1", credits)
+	}
+	if got := reloadPayment(t, payment.ID); got.ProjectsRemaining != 1 {
+		t.Fatalf("got %d credits left on the payment, want 1", got.ProjectsRemaining)
+	}
+
+	var views []models.ProjectView
+	db.Where("investor_id = ?", investor.ID).Find(&views)
+	if len(views) != 1 {
+		t.Fatalf("got %d project views, want 1", len(views))
+	}
+	view := views[0]
+	if view.ProjectID != project.ID || view.PaymentID == nil || *view.PaymentID != payment.ID || view.IsComplimentary {
+		t.Fatalf("unexpected project view %+v", view)
+	}
+	if !paymentSvc.HasViewedProject(investor.ID, project.ID) {
+		t.Fatal("project not reported as viewed")
+	}
+}
+
+func TestRefundThroughFakeProvider(t *testing.T) {
+	requireDB(t)
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	provider := payments.NewFakeProvider("")
+	provider.AutoSucceed = true
+	svc := NewPaymentService(cfg, audit, NewPricingService(cfg, audit), provider)
+
+	admin := createTestUser(t, models.RoleAdmin)
+	investor := createTestUser(t, models.RoleInvestor)
+	pkg := createTestPackage(t, 4, 40000)
+
+	payment, _, err := svc.CreatePaymentIntent(investor.ID, CheckoutInput{PackageID: &pkg.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ConfirmPayment(payment.ID, payment.StripePaymentID); err != nil {
+		t.Fatal(err)
+	}
+
+	refunded, err := svc.RefundPayment(admin.ID, payment.ID, RefundPaymentInput{Type: models.RefundTypeProRata}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.AmountRefunded != 40000 || refunded.RefundPending != 0 || refunded.ProjectsRemaining != 0 {
+		t.Fatalf("got refunded %d, pending %d, credits %d", refunded.AmountRefunded, refunded.RefundPending, refunded.ProjectsRemaining)
+	}
+	if refunds := provider.Refunds(); len(refunds) != 1 || refunds[0].Amount != 40000 {
+		t.Fatalf("provider refunds %+v", refunds)
+	}
+	if credits := svc.GetTotalRemainingCredits(investor.ID); credits != 0 {
+		t.Fatalf("got %d credits after refund, want 0", credits)
+	}
+
+	// The provider's own webhook for the same refund changes nothing
+	event, err := provider.RefundEvent(payment.StripePaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ProcessEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloadPayment(t, payment.ID); got.AmountRefunded != 40000 || got.Status != models.PaymentStatusRefunded {
+		t.Fatalf("got refunded %d with status %s", got.AmountRefunded, got.Status)
+	}
+}
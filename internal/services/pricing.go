@@ -0,0 +1,565 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PricingService manages credit packages and promo codes, and prices checkouts
+type PricingService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewPricingService(cfg *config.Config, auditSvc *AuditService) *PricingService {
+	return &PricingService{config: cfg, auditService: auditSvc}
+}
+
+// ========================================
+// CREDIT PACKAGES
+// ========================================
+
+// CreditPackageInput is the admin-editable part of a credit package
+type CreditPackageInput struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"`
+	Credits      int    `json:"credits" binding:"required,min=1"`
+	Amount       *int64 `json:"amount" binding:"required,min=0"` // 0 makes the package free
+	Currency     string `json:"currency"`
+	DisplayOrder int    `json:"display_order"`
+	IsActive     *bool  `json:"is_active"`
+
+	// Prices in other currencies, in minor units, keyed by currency code. They
+	// replace the package's existing price table.
+	Prices map[string]int64 `json:"prices"`
+}
+
+// ListPackages returns credit packages in display order
+func (s *PricingService) ListPackages(activeOnly bool) ([]models.CreditPackage, error) {
+	db := database.GetDB()
+
+	query := db.Model(&models.CreditPackage{})
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var packages []models.CreditPackage
+	err := query.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("currency ASC")
+	}).Order("display_order ASC, amount ASC").Find(&packages).Error
+	return packages, err
+}
+
+// CreatePackage adds a credit package
+func (s *PricingService) CreatePackage(adminID uuid.UUID, input CreditPackageInput, ipAddress, userAgent string) (*models.CreditPackage, error) {
+	db := database.GetDB()
+
+	admin, err := s.loadAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &models.CreditPackage{IsActive: true}
+	if err := s.applyPackageInput(pkg, input); err != nil {
+		return nil, err
+	}
+
+	// Prices are written by replacePackagePrices
+	prices := pkg.Prices
+	pkg.Prices = nil
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(pkg).Error; err != nil {
+			return err
+		}
+		return s.replacePackagePrices(tx, pkg, prices)
+	}); err != nil {
+		return nil, err
+	}
+
+	s.logPricingAction(admin, models.AuditActionCreditPackageCreated, "credit_package", &pkg.ID, pkg.Name,
+		fmt.Sprintf("Created package %s: %d credits for %s", pkg.Name, pkg.Credits, pkg.FormattedAmount()),
+		ipAddress, userAgent)
+
+	return pkg, nil
+}
+
+// UpdatePackage edits a credit package. Payments already made keep the price they were charged.
+func (s *PricingService) UpdatePackage(adminID, packageID uuid.UUID, input CreditPackageInput, ipAddress, userAgent string) (*models.CreditPackage, error) {
+	db := database.GetDB()
+
+	admin, err := s.loadAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg models.CreditPackage
+	if err := db.First(&pkg, "id = ?", packageID).Error; err != nil {
+		return nil, errors.New("package not found")
+	}
+
+	if err := s.applyPackageInput(&pkg, input); err != nil {
+		return nil, err
+	}
+
+	prices := pkg.Prices
+	pkg.Prices = nil
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&pkg).Error; err != nil {
+			return err
+		}
+		return s.replacePackagePrices(tx, &pkg, prices)
+	}); err != nil {
+		return nil, err
+	}
+
+	s.logPricingAction(admin, models.AuditActionCreditPackageUpdated, "credit_package", &pkg.ID, pkg.Name,
+		fmt.Sprintf("Updated package %s: %d credits for %s", pkg.Name, pkg.Credits, pkg.FormattedAmount()),
+		ipAddress, userAgent)
+
+	return &pkg, nil
+}
+
+// DeletePackage soft deletes a credit package
+func (s *PricingService) DeletePackage(adminID, packageID uuid.UUID, ipAddress, userAgent string) error {
+	db := database.GetDB()
+
+	admin, err := s.loadAdmin(adminID)
+	if err != nil {
+		return err
+	}
+
+	var pkg models.CreditPackage
+	if err := db.First(&pkg, "id = ?", packageID).Error; err != nil {
+		return errors.New("package not found")
+	}
+
+	if err := db.Delete(&pkg).Error; err != nil {
+		return err
+	}
+
+	s.logPricingAction(admin, models.AuditActionCreditPackageDeleted, "credit_package", &pkg.ID, pkg.Name,
+		"Deleted package "+pkg.Name, ipAddress, userAgent)
+
+	return nil
+}
+
+func (s *PricingService) applyPackageInput(pkg *models.CreditPackage, input CreditPackageInput) error {
+	currency := strings.ToLower(input.Currency)
+	if currency == "" {
+		currency = s.config.ViewFeeCurrency
+	}
+	if !models.IsSupportedCurrency(currency) {
+		return fmt.Errorf("unsupported currency %q", input.Currency)
+	}
+
+	var prices []models.CreditPackagePrice
+	for code, amount := range input.Prices {
+		code = strings.ToLower(code)
+		if !models.IsSupportedCurrency(code) {
+			return fmt.Errorf("unsupported currency %q", code)
+		}
+		if code == currency {
+			return fmt.Errorf("%s is the package's base currency", strings.ToUpper(code))
+		}
+		if amount < 0 {
+			return errors.New("prices cannot be negative")
+		}
+		prices = append(prices, models.CreditPackagePrice{Currency: code, Amount: amount})
+	}
+
+	pkg.Name = input.Name
+	pkg.Description = input.Description
+	pkg.Credits = input.Credits
+	pkg.Amount = *input.Amount
+	pkg.Currency = currency
+	pkg.Prices = prices
+	pkg.DisplayOrder = input.DisplayOrder
+	if input.IsActive != nil {
+		pkg.IsActive = *input.IsActive
+	}
+	return nil
+}
+
+// replacePackagePrices swaps a package's price table for prices
+func (s *PricingService) replacePackagePrices(tx *gorm.DB, pkg *models.CreditPackage, prices []models.CreditPackagePrice) error {
+	if err := tx.Where("package_id = ?", pkg.ID).Delete(&models.CreditPackagePrice{}).Error; err != nil {
+		return err
+	}
+	for i := range prices {
+		prices[i].PackageID = pkg.ID
+		if err := tx.Create(&prices[i]).Error; err != nil {
+			return err
+		}
+	}
+	pkg.Prices = prices
+	return nil
+}
+
+// ========================================
+// PROMO CODES
+// ========================================
+
+// PromoCodeInput is the admin-editable part of a promo code
+type PromoCodeInput struct {
+	Code           string     `json:"code" binding:"required"`
+	Description    string     `json:"description"`
+	DiscountType   string     `json:"discount_type" binding:"required,oneof=percent fixed"`
+	DiscountValue  int64      `json:"discount_value" binding:"required,min=1"`
+	Currency       string     `json:"currency"`
+	PackageID      *uuid.UUID `json:"package_id"`
+	MaxRedemptions int        `json:"max_redemptions" binding:"min=0"`
+	MaxPerUser     *int       `json:"max_per_user"`
+	StartsAt       *time.Time `json:"starts_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	IsActive       *bool      `json:"is_active"`
+}
+
+// ListPromoCodes returns all promo codes, newest first
+func (s *PricingService) ListPromoCodes() ([]models.PromoCode, error) {
+	db := database.GetDB()
+
+	var codes []models.PromoCode
+	err := db.Order("created_at DESC").Find(&codes).Error
+	return codes, err
+}
+
+// CreatePromoCode adds a promo code
+func (s *PricingService) CreatePromoCode(adminID uuid.UUID, input PromoCodeInput, ipAddress, userAgent string) (*models.PromoCode, error) {
+	db := database.GetDB()
+
+	admin, err := s.loadAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	code := models.NormalizePromoCode(input.Code)
+	var existing models.PromoCode
+	if err := db.Unscoped().Where("code = ?", code).First(&existing).Error; err == nil {
+		return nil, errors.New("promo code already exists")
+	}
+
+	promo := &models.PromoCode{
+		Code:        code,
+		MaxPerUser:  1,
+		IsActive:    true,
+		CreatedByID: admin.ID,
+	}
+	if err := s.applyPromoInput(promo, input); err != nil {
+		return nil, err
+	}
+
+	if err := db.Create(promo).Error; err != nil {
+		return nil, err
+	}
+
+	s.logPricingAction(admin, models.AuditActionPromoCodeCreated, "promo_code", &promo.ID, promo.Code,
+		fmt.Sprintf("Created promo code %s (%s)", promo.Code, promo.Label()), ipAddress, userAgent)
+
+	return promo, nil
+}
+
+// UpdatePromoCode edits a promo code's discount, limits or validity. The code itself cannot change.
+func (s *PricingService) UpdatePromoCode(adminID, promoID uuid.UUID, input PromoCodeInput, ipAddress, userAgent string) (*models.PromoCode, error) {
+	db := database.GetDB()
+
+	admin, err := s.loadAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	var promo models.PromoCode
+	if err := db.First(&promo, "id = ?", promoID).Error; err != nil {
+		return nil, errors.New("promo code not found")
+	}
+
+	if models.NormalizePromoCode(input.Code) != promo.Code {
+		return nil, errors.New("promo code cannot be renamed")
+	}
+
+	if err := s.applyPromoInput(&promo, input); err != nil {
+		return nil, err
+	}
+
+	// Select("*") so cleared limits and dates are written back
+	if err := db.Model(&promo).Select("*").Omit("redemptions", "created_by_id", "created_at").
+		Updates(&promo).Error; err != nil {
+		return nil, err
+	}
+
+	s.logPricingAction(admin, models.AuditActionPromoCodeUpdated, "promo_code", &promo.ID, promo.Code,
+		fmt.Sprintf("Updated promo code %s (%s)", promo.Code, promo.Label()), ipAddress, userAgent)
+
+	return &promo, nil
+}
+
+// GetPromoRedemptions lists the payments a promo code was applied to
+func (s *PricingService) GetPromoRedemptions(promoID uuid.UUID) ([]models.PromoRedemption, error) {
+	db := database.GetDB()
+
+	var redemptions []models.PromoRedemption
+	err := db.Preload("Investor").
+		Preload("Payment").
+		Where("promo_code_id = ?", promoID).
+		Order("created_at DESC").
+		Find(&redemptions).Error
+	return redemptions, err
+}
+
+func (s *PricingService) applyPromoInput(promo *models.PromoCode, input PromoCodeInput) error {
+	if input.DiscountType == models.DiscountTypePercent && input.DiscountValue > 100 {
+		return errors.New("percentage discount cannot exceed 100")
+	}
+	if input.DiscountType == models.DiscountTypeFixed && input.Currency == "" {
+		return errors.New("currency is required for fixed discounts")
+	}
+	if input.Currency != "" && !models.IsSupportedCurrency(input.Currency) {
+		return fmt.Errorf("unsupported currency %q", input.Currency)
+	}
+	if input.StartsAt != nil && input.ExpiresAt != nil && !input.ExpiresAt.After(*input.StartsAt) {
+		return errors.New("expiry must be after the start date")
+	}
+	if input.PackageID != nil {
+		var pkg models.CreditPackage
+		if err := database.GetDB().First(&pkg, "id = ?", *input.PackageID).Error; err != nil {
+			return errors.New("package not found")
+		}
+	}
+
+	promo.Description = input.Description
+	promo.DiscountType = input.DiscountType
+	promo.DiscountValue = input.DiscountValue
+	promo.Currency = strings.ToLower(input.Currency)
+	promo.PackageID = input.PackageID
+	promo.MaxRedemptions = input.MaxRedemptions
+	if input.MaxPerUser != nil {
+		promo.MaxPerUser = *input.MaxPerUser
+	}
+	promo.StartsAt = input.StartsAt
+	promo.ExpiresAt = input.ExpiresAt
+	if input.IsActive != nil {
+		promo.IsActive = *input.IsActive
+	}
+	return nil
+}
+
+// ========================================
+// CHECKOUT
+// ========================================
+
+// Quote prices a package for an investor. With no package the first active
+// package is used, with no currency the package's base currency, and an empty
+// promo code means no discount.
+func (s *PricingService) Quote(investorID uuid.UUID, packageID *uuid.UUID, currency, code string) (*models.CheckoutQuote, error) {
+	db := database.GetDB()
+
+	var pkg models.CreditPackage
+	query := db.Preload("Prices").Where("is_active = ?", true)
+	if packageID != nil {
+		query = query.Where("id = ?", *packageID)
+	}
+	if err := query.Order("display_order ASC, amount ASC").First(&pkg).Error; err != nil {
+		return nil, errors.New("credit package not available")
+	}
+
+	if currency == "" {
+		currency = pkg.Currency
+	}
+	amount, ok := pkg.PriceIn(currency)
+	if !ok {
+		return nil, fmt.Errorf("%s is not sold in %s", pkg.Name, strings.ToUpper(currency))
+	}
+
+	var promo *models.PromoCode
+	if code = models.NormalizePromoCode(code); code != "" {
+		var p models.PromoCode
+		if err := db.Where("code = ?", code).First(&p).Error; err != nil {
+			return nil, errors.New("invalid promo code")
+		}
+		if err := s.checkPromo(db, &p, investorID, &pkg, amount, currency); err != nil {
+			return nil, err
+		}
+		promo = &p
+	}
+
+	return models.NewCheckoutQuote(&pkg, currency, promo), nil
+}
+
+// promoHoldWindow is how long an unpaid checkout holds its promo code
+// redemption before ReleaseAbandonedPromos gives it back
+const promoHoldWindow = 24 * time.Hour
+
+// RedeemPromo records the quote's promo code against a payment. It runs in the
+// payment's transaction and re-checks the limits under a row lock, so concurrent
+// checkouts cannot exceed them. The redemption is held until the payment fails or
+// is abandoned.
+func (s *PricingService) RedeemPromo(tx *gorm.DB, quote *models.CheckoutQuote, payment *models.Payment) error {
+	if quote.Promo() == nil {
+		return nil
+	}
+
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&promo, "id = ?", quote.Promo().ID).Error; err != nil {
+		return errors.New("invalid promo code")
+	}
+
+	if err := s.checkPromo(tx, &promo, payment.InvestorID, quote.Package, quote.Subtotal, quote.Currency); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&promo).UpdateColumn("redemptions", gorm.Expr("redemptions + 1")).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.PromoRedemption{
+		PromoCodeID:    promo.ID,
+		InvestorID:     payment.InvestorID,
+		PaymentID:      payment.ID,
+		DiscountAmount: quote.Discount,
+		Currency:       quote.Currency,
+	}).Error
+}
+
+// ReleasePromo gives back the redemption held by a payment that did not go through
+func (s *PricingService) ReleasePromo(tx *gorm.DB, paymentID uuid.UUID) error {
+	var redemption models.PromoRedemption
+	if err := tx.Where("payment_id = ?", paymentID).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.PromoCode{}).
+		Where("id = ? AND redemptions > 0", redemption.PromoCodeID).
+		UpdateColumn("redemptions", gorm.Expr("redemptions - 1")).Error
+}
+
+// ConfirmPromo records the redemption of a completed payment whose hold was
+// released while it was unpaid. The customer paid the discounted price, so the
+// limits are not checked again.
+func (s *PricingService) ConfirmPromo(tx *gorm.DB, payment *models.Payment) error {
+	if payment.PromoCodeID == nil {
+		return nil
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PromoRedemption{
+		PromoCodeID:    *payment.PromoCodeID,
+		InvestorID:     payment.InvestorID,
+		PaymentID:      payment.ID,
+		DiscountAmount: payment.DiscountAmount,
+		Currency:       payment.Currency,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		// Still held
+		return result.Error
+	}
+
+	return tx.Model(&models.PromoCode{}).Where("id = ?", *payment.PromoCodeID).
+		UpdateColumn("redemptions", gorm.Expr("redemptions + 1")).Error
+}
+
+// ReleaseAbandonedPromos gives back the redemptions held by checkouts left unpaid
+// for longer than promoHoldWindow. The payments stay pending; one that is paid
+// later records its redemption again through ConfirmPromo.
+func (s *PricingService) ReleaseAbandonedPromos() (int64, error) {
+	db := database.GetDB()
+
+	var paymentIDs []uuid.UUID
+	if err := db.Model(&models.PromoRedemption{}).
+		Joins("JOIN payments ON payments.id = promo_redemptions.payment_id").
+		Where("payments.status = ? AND payments.created_at <= ?", models.PaymentStatusPending, time.Now().Add(-promoHoldWindow)).
+		Pluck("promo_redemptions.payment_id", &paymentIDs).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, paymentID := range paymentIDs {
+		released := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Lock the payment so a concurrent completion keeps its redemption
+			var payment models.Payment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&payment, "id = ? AND status = ?", paymentID, models.PaymentStatusPending).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			released = true
+			return s.ReleasePromo(tx, paymentID)
+		})
+		if err != nil {
+			return count, err
+		}
+		if released {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// checkPromo validates a promo code for an investor buying a package at a price
+func (s *PricingService) checkPromo(db *gorm.DB, promo *models.PromoCode, investorID uuid.UUID, pkg *models.CreditPackage, amount int64, currency string) error {
+	if !promo.IsRedeemableAt(time.Now()) {
+		return errors.New("promo code is no longer valid")
+	}
+
+	if promo.PackageID != nil && *promo.PackageID != pkg.ID {
+		return errors.New("promo code does not apply to this package")
+	}
+
+	if promo.DiscountFor(amount, currency) == 0 {
+		return errors.New("promo code does not apply to this package")
+	}
+
+	if promo.MaxPerUser > 0 {
+		var used int64
+		db.Model(&models.PromoRedemption{}).
+			Where("promo_code_id = ? AND investor_id = ?", promo.ID, investorID).
+			Count(&used)
+		if used >= int64(promo.MaxPerUser) {
+			return errors.New("you have already used this promo code")
+		}
+	}
+
+	return nil
+}
+
+func (s *PricingService) loadAdmin(adminID uuid.UUID) (*models.User, error) {
+	var admin models.User
+	if err := database.GetDB().First(&admin, "id = ? AND role = ?", adminID, models.RoleAdmin).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	return &admin, nil
+}
+
+func (s *PricingService) logPricingAction(admin *models.User, action models.AuditAction, entityType string, entityID *uuid.UUID, entityName, description, ipAddress, userAgent string) {
+	s.auditService.LogAction(
+		&admin.ID,
+		admin.Email,
+		admin.Role,
+		action,
+		entityType,
+		entityID,
+		entityName,
+		description,
+		nil,
+		ipAddress,
+		userAgent,
+	)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+func TestCreatePromoCodeKeepsExplicitZeroValues(t *testing.T) {
+	db := requireDB(t)
+	svc := NewPricingService(testConfig(), NewAuditService(testConfig()))
+	admin := createTestUser(t, models.RoleAdmin)
+
+	inactive, unlimited := false, 0
+	promo, err := svc.CreatePromoCode(admin.ID, PromoCodeInput{
+		Code:          "later",
+		DiscountType:  models.DiscountTypePercent,
+		DiscountValue: 10,
+		MaxPerUser:    &unlimited,
+		IsActive:      &inactive,
+	}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored models.PromoCode
+	db.First(&stored, "id = ?", promo.ID)
+	if stored.IsActive || stored.MaxPerUser != 0 {
+		t.Fatalf("stored is_active=%v max_per_user=%d, want false and 0", stored.IsActive, stored.MaxPerUser)
+	}
+
+	promo, err = svc.CreatePromoCode(admin.ID, PromoCodeInput{
+		Code:          "default",
+		DiscountType:  models.DiscountTypePercent,
+		DiscountValue: 10,
+	}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.First(&stored, "id = ?", promo.ID)
+	if !stored.IsActive || stored.MaxPerUser != 1 {
+		t.Fatalf("stored is_active=%v max_per_user=%d, want true and 1", stored.IsActive, stored.MaxPerUser)
+	}
+}
+
+func TestCreatePackageKeepsExplicitZeroValues(t *testing.T) {
+	db := requireDB(t)
+	svc := NewPricingService(testConfig(), NewAuditService(testConfig()))
+	admin := createTestUser(t, models.RoleAdmin)
+
+	free, inactive := int64(0), false
+	pkg, err := svc.CreatePackage(admin.ID, CreditPackageInput{
+		Name:     "Trial",
+		Credits:  1,
+		Amount:   &free,
+		IsActive: &inactive,
+	}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored models.CreditPackage
+	db.First(&stored, "id = ?", pkg.ID)
+	if stored.IsActive || stored.Amount != 0 {
+		t.Fatalf("stored is_active=%v amount=%d, want false and 0", stored.IsActive, stored.Amount)
+	}
+}
+
+func TestAbandonedCheckoutReleasesPromo(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	pricing := NewPricingService(cfg, audit)
+	payments := NewPaymentService(cfg, audit, pricing, nil)
+	admin := createTestUser(t, models.RoleAdmin)
+	investor := createTestUser(t, models.RoleInvestor)
+
+	amount, active := int64(50000), true
+	pkg, err := pricing.CreatePackage(admin.ID, CreditPackageInput{Name: "Standard", Credits: 4, Amount: &amount, IsActive: &active}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	promo, err := pricing.CreatePromoCode(admin.ID, PromoCodeInput{
+		Code:           "ONCE",
+		DiscountType:   models.DiscountTypePercent,
+		DiscountValue:  20,
+		MaxRedemptions: 1,
+	}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, _, err := payments.CreatePaymentIntent(investor.ID, CheckoutInput{PackageID: &pkg.ID, PromoCode: "once"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh checkout keeps its hold
+	if released, err := pricing.ReleaseAbandonedPromos(); err != nil || released != 0 {
+		t.Fatalf("released %d (%v), want 0", released, err)
+	}
+
+	db.Model(&models.Payment{}).Where("id = ?", payment.ID).Update("created_at", time.Now().Add(-promoHoldWindow-time.Minute))
+	if released, err := pricing.ReleaseAbandonedPromos(); err != nil || released != 1 {
+		t.Fatalf("released %d (%v), want 1", released, err)
+	}
+
+	var stored models.PromoCode
+	db.First(&stored, "id = ?", promo.ID)
+	if stored.Redemptions != 0 {
+		t.Fatalf("got %d redemptions after release, want 0", stored.Redemptions)
+	}
+
+	// Paying the abandoned checkout after all records the redemption again
+	if _, err := payments.DemoConfirmPayment(payment.ID); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&stored, "id = ?", promo.ID)
+	var redemptions int64
+	db.Model(&models.PromoRedemption{}).Where("payment_id = ?", payment.ID).Count(&redemptions)
+	if stored.Redemptions != 1 || redemptions != 1 {
+		t.Fatalf("got %d redemptions and %d records, want 1 and 1", stored.Redemptions, redemptions)
+	}
+}
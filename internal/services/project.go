@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -38,6 +39,8 @@ type CreateProjectRequest struct {
 	MaxInvestment int64     `json:"max_investment"`
 	EquityOffered float64   `json:"equity_offered"`
 	ValuationCap  int64     `json:"valuation_cap"`
+	FundingGoal   int64     `json:"funding_goal"`
+	Currency      string    `json:"currency"` // Defaults to the platform currency
 	ContactEmail  string    `json:"contact_email" binding:"required,email"`
 	ContactPhone  string    `json:"contact_phone"`
 	WebsiteURL    string    `json:"website_url"`
@@ -81,12 +84,16 @@ func (s *ProjectService) CreateProject(developerID uuid.UUID, req *CreateProject
 		MaxInvestment: req.MaxInvestment,
 		EquityOffered: req.EquityOffered,
 		ValuationCap:  req.ValuationCap,
+		FundingGoal:   req.FundingGoal,
 		ContactEmail:  req.ContactEmail,
 		ContactPhone:  req.ContactPhone,
 		WebsiteURL:    req.WebsiteURL,
 		POCURL:        req.POCURL,
 		Status:        models.ProjectStatusDraft,
 	}
+	if err := setProjectCurrency(s.config, project, req.Currency); err != nil {
+		return nil, err
+	}
 
 	if err := db.Create(project).Error; err != nil {
 		return nil, err
@@ -98,6 +105,28 @@ func (s *ProjectService) CreateProject(developerID uuid.UUID, req *CreateProject
 	return project, nil
 }
 
+// setProjectCurrency sets the currency a project raises in. An empty currency
+// keeps the current one, or the platform currency for a new project. Amounts
+// already offered or raised are in the old currency, so it is fixed from the
+// first offer on.
+func setProjectCurrency(cfg *config.Config, project *models.Project, currency string) error {
+	currency = strings.ToLower(strings.TrimSpace(currency))
+	if currency == "" {
+		if project.Currency != "" {
+			return nil
+		}
+		currency = cfg.ViewFeeCurrency
+	}
+	if !models.IsSupportedCurrency(currency) {
+		return fmt.Errorf("unsupported currency %q", currency)
+	}
+	if project.Currency != "" && currency != project.Currency && (project.OfferCount > 0 || project.AmountRaised > 0) {
+		return errors.New("currency cannot be changed once the project has offers")
+	}
+	project.Currency = currency
+	return nil
+}
+
 // UpdateProject updates an existing project
 func (s *ProjectService) UpdateProject(projectID, developerID uuid.UUID, req *CreateProjectRequest) (*models.Project, error) {
 	db := database.GetDB()
@@ -123,10 +152,14 @@ func (s *ProjectService) UpdateProject(projectID, developerID uuid.UUID, req *Cr
 	project.MaxInvestment = req.MaxInvestment
 	project.EquityOffered = req.EquityOffered
 	project.ValuationCap = req.ValuationCap
+	project.FundingGoal = req.FundingGoal
 	project.ContactEmail = req.ContactEmail
 	project.ContactPhone = req.ContactPhone
 	project.WebsiteURL = req.WebsiteURL
 	project.POCURL = req.POCURL
+	if err := setProjectCurrency(s.config, &project, req.Currency); err != nil {
+		return nil, err
+	}
 
 	if err := db.Save(&project).Error; err != nil {
 		return nil, err
@@ -311,15 +344,8 @@ func (s *ProjectService) UnlockProject(investorID, projectID uuid.UUID) error {
 		}
 	}
 
-	// Use view credit
-	if err := s.paymentService.UseViewCredit(investorID, projectID); err != nil {
-		return err
-	}
-
-	// Increment view count
-	db.Model(&project).Update("view_count", project.ViewCount+1)
-
-	return nil
+	// Use view credit; the project's view count goes up with the first view only
+	return s.paymentService.UseViewCredit(investorID, projectID)
 }
 
 // AddTeamMember adds a team member to a project
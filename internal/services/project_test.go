@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+func newTestProjectService() *ProjectService {
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	payments := NewPaymentService(cfg, audit, NewPricingService(cfg, audit), nil)
+	return NewProjectService(cfg, payments, NewNDAService(cfg, audit, NewEmailService(cfg, nil)))
+}
+
+func TestConcurrentUnlocksSpendOneCredit(t *testing.T) {
+	db := requireDB(t)
+	svc := newTestProjectService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createCompletedTestPayment(t, investor.ID, 1)
+
+	const n = 8
+	var projects []*models.Project
+	for i := 0; i < n; i++ {
+		project := createTestProject(t)
+		signTestNDA(t, investor.ID, project.ID)
+		projects = append(projects, project)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := range projects {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = svc.UnlockProject(investor.ID, projects[i].ID)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrNoViewCredits):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d unlocks succeeded with one credit, want 1", succeeded)
+	}
+
+	var views, unlocks, counted int64
+	db.Model(&models.ProjectView{}).Where("investor_id = ?", investor.ID).Count(&views)
+	db.Model(&models.CreditLedgerEntry{}).Where("payment_id = ? AND entry_type = ?", payment.ID, models.LedgerEntryUnlock).Count(&unlocks)
+	db.Model(&models.Project{}).Select("COALESCE(SUM(view_count), 0)").Scan(&counted)
+	if views != 1 || unlocks != 1 || counted != 1 {
+		t.Fatalf("got %d views, %d unlock entries and view count %d, want 1 each", views, unlocks, counted)
+	}
+	if got := reloadPayment(t, payment.ID); got.ProjectsRemaining != 0 {
+		t.Fatalf("got %d credits left, want 0", got.ProjectsRemaining)
+	}
+}
+
+func TestRepeatedUnlocksCountOneView(t *testing.T) {
+	db := requireDB(t)
+	svc := newTestProjectService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createCompletedTestPayment(t, investor.ID, 3)
+	project := createTestProject(t)
+	signTestNDA(t, investor.ID, project.ID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.UnlockProject(investor.ID, project.ID); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var stored models.Project
+	db.First(&stored, "id = ?", project.ID)
+	if stored.ViewCount != 1 {
+		t.Fatalf("got view count %d, want 1", stored.ViewCount)
+	}
+	if got := reloadPayment(t, payment.ID); got.ProjectsRemaining != 2 {
+		t.Fatalf("got %d credits left, want 2", got.ProjectsRemaining)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SweepFunc is a periodic maintenance job returning the number of records it touched
+type SweepFunc func() (int64, error)
+
+type scheduledSweep struct {
+	name string
+	run  SweepFunc
+}
+
+// Scheduler runs registered sweeps (expiries, overdue checks) on a fixed interval
+type Scheduler struct {
+	interval time.Duration
+	sweeps   []scheduledSweep
+}
+
+func NewScheduler(interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	return &Scheduler{interval: interval}
+}
+
+// Register adds a sweep to run on every tick
+func (s *Scheduler) Register(name string, run SweepFunc) {
+	s.sweeps = append(s.sweeps, scheduledSweep{name: name, run: run})
+}
+
+// Start runs all sweeps once immediately and then on every interval until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.RunOnce()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunOnce()
+			}
+		}
+	}()
+}
+
+// RunOnce runs every registered sweep a single time
+func (s *Scheduler) RunOnce() {
+	for _, sweep := range s.sweeps {
+		count, err := sweep.run()
+		if err != nil {
+			log.Error().Err(err).Str("sweep", sweep.name).Msg("Scheduled sweep failed")
+			continue
+		}
+		if count > 0 {
+			log.Info().Str("sweep", sweep.name).Int64("count", count).Msg("Scheduled sweep completed")
+		}
+	}
+}
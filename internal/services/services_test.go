@@ -0,0 +1,197 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+)
+
+// Tests that touch the database run against the Postgres named by
+// TEST_DATABASE_URL and are skipped without it. Every table is truncated before
+// each of those tests, so never point it at a database you care about.
+func TestMain(m *testing.M) {
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		cfg := testConfig()
+		cfg.DatabaseURL = url
+
+		db, err := database.Connect(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := db.AutoMigrate(models.All()...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	os.Exit(m.Run())
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Environment:          "test",
+		BaseURL:              "https://app.test",
+		JWTSecret:            "test-secret-that-is-long-enough-for-hs256",
+		AccessTokenMinutes:   15,
+		RefreshTokenDays:     30,
+		TOTPIssuer:           "AngelVault",
+		WebAuthnRPID:         "app.test",
+		WebAuthnRPName:       "AngelVault",
+		WebAuthnOrigins:      []string{"https://app.test"},
+		ViewFeeAmount:        50000,
+		ViewFeeCurrency:      "usd",
+		MaxProjectViews:      10,
+		CreditLifetimeMonths: 12,
+		ReportingCurrency:    "usd",
+		CommissionRate:       0.02,
+		NDAValidityYears:     2,
+		FromEmail:            "noreply@app.test",
+		FromName:             "AngelVault",
+	}
+}
+
+// requireDB skips the test without a test database and otherwise empties it
+func requireDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := database.GetDB()
+	if db == nil {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	var tables []string
+	for _, model := range models.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, stmt.Schema.Table)
+	}
+	if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " CASCADE").Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, role models.UserRole) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		Email:         strings.ToLower(string(role)) + "-" + uuid.NewString()[:8] + "@example.com",
+		FirstName:     "Test",
+		LastName:      "User",
+		Role:          role,
+		EmailVerified: true,
+		IsActive:      true,
+	}
+	if err := database.GetDB().Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestPayment creates a pending purchase of credits for an investor
+func createTestPayment(t *testing.T, investorID uuid.UUID, credits int) *models.Payment {
+	t.Helper()
+
+	payment := &models.Payment{
+		InvestorID:        investorID,
+		Type:              models.PaymentTypePurchase,
+		Amount:            50000,
+		Currency:          "usd",
+		Status:            models.PaymentStatusPending,
+		ProjectsTotal:     credits,
+		ProjectsRemaining: credits,
+	}
+	if err := database.GetDB().Create(payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+// createTestProject creates an approved project owned by a new developer
+func createTestProject(t *testing.T) *models.Project {
+	t.Helper()
+
+	developer := createTestUser(t, models.RoleDeveloper)
+	category := &models.Category{Name: "Test " + uuid.NewString()[:8], Slug: uuid.NewString()}
+	if err := database.GetDB().Create(category).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	project := &models.Project{
+		DeveloperID:   developer.ID,
+		CategoryID:    category.ID,
+		Title:         "Test project",
+		MinInvestment: 1000000,
+		ContactEmail:  developer.Email,
+		Status:        models.ProjectStatusApproved,
+		ApprovedAt:    &now,
+	}
+	if err := database.GetDB().Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// createCompletedTestPayment gives an investor paid-up credits
+func createCompletedTestPayment(t *testing.T, investorID uuid.UUID, credits int) *models.Payment {
+	t.Helper()
+
+	payment := createTestPayment(t, investorID, credits)
+	cfg := testConfig()
+	svc := NewPaymentService(cfg, NewAuditService(cfg), NewPricingService(cfg, NewAuditService(cfg)), nil)
+	completed, err := svc.DemoConfirmPayment(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return completed
+}
+
+// signTestNDA gives an investor a valid master NDA and waives the project's addendum
+func signTestNDA(t *testing.T, investorID, projectID uuid.UUID) {
+	t.Helper()
+
+	db := database.GetDB()
+	now := time.Now()
+	nda := &models.NDA{
+		InvestorID:    investorID,
+		SignedName:    "Test User",
+		SignatureData: "data:image/png;base64,",
+		IPAddress:     "127.0.0.1",
+		DocumentHash:  "hash",
+		SignedAt:      now,
+		ExpiresAt:     now.AddDate(2, 0, 0),
+	}
+	if err := db.Create(nda).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	config := &models.ProjectNDAConfig{ProjectID: projectID}
+	if err := db.Create(config).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(config).Update("require_addendum", false).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func reloadPayment(t *testing.T, id uuid.UUID) *models.Payment {
+	t.Helper()
+
+	var payment models.Payment
+	if err := database.GetDB().First(&payment, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &payment
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Revoked and expired sessions are kept this long for support enquiries
+	sessionRetention = 30 * 24 * time.Hour
+	// The frontend exchanges a login code as soon as the redirect lands
+	loginCodeTTL = time.Minute
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrInvalidLoginCode is returned for unknown, used or expired login codes
+	ErrInvalidLoginCode = errors.New("login code is invalid or has expired; please sign in again")
+)
+
+// hashToken is how bearer secrets such as refresh tokens and recovery codes are
+// stored; the secret itself is only ever held by the client
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession signs the user in on a new device, returning an access token and
+// the session's first refresh token
+func (s *AuthService) startSession(user *models.User, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	refreshToken := generateToken()
+	now := time.Now()
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		IPAddress:        ipAddress,
+		UserAgent:        userAgent,
+		ExpiresAt:        now.AddDate(0, 0, s.config.RefreshTokenDays),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+		User:         user.ToResponse(),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working; presenting it again revokes the
+// session, since only a stolen copy would still be using it.
+func (s *AuthService) Refresh(refreshToken, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	hash := hashToken(refreshToken)
+	newToken := generateToken()
+
+	var session models.Session
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", hash).
+			First(&session).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !session.IsActive() {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, "id = ?", session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return errors.New("account is disabled")
+		}
+		if user.IsAdmin() && !user.TOTPEnabled {
+			return errors.New("two-factor authentication is required; please sign in again")
+		}
+
+		now := time.Now()
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(newToken),
+			"previous_token_hash": hash,
+			"ip_address":          ipAddress,
+			"user_agent":          userAgent,
+			"expires_at":          now.AddDate(0, 0, s.config.RefreshTokenDays),
+			"last_used_at":        now,
+		}).Error
+	})
+	if errors.Is(err, ErrInvalidRefreshToken) && session.ID == uuid.Nil {
+		// A rotated-out token: revoke the session it belonged to
+		result := db.Model(&models.Session{}).
+			Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedTokenReuse})
+		if result.RowsAffected > 0 {
+			return nil, errors.New("refresh token was already used; please sign in again")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.GenerateToken(&user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: newToken,
+		ExpiresAt:    expiresAt,
+		User:         user.ToResponse(),
+	}, nil
+}
+
+// ExchangeLoginCode redeems a one-time login code from an OAuth redirect. It
+// signs the user in, or returns a challenge when a second factor is needed.
+func (s *AuthService) ExchangeLoginCode(code, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	var loginCode models.LoginCode
+	if err := db.Where("code_hash = ?", hashToken(code)).First(&loginCode).Error; err != nil {
+		return nil, ErrInvalidLoginCode
+	}
+	result := db.Where("id = ?", loginCode.ID).Delete(&models.LoginCode{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(loginCode.ExpiresAt) {
+		return nil, ErrInvalidLoginCode
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", loginCode.UserID).Error; err != nil {
+		return nil, ErrInvalidLoginCode
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is disabled")
+	}
+
+	return s.beginSession(&user, ipAddress, userAgent)
+}
+
+// PurgeLoginCodes deletes login codes that were never exchanged
+func (s *AuthService) PurgeLoginCodes() (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.LoginCode{})
+	return result.RowsAffected, result.Error
+}
+
+// issueLoginCode creates a one-time login code for the user
+func issueLoginCode(db *gorm.DB, userID uuid.UUID) (string, error) {
+	code := generateToken()
+	loginCode := &models.LoginCode{
+		CodeHash:  hashToken(code),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(loginCodeTTL),
+	}
+	if err := db.Create(loginCode).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Logout revokes a session, invalidating its access and refresh tokens
+func (s *AuthService) Logout(userID, sessionID uuid.UUID) error {
+	db := database.GetDB()
+
+	return db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedLogout}).Error
+}
+
+// PurgeSessions deletes sessions that expired or were revoked more than the
+// retention period ago
+func (s *AuthService) PurgeSessions() (int64, error) {
+	db := database.GetDB()
+
+	cutoff := time.Now().Add(-sessionRetention)
+	result := db.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// revokeUserSessions signs a user out everywhere, except the session in keep if
+// given, and returns how many sessions were revoked
+func revokeUserSessions(db *gorm.DB, userID uuid.UUID, reason string, keep *uuid.UUID) (int64, error) {
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keep != nil {
+		query = query.Where("id <> ?", *keep)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
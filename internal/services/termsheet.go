@@ -0,0 +1,525 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/config"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"gorm.io/gorm"
+)
+
+type TermSheetService struct {
+	config       *config.Config
+	auditService *AuditService
+}
+
+func NewTermSheetService(cfg *config.Config, auditSvc *AuditService) *TermSheetService {
+	return &TermSheetService{
+		config:       cfg,
+		auditService: auditSvc,
+	}
+}
+
+// GenerateTermSheetInput for creating a term sheet from an accepted offer
+type GenerateTermSheetInput struct {
+	OfferID  uuid.UUID `json:"offer_id" binding:"required"`
+	SAFEType string    `json:"safe_type"` // post_money (default), pre_money, mfn
+}
+
+// SignTermSheetRequest represents the request to sign a term sheet.
+// DocumentHash must match the hash of the SAFE the signer was shown.
+type SignTermSheetRequest struct {
+	SignedName    string `json:"signed_name" binding:"required"`
+	SignatureData string `json:"signature_data" binding:"required"`
+	DocumentHash  string `json:"document_hash" binding:"required"`
+}
+
+// GenerateTermSheet creates a term sheet from the accepted revision of an offer.
+// Either party to the offer may generate it; the investor signs first, then the founder.
+func (s *TermSheetService) GenerateTermSheet(userID uuid.UUID, input *GenerateTermSheetInput, ipAddress, userAgent string) (*models.TermSheet, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	var offer models.InvestmentOffer
+	if err := db.Preload("Project").First(&offer, "id = ?", input.OfferID).Error; err != nil {
+		return nil, errors.New("offer not found")
+	}
+
+	isInvestor := offer.InvestorID == userID
+	isDeveloper := offer.Project != nil && offer.Project.DeveloperID == userID
+	if !isInvestor && !isDeveloper {
+		return nil, errors.New("not authorized to generate a term sheet for this offer")
+	}
+
+	if offer.Status != models.OfferStatusAccepted {
+		return nil, errors.New("term sheets can only be generated for accepted offers")
+	}
+
+	// An offer has one term sheet; one that lapsed or was cancelled is replaced
+	var replaced *models.TermSheet
+	var existing models.TermSheet
+	if err := db.Where("offer_id = ?", offer.ID).First(&existing).Error; err == nil {
+		if existing.IsExpired() {
+			s.markExpired(&existing)
+		}
+		if !isReplaceableTermSheet(&existing) {
+			return nil, errors.New("a term sheet already exists for this offer")
+		}
+		replaced = &existing
+	}
+
+	safeType := input.SAFEType
+	if safeType == "" {
+		safeType = models.SAFETypePostMoney
+	}
+	if !models.IsValidSAFEType(safeType) {
+		return nil, errors.New("invalid SAFE type")
+	}
+
+	// Terms come from the accepted revision so later counters can never leak in
+	var revision *models.OfferRevision
+	if offer.AcceptedRevisionID != nil {
+		var accepted models.OfferRevision
+		if err := db.First(&accepted, "id = ?", *offer.AcceptedRevisionID).Error; err != nil {
+			return nil, errors.New("accepted offer revision not found")
+		}
+		revision = &accepted
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, s.config.TermSheetValidityDays)
+
+	termSheet := offer.NewTermSheet(revision)
+	termSheet.ID = uuid.New()
+	termSheet.SAFEType = safeType
+	termSheet.CommissionRate = s.config.CommissionRate
+	termSheet.Status = models.TermSheetStatusPendingInvestor
+	termSheet.ExpiresAt = &expiresAt
+
+	// Render the SAFE once; both signatures bind to these exact bytes
+	content, err := s.renderSAFEDocument(termSheet, &offer, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	termSheet.DocumentContent = content
+	termSheet.DocumentHash = models.HashDocument(content)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if replaced != nil {
+			result := tx.Where("id = ? AND status IN ?", replaced.ID, replaceableTermSheetStatuses).
+				Delete(&models.TermSheet{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("a term sheet already exists for this offer")
+			}
+		}
+		return tx.Create(termSheet).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Term sheet generated from accepted offer"
+	if replaced != nil {
+		description = fmt.Sprintf("Term sheet regenerated from accepted offer, replacing %s term sheet %s", replaced.Status, replaced.ID)
+	}
+	s.auditService.LogTermSheetAction(&user, models.AuditActionTermSheetGenerated, termSheet, offer.Project,
+		description, ipAddress, userAgent)
+
+	termSheet.Offer = &offer
+	return termSheet, nil
+}
+
+// replaceableTermSheetStatuses are those of term sheets that will never be
+// signed, which a new term sheet for the same offer may replace
+var replaceableTermSheetStatuses = []models.TermSheetStatus{
+	models.TermSheetStatusExpired,
+	models.TermSheetStatusCancelled,
+}
+
+func isReplaceableTermSheet(termSheet *models.TermSheet) bool {
+	for _, status := range replaceableTermSheetStatuses {
+		if termSheet.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// SignAsInvestor records the investor's signature; the investor always signs first
+func (s *TermSheetService) SignAsInvestor(investorID, termSheetID uuid.UUID, req *SignTermSheetRequest, ipAddress, userAgent string) (*models.TermSheet, error) {
+	db := database.GetDB()
+
+	termSheet, err := s.loadTermSheet(termSheetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if termSheet.Offer.InvestorID != investorID {
+		return nil, errors.New("not authorized to sign this term sheet")
+	}
+
+	if err := s.checkNotExpired(termSheet); err != nil {
+		return nil, err
+	}
+
+	if !termSheet.NeedsInvestorSignature() || termSheet.Status != models.TermSheetStatusPendingInvestor {
+		return nil, errors.New("term sheet is not awaiting the investor's signature")
+	}
+
+	if err := s.checkDocumentHash(termSheet, req.DocumentHash); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := db.Model(&models.TermSheet{}).
+		Where("id = ? AND status = ? AND investor_signed_at IS NULL", termSheet.ID, models.TermSheetStatusPendingInvestor).
+		Updates(map[string]interface{}{
+			"investor_signed_name": req.SignedName,
+			"investor_signature":   req.SignatureData,
+			"investor_signed_at":   now,
+			"investor_ip":          ipAddress,
+			"investor_user_agent":  userAgent,
+			"status":               models.TermSheetStatusPendingFounder,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("term sheet is not awaiting the investor's signature")
+	}
+
+	termSheet.InvestorSignedName = req.SignedName
+	termSheet.InvestorSignature = req.SignatureData
+	termSheet.InvestorSignedAt = &now
+	termSheet.InvestorIP = ipAddress
+	termSheet.InvestorUserAgent = userAgent
+	termSheet.Status = models.TermSheetStatusPendingFounder
+
+	if termSheet.Offer.Investor != nil {
+		s.auditService.LogTermSheetAction(termSheet.Offer.Investor, models.AuditActionTermSheetInvestorSigned, termSheet,
+			termSheet.Offer.Project, "Investor signed term sheet", ipAddress, userAgent)
+	}
+
+	return termSheet, nil
+}
+
+// SignAsFounder records the founder's countersignature once the investor has signed
+func (s *TermSheetService) SignAsFounder(developerID, termSheetID uuid.UUID, req *SignTermSheetRequest, ipAddress, userAgent string) (*models.TermSheet, error) {
+	db := database.GetDB()
+
+	var developer models.User
+	if err := db.First(&developer, "id = ?", developerID).Error; err != nil {
+		return nil, errors.New("developer not found")
+	}
+
+	termSheet, err := s.loadTermSheet(termSheetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if termSheet.Offer.Project == nil || termSheet.Offer.Project.DeveloperID != developerID {
+		return nil, errors.New("not authorized to sign this term sheet")
+	}
+
+	if err := s.checkNotExpired(termSheet); err != nil {
+		return nil, err
+	}
+
+	if termSheet.NeedsInvestorSignature() {
+		return nil, errors.New("the investor must sign the term sheet first")
+	}
+	if !termSheet.NeedsFounderSignature() || termSheet.Status != models.TermSheetStatusPendingFounder {
+		return nil, errors.New("term sheet is not awaiting the founder's signature")
+	}
+
+	if err := s.checkDocumentHash(termSheet, req.DocumentHash); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := db.Model(&models.TermSheet{}).
+		Where("id = ? AND status = ? AND founder_signed_at IS NULL", termSheet.ID, models.TermSheetStatusPendingFounder).
+		Updates(map[string]interface{}{
+			"founder_signed_name": req.SignedName,
+			"founder_signature":   req.SignatureData,
+			"founder_signed_at":   now,
+			"founder_ip":          ipAddress,
+			"founder_user_agent":  userAgent,
+			"status":              models.TermSheetStatusFullySigned,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("term sheet is not awaiting the founder's signature")
+	}
+
+	termSheet.FounderSignedName = req.SignedName
+	termSheet.FounderSignature = req.SignatureData
+	termSheet.FounderSignedAt = &now
+	termSheet.FounderIP = ipAddress
+	termSheet.FounderUserAgent = userAgent
+	termSheet.Status = models.TermSheetStatusFullySigned
+
+	s.auditService.LogTermSheetAction(&developer, models.AuditActionTermSheetFounderSigned, termSheet,
+		termSheet.Offer.Project, "Founder countersigned term sheet", ipAddress, userAgent)
+
+	return termSheet, nil
+}
+
+// GetTermSheet returns a term sheet visible to either party of the underlying offer
+func (s *TermSheetService) GetTermSheet(userID, termSheetID uuid.UUID) (*models.TermSheet, error) {
+	termSheet, err := s.loadTermSheet(termSheetID)
+	if err != nil {
+		return nil, err
+	}
+
+	isInvestor := termSheet.Offer.InvestorID == userID
+	isDeveloper := termSheet.Offer.Project != nil && termSheet.Offer.Project.DeveloperID == userID
+	if !isInvestor && !isDeveloper {
+		return nil, errors.New("term sheet not found")
+	}
+
+	return termSheet, nil
+}
+
+// GetInvestorTermSheets returns all term sheets for an investor's offers
+func (s *TermSheetService) GetInvestorTermSheets(investorID uuid.UUID) ([]models.TermSheet, error) {
+	db := database.GetDB()
+
+	var termSheets []models.TermSheet
+	err := db.Joins("JOIN investment_offers ON investment_offers.id = term_sheets.offer_id").
+		Where("investment_offers.investor_id = ?", investorID).
+		Preload("Offer").
+		Preload("Offer.Project").
+		Order("term_sheets.created_at DESC").
+		Find(&termSheets).Error
+
+	return termSheets, err
+}
+
+// GetDeveloperTermSheets returns all term sheets on a developer's projects
+func (s *TermSheetService) GetDeveloperTermSheets(developerID uuid.UUID) ([]models.TermSheet, error) {
+	db := database.GetDB()
+
+	var termSheets []models.TermSheet
+	err := db.Joins("JOIN investment_offers ON investment_offers.id = term_sheets.offer_id").
+		Joins("JOIN projects ON projects.id = investment_offers.project_id").
+		Where("projects.developer_id = ?", developerID).
+		Preload("Offer").
+		Preload("Offer.Investor").
+		Preload("Offer.Project").
+		Order("term_sheets.created_at DESC").
+		Find(&termSheets).Error
+
+	return termSheets, err
+}
+
+// ExpireUnsignedTermSheets expires term sheets that were not fully signed before their expiry date
+func (s *TermSheetService) ExpireUnsignedTermSheets() (int64, error) {
+	db := database.GetDB()
+
+	var termSheets []models.TermSheet
+	if err := db.Where("status IN ? AND expires_at < ?", []models.TermSheetStatus{
+		models.TermSheetStatusDraft,
+		models.TermSheetStatusPendingInvestor,
+		models.TermSheetStatusPendingFounder,
+	}, time.Now()).Find(&termSheets).Error; err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for i := range termSheets {
+		if s.markExpired(&termSheets[i]) {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// GetTermSheetDocument returns the SAFE text and its hash for a party to review before signing
+func (s *TermSheetService) GetTermSheetDocument(userID, termSheetID uuid.UUID) (string, string, error) {
+	termSheet, err := s.GetTermSheet(userID, termSheetID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if termSheet.DocumentContent == "" {
+		return "", "", errors.New("term sheet document has not been generated")
+	}
+
+	return termSheet.DocumentContent, termSheet.DocumentHash, nil
+}
+
+// renderSAFEDocument fills the SAFE template for the term sheet's type from the offer,
+// the project's legal entity details and the investor's profile
+func (s *TermSheetService) renderSAFEDocument(termSheet *models.TermSheet, offer *models.InvestmentOffer, date time.Time) (string, error) {
+	db := database.GetDB()
+
+	var investor models.User
+	if err := db.Preload("InvestorProfile").First(&investor, "id = ?", offer.InvestorID).Error; err != nil {
+		return "", errors.New("investor not found")
+	}
+
+	if offer.Project == nil {
+		return "", errors.New("project not found")
+	}
+
+	// Company details come from the project's legal entity, falling back to the listing
+	companyName := offer.Project.Title
+	entityType := "company"
+	jurisdiction := "[jurisdiction not specified]"
+	registration := ""
+
+	var readiness models.ProjectReadiness
+	if err := db.Where("project_id = ?", offer.Project.ID).First(&readiness).Error; err == nil && readiness.HasLegalEntity {
+		if readiness.LegalEntityName != "" {
+			companyName = readiness.LegalEntityName
+		}
+		if readiness.LegalEntityType != "" {
+			entityType = readiness.LegalEntityType
+		}
+		if readiness.LegalJurisdiction != "" {
+			jurisdiction = readiness.LegalJurisdiction
+		}
+		registration = readiness.RegistrationNumber
+	}
+
+	data := map[string]interface{}{
+		"CompanyName":         companyName,
+		"CompanyEntityType":   entityType,
+		"CompanyJurisdiction": jurisdiction,
+		"CompanyRegistration": registration,
+		"InvestorName":        investor.FullName(),
+		"InvestorType":        "private",
+		"PurchaseAmount":      models.FormatCurrency(termSheet.InvestmentAmount, termSheet.Currency),
+		"ValuationCap":        "",
+		"DiscountPercent":     "",
+		"DiscountRatePercent": "",
+		"HasMFN":              termSheet.HasMFN,
+		"ProRataRights":       termSheet.ProRataRights,
+		"DocumentDate":        date.Format("January 2, 2006"),
+		"TermSheetID":         termSheet.ID.String(),
+		"Version":             "1.0",
+	}
+
+	if investor.InvestorProfile != nil {
+		profile := investor.InvestorProfile.ForNDA()
+		if profile["type"] == "institutional" {
+			data["InvestorType"] = "institutional"
+			data["InvestorName"] = profile["company_name"]
+			data["InvestorRegistration"] = profile["registration"]
+			data["InvestorJurisdiction"] = profile["jurisdiction"]
+			data["InvestorAddress"] = joinNonEmpty(", ",
+				profile["address"], profile["city"], profile["state"], profile["postal_code"], profile["country"])
+			data["InvestorSignatory"] = profile["signatory"]
+			data["InvestorTitle"] = profile["title"]
+		}
+	}
+
+	if termSheet.ValuationCap > 0 {
+		data["ValuationCap"] = models.FormatCurrency(termSheet.ValuationCap, termSheet.Currency)
+	}
+	if termSheet.DiscountRate > 0 {
+		data["DiscountPercent"] = fmt.Sprintf("%g%%", termSheet.DiscountRate*100)
+		data["DiscountRatePercent"] = fmt.Sprintf("%g%%", (1-termSheet.DiscountRate)*100)
+	}
+
+	tmpl, err := template.New("safe").Parse(models.SAFETemplateFor(termSheet.SAFEType))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// checkDocumentHash ensures the signer saw the stored document and that it is unaltered
+func (s *TermSheetService) checkDocumentHash(termSheet *models.TermSheet, signedHash string) error {
+	if termSheet.DocumentHash == "" || models.HashDocument(termSheet.DocumentContent) != termSheet.DocumentHash {
+		return errors.New("term sheet document failed integrity check")
+	}
+	if signedHash != termSheet.DocumentHash {
+		return errors.New("document hash does not match the current term sheet")
+	}
+	return nil
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
+
+func (s *TermSheetService) loadTermSheet(termSheetID uuid.UUID) (*models.TermSheet, error) {
+	db := database.GetDB()
+
+	var termSheet models.TermSheet
+	if err := db.Preload("Offer").
+		Preload("Offer.Investor").
+		Preload("Offer.Project").
+		First(&termSheet, "id = ?", termSheetID).Error; err != nil {
+		return nil, errors.New("term sheet not found")
+	}
+
+	if termSheet.Offer == nil {
+		return nil, errors.New("term sheet not found")
+	}
+
+	return &termSheet, nil
+}
+
+// checkNotExpired rejects signing after the deadline, flagging the sheet as expired
+func (s *TermSheetService) checkNotExpired(termSheet *models.TermSheet) error {
+	if !termSheet.IsExpired() {
+		return nil
+	}
+	s.markExpired(termSheet)
+	return errors.New("term sheet has expired")
+}
+
+// markExpired transitions an unsigned term sheet to expired and records it in the audit trail
+func (s *TermSheetService) markExpired(termSheet *models.TermSheet) bool {
+	db := database.GetDB()
+
+	result := db.Model(&models.TermSheet{}).
+		Where("id = ? AND status IN ?", termSheet.ID, []models.TermSheetStatus{
+			models.TermSheetStatusDraft,
+			models.TermSheetStatusPendingInvestor,
+			models.TermSheetStatusPendingFounder,
+		}).
+		Update("status", models.TermSheetStatusExpired)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+
+	termSheet.Status = models.TermSheetStatusExpired
+
+	s.auditService.LogAction(nil, "", "", models.AuditActionTermSheetExpired, "term_sheet", &termSheet.ID, "",
+		"Term sheet expired before it was fully signed",
+		map[string]interface{}{"offer_id": termSheet.OfferID}, "", "")
+
+	return true
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ukuvago/angelvault/internal/models"
+)
+
+func TestExpiredTermSheetCanBeRegenerated(t *testing.T) {
+	db := requireDB(t)
+	cfg := testConfig()
+	cfg.TermSheetValidityDays = 30
+	audit := NewAuditService(cfg)
+	offers := NewOfferService(cfg, audit)
+	termSheets := NewTermSheetService(cfg, audit)
+	investor := createTestUser(t, models.RoleInvestor)
+	project := createTestProject(t)
+
+	offer, err := offers.CreateOffer(investor.ID, &CreateOfferInput{ProjectID: project.ID, Amount: project.MinInvestment}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := offers.AcceptOffer(project.DeveloperID, offer.ID, 1, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := termSheets.GenerateTermSheet(investor.ID, &GenerateTermSheetInput{OfferID: offer.ID}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := termSheets.GenerateTermSheet(investor.ID, &GenerateTermSheetInput{OfferID: offer.ID}, "", ""); err == nil {
+		t.Fatal("generated a second term sheet while the first can still be signed")
+	}
+
+	// The signing deadline passes without anyone marking the sheet expired
+	db.Model(first).Update("expires_at", time.Now().Add(-time.Hour))
+
+	second, err := termSheets.GenerateTermSheet(project.DeveloperID, &GenerateTermSheetInput{OfferID: offer.ID}, "", "")
+	if err != nil {
+		t.Fatalf("got %v regenerating after the term sheet expired", err)
+	}
+	if second.ID == first.ID || second.Status != models.TermSheetStatusPendingInvestor {
+		t.Fatalf("got term sheet %s in status %s, want a new one awaiting the investor", second.ID, second.Status)
+	}
+
+	var sheets []models.TermSheet
+	db.Where("offer_id = ?", offer.ID).Find(&sheets)
+	if len(sheets) != 1 || sheets[0].ID != second.ID {
+		t.Fatalf("got %d term sheets for the offer, want only the new one", len(sheets))
+	}
+
+	// A cancelled sheet is replaced too
+	db.Model(second).Update("status", models.TermSheetStatusCancelled)
+	if _, err := termSheets.GenerateTermSheet(investor.ID, &GenerateTermSheetInput{OfferID: offer.ID}, "", ""); err != nil {
+		t.Fatalf("got %v regenerating after the term sheet was cancelled", err)
+	}
+}
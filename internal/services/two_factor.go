@@ -0,0 +1,507 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	// A password sign-in must be completed with a second factor within this time
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+
+	// Codes that can be tried against one challenge, and wrong codes across
+	// challenges before the user's second factor is locked for twoFactorLockout
+	maxChallengeAttempts = 5
+	maxTwoFactorFailures = 10
+	twoFactorLockout     = 15 * time.Minute
+
+	challengeAudience      = "2fa-challenge"
+	challengePurposeVerify = "verify" // Enter a TOTP or recovery code
+	challengePurposeEnroll = "enroll" // Admin without 2FA must set it up first
+)
+
+var (
+	ErrInvalidChallenge     = errors.New("sign-in challenge is invalid or has expired; please sign in again")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked      = errors.New("too many incorrect codes; please try again later")
+)
+
+// twoFactorChallenge is the token handed out after the password step. It has no
+// session, so it is never accepted as an access token. Its ID names the
+// models.TwoFactorChallenge that limits its use.
+type twoFactorChallenge struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Purpose string    `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// beginSession signs the user in, or, when they need a second factor, returns a
+// challenge to complete at /auth/2fa/login
+func (s *AuthService) beginSession(user *models.User, ipAddress, userAgent string) (*AuthResponse, error) {
+	if !user.RequiresTwoFactor() {
+		return s.startSession(user, ipAddress, userAgent)
+	}
+
+	purpose := challengePurposeVerify
+	if !user.TOTPEnabled {
+		purpose = challengePurposeEnroll
+	}
+
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
+	challenge := &models.TwoFactorChallenge{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}
+	if err := database.GetDB().Create(challenge).Error; err != nil {
+		return nil, err
+	}
+
+	claims := &twoFactorChallenge{
+		UserID:  user.ID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challenge.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "angelvault",
+			Audience:  jwt.ClaimStrings{challengeAudience},
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		TwoFactorRequired:      purpose == challengePurposeVerify,
+		TwoFactorSetupRequired: purpose == challengePurposeEnroll,
+		ChallengeToken:         token,
+		ExpiresAt:              expiresAt,
+		User:                   user.ToResponse(),
+	}, nil
+}
+
+// parseChallenge loads the user a sign-in challenge was issued to, and the
+// challenge's ID
+func (s *AuthService) parseChallenge(token, purpose string) (*models.User, uuid.UUID, error) {
+	claims := &twoFactorChallenge{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(challengeAudience))
+	if err != nil || !parsed.Valid || claims.Purpose != purpose {
+		return nil, uuid.Nil, ErrInvalidChallenge
+	}
+	challengeID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, uuid.Nil, ErrInvalidChallenge
+	}
+
+	db := database.GetDB()
+	var challenge models.TwoFactorChallenge
+	if err := db.First(&challenge, "id = ? AND user_id = ? AND purpose = ? AND expires_at > ?",
+		challengeID, claims.UserID, purpose, time.Now()).Error; err != nil {
+		return nil, uuid.Nil, ErrInvalidChallenge
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", claims.UserID).Error; err != nil {
+		return nil, uuid.Nil, ErrInvalidChallenge
+	}
+	if !user.IsActive {
+		return nil, uuid.Nil, errors.New("account is disabled")
+	}
+	return &user, challenge.ID, nil
+}
+
+// useChallengeAttempt counts an attempt against a challenge before its code is
+// checked, so parallel guesses cannot exceed the limit. It fails once the
+// challenge is spent.
+func useChallengeAttempt(db *gorm.DB, challengeID uuid.UUID) error {
+	result := db.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND attempts < ? AND expires_at > ?", challengeID, maxChallengeAttempts, time.Now()).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// consumeChallenge deletes a challenge after it completed a sign-in. Only one
+// of several concurrent requests with the same challenge gets to do so.
+func consumeChallenge(db *gorm.DB, challengeID uuid.UUID) error {
+	result := db.Where("id = ?", challengeID).Delete(&models.TwoFactorChallenge{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// recordTwoFactorFailure counts a wrong code against the user, locking their
+// second factor once there have been too many
+func recordTwoFactorFailure(db *gorm.DB, userID uuid.UUID) {
+	db.Model(&models.User{}).Where("id = ?", userID).
+		UpdateColumn("two_factor_failures", gorm.Expr("two_factor_failures + 1"))
+	db.Model(&models.User{}).
+		Where("id = ? AND two_factor_failures >= ?", userID, maxTwoFactorFailures).
+		UpdateColumns(map[string]interface{}{
+			"two_factor_failures":     0,
+			"two_factor_locked_until": time.Now().Add(twoFactorLockout),
+		})
+}
+
+// CompleteTwoFactorLogin finishes a sign-in with a TOTP code or a recovery code.
+// A challenge completes one sign-in and allows maxChallengeAttempts codes; too
+// many wrong codes across challenges lock the user's second factor for a while.
+func (s *AuthService) CompleteTwoFactorLogin(challengeToken, code, recoveryCode, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	user, challengeID, err := s.parseChallenge(challengeToken, challengePurposeVerify)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorLockedUntil != nil && time.Now().Before(*user.TwoFactorLockedUntil) {
+		return nil, ErrTwoFactorLocked
+	}
+	if err := useChallengeAttempt(db, challengeID); err != nil {
+		return nil, err
+	}
+
+	if err := verifySecondFactor(db, user, code, recoveryCode); err != nil {
+		recordTwoFactorFailure(db, user.ID)
+		return nil, err
+	}
+
+	if err := consumeChallenge(db, challengeID); err != nil {
+		return nil, err
+	}
+	db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("two_factor_failures", 0)
+
+	return s.startSession(user, ipAddress, userAgent)
+}
+
+// PurgeTwoFactorChallenges deletes sign-in challenges that were never completed
+func (s *AuthService) PurgeTwoFactorChallenges() (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.TwoFactorChallenge{})
+	return result.RowsAffected, result.Error
+}
+
+// BeginLoginEnrollment starts TOTP set-up for an admin who cannot sign in until
+// they have it
+func (s *AuthService) BeginLoginEnrollment(challengeToken string) (*models.TwoFactorEnrollment, error) {
+	user, _, err := s.parseChallenge(challengeToken, challengePurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return s.enroll(user)
+}
+
+// CompleteLoginEnrollment turns on TOTP with a first code and finishes the
+// sign-in. The response carries the new recovery codes. Wrong codes count
+// against the challenge and the user as in CompleteTwoFactorLogin.
+func (s *AuthService) CompleteLoginEnrollment(challengeToken, code, ipAddress, userAgent string) (*AuthResponse, error) {
+	db := database.GetDB()
+
+	user, challengeID, err := s.parseChallenge(challengeToken, challengePurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorLockedUntil != nil && time.Now().Before(*user.TwoFactorLockedUntil) {
+		return nil, ErrTwoFactorLocked
+	}
+	if err := useChallengeAttempt(db, challengeID); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.activate(user, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			recordTwoFactorFailure(db, user.ID)
+		}
+		return nil, err
+	}
+	if err := consumeChallenge(db, challengeID); err != nil {
+		return nil, err
+	}
+	db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("two_factor_failures", 0)
+
+	resp, err := s.startSession(user, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = codes
+	return resp, nil
+}
+
+// GetTwoFactorStatus returns whether the user has 2FA and how many recovery
+// codes they have left
+func (s *AuthService) GetTwoFactorStatus(userID uuid.UUID) (*models.TwoFactorStatus, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	status := &models.TwoFactorStatus{
+		Enabled:   user.TOTPEnabled,
+		Required:  user.IsAdmin(),
+		EnabledAt: user.TOTPEnabledAt,
+	}
+	db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&status.RecoveryCodesRemaining)
+	return status, nil
+}
+
+// BeginTwoFactorEnrollment generates a new secret for a signed-in user. 2FA is
+// not on until EnableTwoFactor confirms a code from it.
+func (s *AuthService) BeginTwoFactorEnrollment(userID uuid.UUID) (*models.TwoFactorEnrollment, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	return s.enroll(&user)
+}
+
+// EnableTwoFactor confirms enrollment with a code and returns recovery codes,
+// which are shown only this once
+func (s *AuthService) EnableTwoFactor(userID uuid.UUID, code string) ([]string, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	return s.activate(&user, code)
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code. Admins must keep it.
+func (s *AuthService) DisableTwoFactor(userID uuid.UUID, code, recoveryCode string) error {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if user.IsAdmin() {
+		return errors.New("two-factor authentication is mandatory for admins")
+	}
+	if !user.TOTPEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if err := verifySecondFactor(db, &user, code, recoveryCode); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return clearTwoFactor(tx, user.ID)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// current TOTP code
+func (s *AuthService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := verifySecondFactor(db, &user, code, ""); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// enroll stores a fresh, not yet active secret for the user
+func (s *AuthService) enroll(user *models.User) (*models.TwoFactorEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	result := database.GetDB().Model(&models.User{}).
+		Where("id = ? AND totp_enabled = ?", user.ID, false).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.config.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// activate turns on TOTP once the user proves their app has the secret, and
+// issues their recovery codes
+func (s *AuthService) activate(user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("start two-factor enrollment first")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled = ? AND totp_secret = ?", user.ID, false, user.TOTPSecret).
+			Updates(map[string]interface{}{
+				"totp_enabled":      true,
+				"totp_enabled_at":   now,
+				"totp_last_counter": step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("two-factor enrollment changed; please start again")
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	return codes, nil
+}
+
+// verifySecondFactor accepts a TOTP code, or failing that a recovery code
+func verifySecondFactor(db *gorm.DB, user *models.User, code, recoveryCode string) error {
+	if code != "" {
+		if verifyTOTP(db, user, code) {
+			return nil
+		}
+		return ErrInvalidTwoFactorCode
+	}
+	if recoveryCode != "" {
+		if useRecoveryCode(db, user.ID, recoveryCode) {
+			return nil
+		}
+		return errors.New("invalid recovery code")
+	}
+	return errors.New("a two-factor code or recovery code is required")
+}
+
+// verifyTOTP checks a code and records its time step, so the same code cannot be
+// used twice even while it is still current
+func verifyTOTP(db *gorm.DB, user *models.User, code string) bool {
+	if !user.TOTPEnabled || user.TOTPSecret == "" {
+		return false
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_counter < ?", user.ID, step).
+		Update("totp_last_counter", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// useRecoveryCode spends one of the user's recovery codes
+func useRecoveryCode(db *gorm.DB, userID uuid.UUID, code string) bool {
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and issues a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clearTwoFactor removes a user's TOTP secret and recovery codes
+func clearTwoFactor(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":       "",
+		"totp_enabled":      false,
+		"totp_enabled_at":   nil,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// generateRecoveryCode returns a code such as "k7dq2-xm4pa"
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ukuvago/angelvault/internal/database"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/totp"
+)
+
+// createTwoFactorUser creates an investor with TOTP turned on
+func createTwoFactorUser(t *testing.T) (*models.User, string) {
+	t.Helper()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, models.RoleInvestor)
+	user.TOTPSecret = secret
+	user.TOTPEnabled = true
+	if err := database.GetDB().Save(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, secret
+}
+
+func twoFactorChallengeFor(t *testing.T, svc *AuthService, user *models.User) string {
+	t.Helper()
+
+	resp, err := svc.beginSession(user, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.TwoFactorRequired || resp.ChallengeToken == "" {
+		t.Fatalf("no challenge issued: %+v", resp)
+	}
+	return resp.ChallengeToken
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// wrongCode is the current code with its last digit changed
+func wrongCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code := []byte(currentCode(t, secret))
+	code[len(code)-1] = '0' + (code[len(code)-1]-'0'+1)%10
+	return string(code)
+}
+
+func TestTwoFactorChallengeIsSingleUse(t *testing.T) {
+	requireDB(t)
+	svc := NewAuthService(testConfig(), NewAuditService(testConfig()), nil)
+	user, secret := createTwoFactorUser(t)
+	challenge := twoFactorChallengeFor(t, svc, user)
+
+	if _, err := svc.CompleteTwoFactorLogin(challenge, currentCode(t, secret), "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the challenge fails even with a code that would be accepted
+	database.GetDB().Model(user).Update("totp_last_counter", 0)
+	if _, err := svc.CompleteTwoFactorLogin(challenge, currentCode(t, secret), "", "", ""); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("got %v replaying a used challenge, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestTwoFactorChallengeAttemptsAreLimited(t *testing.T) {
+	requireDB(t)
+	svc := NewAuthService(testConfig(), NewAuditService(testConfig()), nil)
+	user, secret := createTwoFactorUser(t)
+	challenge := twoFactorChallengeFor(t, svc, user)
+
+	for i := 0; i < maxChallengeAttempts; i++ {
+		if _, err := svc.CompleteTwoFactorLogin(challenge, wrongCode(t, secret), "", "", ""); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+
+	if _, err := svc.CompleteTwoFactorLogin(challenge, currentCode(t, secret), "", "", ""); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("got %v after %d misses, want ErrInvalidChallenge", err, maxChallengeAttempts)
+	}
+}
+
+func TestTwoFactorLocksOutAfterRepeatedMisses(t *testing.T) {
+	db := requireDB(t)
+	svc := NewAuthService(testConfig(), NewAuditService(testConfig()), nil)
+	user, secret := createTwoFactorUser(t)
+
+	// Fresh challenges, as from signing in with the password again
+	for i := 0; i < maxTwoFactorFailures; i++ {
+		challenge := twoFactorChallengeFor(t, svc, user)
+		if _, err := svc.CompleteTwoFactorLogin(challenge, "", "not-a-recovery-code", "", ""); err == nil {
+			t.Fatal("wrong recovery code accepted")
+		}
+	}
+
+	challenge := twoFactorChallengeFor(t, svc, user)
+	if _, err := svc.CompleteTwoFactorLogin(challenge, currentCode(t, secret), "", "", ""); !errors.Is(err, ErrTwoFactorLocked) {
+		t.Fatalf("got %v after %d misses, want ErrTwoFactorLocked", err, maxTwoFactorFailures)
+	}
+
+	db.Model(user).Update("two_factor_locked_until", time.Now().Add(-time.Second))
+	if _, err := svc.CompleteTwoFactorLogin(challenge, currentCode(t, secret), "", "", ""); err != nil {
+		t.Fatalf("got %v once the lockout ended", err)
+	}
+}
+
+func TestLoginEnrollmentLocksOutAfterRepeatedMisses(t *testing.T) {
+	requireDB(t)
+	svc := NewAuthService(testConfig(), NewAuditService(testConfig()), nil)
+	admin := createTestUser(t, models.RoleAdmin)
+
+	enrollChallenge := func() string {
+		t.Helper()
+		resp, err := svc.beginSession(admin, "127.0.0.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.TwoFactorSetupRequired {
+			t.Fatalf("no enrollment challenge issued: %+v", resp)
+		}
+		return resp.ChallengeToken
+	}
+
+	enrollment, err := svc.BeginLoginEnrollment(enrollChallenge())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxTwoFactorFailures; i++ {
+		if _, err := svc.CompleteLoginEnrollment(enrollChallenge(), wrongCode(t, enrollment.Secret), "", ""); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+
+	if _, err := svc.CompleteLoginEnrollment(enrollChallenge(), currentCode(t, enrollment.Secret), "", ""); !errors.Is(err, ErrTwoFactorLocked) {
+		t.Fatalf("got %v after %d misses, want ErrTwoFactorLocked", err, maxTwoFactorFailures)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+	"github.com/ukuvago/angelvault/internal/models"
+	"github.com/ukuvago/angelvault/internal/payments"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func newStripeWebhookService() *PaymentService {
+	cfg := testConfig()
+	audit := NewAuditService(cfg)
+	return NewPaymentService(cfg, audit, NewPricingService(cfg, audit), payments.NewStripeProvider("sk_test", testWebhookSecret))
+}
+
+// signedStripeEvent builds a Stripe event delivery signed with the test secret
+func signedStripeEvent(t *testing.T, id, eventType string, object map[string]interface{}) ([]byte, string) {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"created":     time.Now().Unix(),
+		"data":        map[string]interface{}{"object": object},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    testWebhookSecret,
+		Timestamp: time.Now(),
+	})
+	return payload, signed.Header
+}
+
+func intentObject(payment *models.Payment, intentID, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       intentID,
+		"object":   "payment_intent",
+		"amount":   payment.Amount,
+		"currency": payment.Currency,
+		"status":   status,
+		"metadata": map[string]string{"type": "view_credits", "payment_id": payment.ID.String()},
+	}
+}
+
+func chargeObject(intentID string, amountRefunded int64, refunded bool) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              "ch_" + intentID,
+		"object":          "charge",
+		"payment_intent":  intentID,
+		"amount_refunded": amountRefunded,
+		"refunded":        refunded,
+	}
+}
+
+func deliver(t *testing.T, svc *PaymentService, id, eventType string, object map[string]interface{}) {
+	t.Helper()
+
+	payload, signature := signedStripeEvent(t, id, eventType, object)
+	if err := svc.HandleWebhook(payload, signature); err != nil {
+		t.Fatalf("%s %s: %v", eventType, id, err)
+	}
+}
+
+func TestWebhookPaymentSucceeded(t *testing.T) {
+	requireDB(t)
+	svc := newStripeWebhookService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createTestPayment(t, investor.ID, 10)
+
+	deliver(t, svc, "evt_succeeded", "payment_intent.succeeded", intentObject(payment, "pi_1", "succeeded"))
+
+	got := reloadPayment(t, payment.ID)
+	if got.Status != models.PaymentStatusCompleted || got.StripePaymentID != "pi_1" || got.CompletedAt == nil {
+		t.Fatalf("payment not completed: status %s, intent %q", got.Status, got.StripePaymentID)
+	}
+	if credits := svc.GetTotalRemainingCredits(investor.ID); credits != 10 {
+		t.Fatalf("got %d credits, want 10", credits)
+	}
+}
+
+func TestWebhookRejectsBadSignature(t *testing.T) {
+	requireDB(t)
+	svc := newStripeWebhookService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createTestPayment(t, investor.ID, 10)
+
+	payload, _ := signedStripeEvent(t, "evt_forged", "payment_intent.succeeded", intentObject(payment, "pi_1", "succeeded"))
+	forged := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    "whsec_attacker",
+		Timestamp: time.Now(),
+	})
+
+	if err := svc.HandleWebhook(payload, forged.Header); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("got %v, want ErrInvalidWebhookSignature", err)
+	}
+	if got := reloadPayment(t, payment.ID); got.Status != models.PaymentStatusPending {
+		t.Fatalf("forged event changed payment to %s", got.Status)
+	}
+}
+
+func TestWebhookReplayedEventIsIgnored(t *testing.T) {
+	db := requireDB(t)
+	svc := newStripeWebhookService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createTestPayment(t, investor.ID, 10)
+
+	deliver(t, svc, "evt_succeeded", "payment_intent.succeeded", intentObject(payment, "pi_1", "succeeded"))
+	deliver(t, svc, "evt_refund", "charge.refunded", chargeObject("pi_1", payment.Amount, true))
+
+	// A replay of the succeeded event must not bring the refunded credits back,
+	// and a replay of the refund must not post a second ledger entry
+	deliver(t, svc, "evt_succeeded", "payment_intent.succeeded", intentObject(payment, "pi_1", "succeeded"))
+	deliver(t, svc, "evt_refund", "charge.refunded", chargeObject("pi_1", payment.Amount, true))
+
+	got := reloadPayment(t, payment.ID)
+	if got.Status != models.PaymentStatusRefunded || got.ProjectsRemaining != 0 {
+		t.Fatalf("got status %s with %d credits, want refunded with 0", got.Status, got.ProjectsRemaining)
+	}
+
+	var events, refunds int64
+	db.Model(&models.StripeWebhookEvent{}).Count(&events)
+	db.Model(&models.CreditLedgerEntry{}).Where("payment_id = ? AND entry_type = ?", payment.ID, models.LedgerEntryRefund).Count(&refunds)
+	if events != 2 || refunds != 1 {
+		t.Fatalf("got %d events and %d refund entries, want 2 and 1", events, refunds)
+	}
+}
+
+func TestWebhookOutOfOrderEvents(t *testing.T) {
+	requireDB(t)
+	svc := newStripeWebhookService()
+	investor := createTestUser(t, models.RoleInvestor)
+	payment := createTestPayment(t, investor.ID, 10)
+
+	// A declined first attempt can be delivered after the retry that succeeded
+	deliver(t, svc, "evt_succeeded", "payment_intent.succeeded", intentObject(payment, "pi_1", "succeeded"))
+	deliver(t, svc, "evt_failed", "payment_intent.payment_failed", intentObject(payment, "pi_1", "requires_payment_method"))
+
+	if got := reloadPayment(t, payment.ID); got.Status != models.PaymentStatusCompleted || got.ProjectsRemaining != 10 {
+		t.Fatalf("late failure changed payment to %s with %d credits", got.Status, got.ProjectsRemaining)
+	}
+
+	// Refund events carry the cumulative amount, so an older one arriving late
+	// must not lower what has been refunded
+	deliver(t, svc, "evt_refund_2", "charge.refunded", chargeObject("pi_1", 30000, false))
+	deliver(t, svc, "evt_refund_1", "charge.refunded", chargeObject("pi_1", 10000, false))
+
+	got := reloadPayment(t, payment.ID)
+	if got.AmountRefunded != 30000 || got.Status != models.PaymentStatusCompleted {
+		t.Fatalf("got %d refunded with status %s, want 30000 and completed", got.AmountRefunded, got.Status)
+	}
+}
@@ -0,0 +1,112 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890"
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// Appendix B lists eight digit codes; six digit codes are their last six
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.code[len(tt.code)-Digits:]; got != want {
+			t.Fatalf("got %s at %d, want %s", got, tt.unix, want)
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Counter(now)
+
+	for offset := int64(-Skew - 1); offset <= Skew+1; offset++ {
+		code, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched, ok := Validate(rfcSecret, code, now)
+		if inWindow := offset >= -Skew && offset <= Skew; ok != inWindow {
+			t.Fatalf("code %d steps from now accepted: %v, want %v", offset, ok, inWindow)
+		}
+		if ok && matched != step+offset {
+			t.Fatalf("got step %d, want %d", matched, step+offset)
+		}
+	}
+
+	// Authenticator apps may show the code in two groups
+	code, _ := Code(rfcSecret, step)
+	if _, ok := Validate(rfcSecret, " "+code[:3]+" "+code[3:]+" ", now); !ok {
+		t.Fatal("rejected a code with spaces")
+	}
+	for _, bad := range []string{"", code[:Digits-1], code + "0", strings.Repeat("x", Digits)} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Fatalf("accepted %q", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", code, now); ok {
+		t.Fatal("accepted a code for an invalid secret")
+	}
+}
+
+func TestReplayedCodeMatchesTheSameStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := Code(rfcSecret, Counter(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, ok := Validate(rfcSecret, code, now)
+	if !ok {
+		t.Fatal("rejected the current code")
+	}
+
+	// Still inside the skew window a period later, the code reports the step it
+	// was first accepted at, so a caller that keeps the last step refuses it
+	replayed, ok := Validate(rfcSecret, code, now.Add(Period))
+	if !ok || replayed > last {
+		t.Fatalf("replay matched step %d (ok %v), want at most %d", replayed, ok, last)
+	}
+
+	next, err := Code(rfcSecret, Counter(now.Add(Period)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := Validate(rfcSecret, next, now.Add(Period)); !ok || step <= last {
+		t.Fatalf("next code matched step %d (ok %v), want after %d", step, ok, last)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("generated the same secret twice")
+	}
+	if key, err := encoding.DecodeString(a); err != nil || len(key) != secretBytes {
+		t.Fatalf("got a %d byte secret (%v), want %d bytes", len(key), err, secretBytes)
+	}
+}
@@ -0,0 +1,261 @@
+// Package webauthn implements the relying party side of WebAuthn (passkeys):
+// issuing challenges, verifying registrations with "none" attestation and
+// verifying assertions. Attestation statements are not checked, so any
+// authenticator the browser accepts can be registered.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// Timeout is how long the browser is given to complete a ceremony
+	Timeout = 5 * time.Minute
+
+	challengeBytes = 32
+
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+
+	authDataMinLength = 37 // rpIdHash, flags and signCount
+)
+
+var (
+	ErrInvalidClientData   = errors.New("invalid client data")
+	ErrChallengeMismatch   = errors.New("challenge does not match")
+	ErrOriginMismatch      = errors.New("origin is not allowed")
+	ErrRPIDMismatch        = errors.New("credential is scoped to a different relying party")
+	ErrUserNotPresent      = errors.New("user presence was not confirmed")
+	ErrInvalidSignature    = errors.New("invalid signature")
+	ErrSignCountRegression = errors.New("sign count did not increase; the authenticator may have been cloned")
+)
+
+// Encoding is the base64url alphabet WebAuthn uses for binary values in JSON
+var Encoding = base64.RawURLEncoding
+
+// RelyingParty identifies this service to authenticators
+type RelyingParty struct {
+	ID      string   // Effective domain, e.g. "angelvault.io"
+	Name    string   // Shown by the authenticator
+	Origins []string // Origins the browser may report, e.g. "https://angelvault.io"
+}
+
+// NewChallenge returns a random base64url challenge
+func NewChallenge() (string, error) {
+	buf := make([]byte, challengeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return Encoding.EncodeToString(buf), nil
+}
+
+// ClientData is the clientDataJSON the browser signs over
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// ParseClientData decodes clientDataJSON so the challenge can be looked up
+// before the rest of the response is verified
+func ParseClientData(raw []byte) (*ClientData, error) {
+	var cd ClientData
+	if err := json.Unmarshal(raw, &cd); err != nil || cd.Challenge == "" {
+		return nil, ErrInvalidClientData
+	}
+	return &cd, nil
+}
+
+// Credential is a verified, newly registered credential
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key, as stored and passed back to VerifyAssertion
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+// Assertion is the outcome of a verified login
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+	BackupState  bool
+}
+
+// authenticatorData is the parsed binary structure authenticators sign
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	aaguid       []byte
+	credentialID []byte
+	publicKey    *publicKey
+	publicKeyRaw []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < authDataMinLength {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	ad := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := data[authDataMinLength:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data is too short")
+	}
+	ad.aaguid = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, errors.New("invalid credential ID")
+	}
+	ad.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	key, after, err := parsePublicKey(rest)
+	if err != nil {
+		return nil, err
+	}
+	ad.publicKey = key
+	ad.publicKeyRaw = rest[:len(rest)-len(after)]
+	return ad, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	cd, err := ParseClientData(raw)
+	if err != nil {
+		return err
+	}
+	if cd.Type != ceremony {
+		return ErrInvalidClientData
+	}
+	if cd.Challenge != challenge {
+		return ErrChallengeMismatch
+	}
+	for _, origin := range rp.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+// verifyAuthenticatorData checks the RP ID hash and user presence
+func (rp *RelyingParty) verifyAuthenticatorData(ad *authenticatorData) error {
+	expected := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, expected[:]) {
+		return ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	return nil
+}
+
+// VerifyRegistration checks the response to navigator.credentials.create()
+// against the challenge that was issued and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	raw, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, errors.New("invalid attestation object")
+	}
+	attestation, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	ad, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return nil, err
+	}
+	if ad.publicKey == nil {
+		return nil, errors.New("attestation has no credential")
+	}
+
+	return &Credential{
+		ID:             append([]byte(nil), ad.credentialID...),
+		PublicKey:      append([]byte(nil), ad.publicKeyRaw...),
+		Algorithm:      ad.publicKey.alg,
+		SignCount:      ad.signCount,
+		AAGUID:         append([]byte(nil), ad.aaguid...),
+		UserVerified:   ad.flags&flagUserVerified != 0,
+		BackupEligible: ad.flags&flagBackupEligible != 0,
+		BackupState:    ad.flags&flagBackupState != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the response to navigator.credentials.get() against
+// the issued challenge and the stored credential. storedSignCount is the last
+// counter seen; callers should persist the returned one.
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKeyCOSE []byte, storedSignCount uint32, clientDataJSON, authData, signature []byte) (*Assertion, error) {
+	if err := rp.verifyClientData(clientDataJSON, ceremonyGet, challenge); err != nil {
+		return nil, err
+	}
+
+	ad, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return nil, err
+	}
+
+	key, _, err := parsePublicKey(publicKeyCOSE)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+	if !key.verify(signed, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Authenticators that keep a counter must always increase it. Synced
+	// passkeys report zero, which is allowed as long as both sides are zero.
+	if (ad.signCount != 0 || storedSignCount != 0) && ad.signCount <= storedSignCount {
+		return nil, ErrSignCountRegression
+	}
+
+	return &Assertion{
+		SignCount:    ad.signCount,
+		UserVerified: ad.flags&flagUserVerified != 0,
+		BackupState:  ad.flags&flagBackupState != 0,
+	}, nil
+}
@@ -0,0 +1,213 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ukuvago/angelvault/internal/webauthn"
+	"github.com/ukuvago/angelvault/internal/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "app.test"
+	testOrigin = "https://app.test"
+)
+
+func testRelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{ID: testRPID, Name: "AngelVault", Origins: []string{testOrigin}}
+}
+
+func newChallenge(t *testing.T) string {
+	t.Helper()
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+// register enrols a credential with the authenticator and verifies it
+func register(t *testing.T, rp *webauthn.RelyingParty, auth *webauthntest.SoftAuthenticator) *webauthn.Credential {
+	t.Helper()
+
+	challenge := newChallenge(t)
+	resp, err := auth.Register(rp.CreationOptions(challenge, []byte("user-handle"), "investor@example.com", "Test User", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := rp.VerifyRegistration(challenge, resp.ClientDataJSON, resp.AttestationObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cred
+}
+
+func verify(rp *webauthn.RelyingParty, challenge string, cred *webauthn.Credential, signCount uint32, resp *webauthntest.AssertionResponse) (*webauthn.Assertion, error) {
+	return rp.VerifyAssertion(challenge, cred.PublicKey, signCount, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+	if cred.Algorithm != webauthn.AlgES256 || cred.SignCount != 1 || !cred.UserVerified {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	challenge := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := verify(rp, challenge, cred, cred.SignCount, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assertion.SignCount != 2 || !assertion.UserVerified {
+		t.Fatalf("unexpected assertion %+v", assertion)
+	}
+}
+
+func TestRegistrationRejectsWrongOriginAndRPID(t *testing.T) {
+	rp := testRelyingParty()
+
+	tests := []struct {
+		name         string
+		rpID, origin string
+		want         error
+	}{
+		{"origin", testRPID, "https://evil.test", webauthn.ErrOriginMismatch},
+		{"rp id", "evil.test", testOrigin, webauthn.ErrRPIDMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := webauthntest.NewSoftAuthenticator(tt.rpID, tt.origin)
+			challenge := newChallenge(t)
+			resp, err := auth.Register(rp.CreationOptions(challenge, []byte("user-handle"), "investor@example.com", "Test User", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rp.VerifyRegistration(challenge, resp.ClientDataJSON, resp.AttestationObject); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertionRejectsWrongOrigin(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	auth.Origin = "https://app.test.evil.test"
+	challenge := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(rp, challenge, cred, cred.SignCount, resp); !errors.Is(err, webauthn.ErrOriginMismatch) {
+		t.Fatalf("got %v, want ErrOriginMismatch", err)
+	}
+}
+
+func TestAssertionRejectsWrongRPIDHash(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	// The same key, but scoped to another site
+	auth.RPID = "evil.test"
+	challenge := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(rp, challenge, cred, cred.SignCount, resp); !errors.Is(err, webauthn.ErrRPIDMismatch) {
+		t.Fatalf("got %v, want ErrRPIDMismatch", err)
+	}
+}
+
+func TestAssertionRequiresUserPresence(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	challenge := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.AuthenticatorData[32] &^= 0x01 // UP
+	if err := auth.Resign(resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(rp, challenge, cred, cred.SignCount, resp); !errors.Is(err, webauthn.ErrUserNotPresent) {
+		t.Fatalf("got %v, want ErrUserNotPresent", err)
+	}
+}
+
+func TestAssertionRejectsOtherChallenge(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	first := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(first, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(rp, first, cred, cred.SignCount, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// A captured assertion cannot answer a later challenge
+	if _, err := verify(rp, newChallenge(t), cred, cred.SignCount, resp); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Fatalf("got %v, want ErrChallengeMismatch", err)
+	}
+}
+
+func TestAssertionRejectsTamperedSignature(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	challenge := newChallenge(t)
+	resp, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.AuthenticatorData[32] ^= 0x04 // UV, without re-signing
+	if _, err := verify(rp, challenge, cred, cred.SignCount, resp); !errors.Is(err, webauthn.ErrInvalidSignature) {
+		t.Fatalf("got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestAssertionRejectsSignCountRegression(t *testing.T) {
+	rp := testRelyingParty()
+	auth := webauthntest.NewSoftAuthenticator(testRPID, testOrigin)
+	cred := register(t, rp, auth)
+
+	// Two assertions from the same authenticator; once the later one has been
+	// accepted, the earlier counter is what a clone would report
+	challenge := newChallenge(t)
+	earlier, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := auth.Assert(cred.ID, rp.RequestOptions(challenge, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion, err := verify(rp, challenge, cred, cred.SignCount, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(rp, challenge, cred, assertion.SignCount, earlier); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("got %v for a lower counter, want ErrSignCountRegression", err)
+	}
+	if _, err := verify(rp, challenge, cred, assertion.SignCount, later); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("got %v for a repeated counter, want ErrSignCountRegression", err)
+	}
+}
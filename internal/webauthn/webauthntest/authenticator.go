@@ -0,0 +1,167 @@
+// Package webauthntest provides an in-memory authenticator for tests. It is
+// not imported by the server.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/ukuvago/angelvault/internal/webauthn"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// SoftAuthenticator is an in-memory ES256 authenticator. It produces the same
+// structures a browser returns, so registration and login can be exercised
+// end to end without hardware.
+type SoftAuthenticator struct {
+	RPID   string
+	Origin string
+
+	// UserVerified controls the UV flag on responses
+	UserVerified bool
+
+	credentials map[string]*softCredential
+}
+
+type softCredential struct {
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// AttestationResponse is what navigator.credentials.create() returns
+type AttestationResponse struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// AssertionResponse is what navigator.credentials.get() returns
+type AssertionResponse struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+// NewSoftAuthenticator returns an authenticator for rpID that reports origin
+func NewSoftAuthenticator(rpID, origin string) *SoftAuthenticator {
+	return &SoftAuthenticator{RPID: rpID, Origin: origin, UserVerified: true, credentials: make(map[string]*softCredential)}
+}
+
+// Register creates a credential for the options' user and challenge
+func (a *SoftAuthenticator) Register(opts webauthn.CreationOptions) (*AttestationResponse, error) {
+	userHandle, err := webauthn.Encoding.DecodeString(opts.User.ID)
+	if err != nil {
+		return nil, errors.New("invalid user handle")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	coseKey, err := encodeES256Key(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &softCredential{key: key, userHandle: userHandle, signCount: 1}
+	a.credentials[string(id)] = cred
+
+	authData := a.authenticatorData(flagAttestedData, cred.signCount)
+	authData = append(authData, make([]byte, 16)...) // Zero AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, coseKey...)
+
+	attestationObject, err := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttestationResponse{
+		CredentialID:      id,
+		ClientDataJSON:    a.clientData("webauthn.create", opts.Challenge),
+		AttestationObject: attestationObject,
+	}, nil
+}
+
+// Assert signs the challenge with a previously registered credential
+func (a *SoftAuthenticator) Assert(credentialID []byte, opts webauthn.RequestOptions) (*AssertionResponse, error) {
+	cred, ok := a.credentials[string(credentialID)]
+	if !ok {
+		return nil, errors.New("unknown credential")
+	}
+	cred.signCount++
+
+	authData := a.authenticatorData(0, cred.signCount)
+	clientDataJSON := a.clientData("webauthn.get", opts.Challenge)
+
+	signature, err := sign(cred.key, authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AssertionResponse{
+		CredentialID:      credentialID,
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         signature,
+		UserHandle:        cred.userHandle,
+	}, nil
+}
+
+// Resign replaces the response's signature after a test has altered its
+// authenticator or client data, so only the altered field fails verification
+func (a *SoftAuthenticator) Resign(resp *AssertionResponse) error {
+	cred, ok := a.credentials[string(resp.CredentialID)]
+	if !ok {
+		return errors.New("unknown credential")
+	}
+	signature, err := sign(cred.key, resp.AuthenticatorData, resp.ClientDataJSON)
+	if err != nil {
+		return err
+	}
+	resp.Signature = signature
+	return nil
+}
+
+// sign produces an assertion signature over authData and the client data hash
+func sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+func (a *SoftAuthenticator) authenticatorData(flags byte, signCount uint32) []byte {
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func (a *SoftAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, _ := json.Marshal(webauthn.ClientData{Type: ceremony, Challenge: challenge, Origin: a.Origin})
+	return data
+}
@@ -0,0 +1,116 @@
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ukuvago/angelvault/internal/webauthn"
+)
+
+// encodeCBOR encodes int, int64, string, []byte and maps keyed by int or string,
+// with map keys in canonical order. It is used by SoftAuthenticator.
+func encodeCBOR(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeItem(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeItem(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		encodeInt(buf, int64(v))
+	case int64:
+		encodeInt(buf, v)
+	case string:
+		writeHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []byte:
+		writeHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case map[interface{}]interface{}:
+		type pair struct{ key, value []byte }
+		pairs := make([]pair, 0, len(v))
+		for key, value := range v {
+			k, err := encodeCBOR(key)
+			if err != nil {
+				return err
+			}
+			val, err := encodeCBOR(value)
+			if err != nil {
+				return err
+			}
+			pairs = append(pairs, pair{k, val})
+		}
+		// Canonical CBOR: shorter keys first, then bytewise
+		sort.Slice(pairs, func(i, j int) bool {
+			if len(pairs[i].key) != len(pairs[j].key) {
+				return len(pairs[i].key) < len(pairs[j].key)
+			}
+			return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+		})
+		writeHead(buf, 5, uint64(len(pairs)))
+		for _, p := range pairs {
+			buf.Write(p.key)
+			buf.Write(p.value)
+		}
+	default:
+		return fmt.Errorf("cbor: cannot encode %T", v)
+	}
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, n int64) {
+	if n >= 0 {
+		writeHead(buf, 0, uint64(n))
+		return
+	}
+	writeHead(buf, 1, uint64(-1-n))
+}
+
+func writeHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+// COSE key parameters for an ES256 public key
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+
+	ktyEC2  = 2
+	crvP256 = 1
+)
+
+// encodeES256Key returns the COSE form of a P-256 public key
+func encodeES256Key(key *ecdsa.PublicKey) ([]byte, error) {
+	return encodeCBOR(map[interface{}]interface{}{
+		int64(coseKty): int64(ktyEC2),
+		int64(coseAlg): webauthn.AlgES256,
+		int64(coseCrv): int64(crvP256),
+		int64(coseX):   key.X.FillBytes(make([]byte, 32)),
+		int64(coseY):   key.Y.FillBytes(make([]byte, 32)),
+	})
+}